@@ -0,0 +1,155 @@
+package simplehttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// AccessLogFormat is the format of each line written by an [AccessLog].
+type AccessLogFormat int
+
+const (
+	// CommonLogFormat is the Apache Common Log Format, ex.
+	//   127.0.0.1 - - [12/May/2024:05:53:22 +0000] "GET /index.html HTTP/1.0" 200 2326
+	CommonLogFormat AccessLogFormat = iota
+	// CombinedLogFormat is the Apache Combined Log Format, which adds the
+	// Referer and User-Agent headers to the Common Log Format.
+	CombinedLogFormat
+	// JSONLogFormat writes each request as a JSON object on its own line.
+	JSONLogFormat
+)
+
+const commonLogTimeFormat string = "02/Jan/2006:15:04:05 -0700"
+
+// An AccessLog writes one line for every request handled by a [Server].
+// An AccessLog should only be created using the [NewAccessLog] method, and
+// is enabled by registering its hooks using [Server.AddHooks]:
+//
+//	accessLog := simplehttp.NewAccessLog(os.Stdout, simplehttp.CombinedLogFormat)
+//	server.AddHooks(accessLog.Hooks())
+//
+// Lines can be written to any [io.Writer]. See [RotatingFile] for a writer
+// that rotates log files by size or date.
+type AccessLog struct {
+	// Format is the format of each line.
+	Format AccessLogFormat
+	writer io.Writer
+	mutex  sync.Mutex
+}
+
+// Creates a new [AccessLog] that writes lines in the provided format to w.
+func NewAccessLog(w io.Writer, format AccessLogFormat) *AccessLog {
+	return &AccessLog{
+		Format: format,
+		writer: w,
+	}
+}
+
+// Returns the [Hooks] to be registered on the [Server] using [Server.AddHooks].
+func (al *AccessLog) Hooks() Hooks {
+	return Hooks{
+		RequestCompleted: al.write,
+	}
+}
+
+type accessLogEntry struct {
+	Time       string  `json:"time"`
+	RemoteAddr string  `json:"remote_addr"`
+	Method     string  `json:"method"`
+	Path       string  `json:"path"`
+	Protocol   string  `json:"protocol"`
+	Status     uint    `json:"status"`
+	Bytes      int64   `json:"bytes"`
+	DurationMs float64 `json:"duration_ms"`
+	Referer    string  `json:"referer"`
+	UserAgent  string  `json:"user_agent"`
+	RequestID  string  `json:"request_id"`
+}
+
+func (al *AccessLog) write(completed CompletedRequest) {
+	line := al.format(completed)
+
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+	io.WriteString(al.writer, line)
+}
+
+func (al *AccessLog) format(completed CompletedRequest) string {
+	req := completed.Request
+	referer, _ := req.headers.get("Referer")
+	userAgent, _ := req.headers.get("User-Agent")
+
+	if al.Format == JSONLogFormat {
+		entry := accessLogEntry{
+			Time:       completed.Start.Format("2006-01-02T15:04:05.000Z07:00"),
+			RemoteAddr: remoteHost(req.remoteAddr),
+			Method:     req.Method(),
+			Path:       req.Uri(),
+			Protocol:   req.httpVersion,
+			Status:     completed.StatusCode,
+			Bytes:      completed.BytesWritten,
+			DurationMs: float64(completed.Duration.Microseconds()) / 1000,
+			Referer:    referer,
+			UserAgent:  userAgent,
+			RequestID:  req.id,
+		}
+
+		line, _ := json.Marshal(entry)
+		return string(line) + "\n"
+	}
+
+	bytes := "-"
+	if completed.BytesWritten > 0 {
+		bytes = fmt.Sprint(completed.BytesWritten)
+	}
+
+	line := fmt.Sprintf(`%s - - [%s] "%s %s %s" %d %s`,
+		orDash(remoteHost(req.remoteAddr)),
+		completed.Start.Format(commonLogTimeFormat),
+		req.Method(), escapeLogValue(req.Uri()), escapeLogValue(req.httpVersion),
+		completed.StatusCode, bytes)
+
+	if al.Format == CombinedLogFormat {
+		line += fmt.Sprintf(` "%s" "%s"`, orDash(escapeLogValue(referer)), orDash(escapeLogValue(userAgent)))
+	}
+
+	return line + "\n"
+}
+
+// returns the host portion of a remote address, without the port
+func remoteHost(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// escapes quotes, backslashes, and control characters so a value
+// cannot break the structure of a log line
+func escapeLogValue(value string) string {
+	var escaped strings.Builder
+	for _, c := range value {
+		switch {
+		case c == '"' || c == '\\':
+			escaped.WriteRune('\\')
+			escaped.WriteRune(c)
+		case c < 0x20 || c == 0x7f:
+			escaped.WriteString(fmt.Sprintf("\\x%02x", c))
+		default:
+			escaped.WriteRune(c)
+		}
+	}
+	return escaped.String()
+}
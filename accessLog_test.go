@@ -0,0 +1,93 @@
+package simplehttp
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newAccessLogTestRequest() CompletedRequest {
+	uri, _ := url.ParseRequestURI("/index.html?page=2")
+	req := Request{
+		method:      get,
+		uri:         *uri,
+		httpVersion: "HTTP/1.0",
+		headers: headers{
+			"Referer":    "https://example.com/",
+			"User-Agent": `curl/8.0 "quoted"`,
+		},
+		remoteAddr: "127.0.0.1:54321",
+		id:         "f47ac10b-58cc-4372-a567-0e02b2c3d479",
+	}
+
+	start := time.Date(2024, 5, 12, 5, 53, 22, 0, time.UTC)
+	return CompletedRequest{
+		Request:      req,
+		StatusCode:   200,
+		BytesWritten: 2326,
+		Start:        start,
+		Duration:     1500 * time.Microsecond,
+	}
+}
+
+func TestAccessLog_CommonLogFormat(t *testing.T) {
+	al := NewAccessLog(nil, CommonLogFormat)
+	expected := `127.0.0.1 - - [12/May/2024:05:53:22 +0000] "GET /index.html?page=2 HTTP/1.0" 200 2326` + "\n"
+
+	actual := al.format(newAccessLogTestRequest())
+	if actual != expected {
+		t.Fatalf("incorrect line. Expected '%s' | Actual '%s'", expected, actual)
+	}
+}
+
+func TestAccessLog_CombinedLogFormat(t *testing.T) {
+	al := NewAccessLog(nil, CombinedLogFormat)
+	completed := newAccessLogTestRequest()
+	completed.BytesWritten = 0
+	expected := `127.0.0.1 - - [12/May/2024:05:53:22 +0000] "GET /index.html?page=2 HTTP/1.0" 200 - ` +
+		`"https://example.com/" "curl/8.0 \"quoted\""` + "\n"
+
+	actual := al.format(completed)
+	if actual != expected {
+		t.Fatalf("incorrect line. Expected '%s' | Actual '%s'", expected, actual)
+	}
+}
+
+func TestAccessLog_JSONLogFormat(t *testing.T) {
+	var output strings.Builder
+	al := NewAccessLog(&output, JSONLogFormat)
+	al.Hooks().RequestCompleted(newAccessLogTestRequest())
+
+	var entry accessLogEntry
+	err := json.Unmarshal([]byte(output.String()), &entry)
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+
+	expected := accessLogEntry{
+		Time:       "2024-05-12T05:53:22.000Z",
+		RemoteAddr: "127.0.0.1",
+		Method:     "GET",
+		Path:       "/index.html?page=2",
+		Protocol:   "HTTP/1.0",
+		Status:     200,
+		Bytes:      2326,
+		DurationMs: 1.5,
+		Referer:    "https://example.com/",
+		UserAgent:  `curl/8.0 "quoted"`,
+		RequestID:  "f47ac10b-58cc-4372-a567-0e02b2c3d479",
+	}
+
+	if entry != expected {
+		t.Fatalf("incorrect entry. Expected %+v | Actual %+v", expected, entry)
+	}
+}
+
+func TestEscapeLogValue(t *testing.T) {
+	actual := escapeLogValue("a\"b\\c\nd")
+	if actual != `a\"b\\c\x0ad` {
+		t.Fatalf(`incorrect escaping. Expected 'a\"b\\c\x0ad' | Actual '%s'`, actual)
+	}
+}
@@ -0,0 +1,229 @@
+package simplehttp
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+const defaultRealm string = "Restricted"
+
+// Principal identifies the client that made an authenticated request.
+// Callbacks behind a [BasicAuth] or [BearerAuth] can read it using
+// [Request.Principal].
+type Principal struct {
+	// Name is the user name, for Basic authentication, or the subject of
+	// the token, for Bearer authentication.
+	Name string
+	// Scheme is the authentication scheme that was used, either "Basic" or "Bearer".
+	Scheme string
+	// Details holds any additional information about the client, such as
+	// the claims of a token.
+	Details any
+}
+
+type principalKey struct{}
+
+// Returns the [Principal] that authenticated the request. The second return
+// value is false if the request was not authenticated.
+func (r Request) Principal() (Principal, bool) {
+	principal, ok := r.Context().Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+// returns a copy of the request that carries the principal
+func (r Request) withPrincipal(principal Principal) Request {
+	return r.WithContext(context.WithValue(r.Context(), principalKey{}, principal))
+}
+
+// BasicAuth is middleware that requires requests to include a user name and
+// password using HTTP Basic authentication, as described by RFC 7617.
+// A BasicAuth should only be created using the [NewBasicAuth] method to
+// ensure it is properly initialized. It is registered using [Server.Use] to
+// protect every path, or it can wrap the callback of specific paths:
+//
+//	auth := simplehttp.NewBasicAuth("admin", map[string]string{"alice": "s3cret"})
+//	server.Get("/admin", auth.Middleware(admin))
+//
+// Requests without valid credentials receive a 401 Unauthorized response with
+// a WWW-Authenticate header, which prompts browsers to ask for credentials.
+// Basic authentication sends the password with every request, so it should
+// only be used over HTTPS.
+type BasicAuth struct {
+	// Realm describes the protected area to the user. Defaults to "Restricted".
+	Realm string
+	// Users maps user names to their passwords. Passwords are compared in
+	// constant time.
+	Users map[string]string
+	// Validate is called to check the credentials instead of Users, if it is
+	// provided. For example, it can compare a password against a stored hash.
+	// Validate should compare credentials in constant time.
+	Validate func(username string, password string) bool
+}
+
+// Creates and initializes a new [BasicAuth] that accepts the provided
+// user names and passwords.
+func NewBasicAuth(realm string, users map[string]string) *BasicAuth {
+	if realm == "" {
+		realm = defaultRealm
+	}
+
+	return &BasicAuth{
+		Realm: realm,
+		Users: users,
+	}
+}
+
+// Middleware is the [MiddlewareFunc] to be registered on the [Server] using
+// [Server.Use].
+func (b *BasicAuth) Middleware(next CallbackFunc) CallbackFunc {
+	return func(req Request, res *Response) error {
+		username, password, ok := parseBasicCredentials(req)
+		if !ok || !b.checkCredentials(username, password) {
+			res.SetStatus(401)
+			res.headers["WWW-Authenticate"] = fmt.Sprintf(`Basic realm=%s, charset="UTF-8"`, quoteString(b.Realm))
+			return nil
+		}
+
+		return next(req.withPrincipal(Principal{Name: username, Scheme: "Basic"}), res)
+	}
+}
+
+func (b *BasicAuth) checkCredentials(username string, password string) bool {
+	if b.Validate != nil {
+		return b.Validate(username, password)
+	}
+
+	// compare against an empty password for unknown users, so the time taken
+	// does not reveal whether the user exists
+	expected, exists := b.Users[username]
+	matches := constantTimeEqual(password, expected)
+	return exists && matches
+}
+
+// returns the user name and password from a request's Basic Authorization header
+func parseBasicCredentials(req Request) (string, string, bool) {
+	credentials, ok := authorizationCredentials(req, "Basic")
+	if !ok {
+		return "", "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(credentials)
+	if err != nil {
+		return "", "", false
+	}
+
+	return strings.Cut(string(decoded), ":")
+}
+
+// TokenValidator checks a bearer token sent with a request and returns the
+// [Principal] it identifies, or an error if the token is not valid.
+type TokenValidator func(req Request, token string) (Principal, error)
+
+// BearerAuth is middleware that requires requests to include a token using
+// the Bearer authentication scheme, as described by RFC 6750. Tokens are
+// checked by a [TokenValidator], so any kind of token can be used, for example
+// an opaque token looked up in a database or a [JWTValidator].
+// A BearerAuth should only be created using the [NewBearerAuth] method to
+// ensure it is properly initialized. It is registered in the same way as a
+// [BasicAuth].
+//
+// Requests without a token, or with a token the validator rejects, receive a
+// 401 Unauthorized response with a WWW-Authenticate header.
+type BearerAuth struct {
+	// Realm describes the protected area to the client. Defaults to "Restricted".
+	Realm string
+	// Validate checks each token.
+	Validate TokenValidator
+	// Logger receives a [LevelWarn] message with the validator's error
+	// whenever a token is rejected. The error is not sent to the client, since
+	// it may describe the token's contents. If a Logger is not provided,
+	// the errors are discarded.
+	Logger StructuredLogger
+}
+
+// Creates and initializes a new [BearerAuth] that checks tokens using validate.
+func NewBearerAuth(realm string, validate TokenValidator) *BearerAuth {
+	if realm == "" {
+		realm = defaultRealm
+	}
+
+	return &BearerAuth{
+		Realm:    realm,
+		Validate: validate,
+	}
+}
+
+// Middleware is the [MiddlewareFunc] to be registered on the [Server] using
+// [Server.Use].
+func (b *BearerAuth) Middleware(next CallbackFunc) CallbackFunc {
+	return func(req Request, res *Response) error {
+		challenge := "Bearer realm=" + quoteString(b.Realm)
+
+		token, ok := authorizationCredentials(req, "Bearer")
+		if !ok {
+			res.SetStatus(401)
+			res.headers["WWW-Authenticate"] = challenge
+			return nil
+		}
+
+		principal, err := b.Validate(req, token)
+		if err != nil {
+			if b.Logger != nil {
+				b.Logger.Log(LevelWarn, "Rejected a bearer token",
+					LogField{"request_id", req.id}, LogField{"error", err})
+			}
+
+			res.SetStatus(401)
+			res.headers["WWW-Authenticate"] = challenge +
+				`, error="invalid_token", error_description="The access token is invalid"`
+			return nil
+		}
+
+		if principal.Scheme == "" {
+			principal.Scheme = "Bearer"
+		}
+		return next(req.withPrincipal(principal), res)
+	}
+}
+
+// returns the credentials of a request's Authorization header if it uses the
+// provided scheme. Schemes are case-insensitive.
+func authorizationCredentials(req Request, scheme string) (string, bool) {
+	authorization, exists := req.headers.get("Authorization")
+	if !exists {
+		return "", false
+	}
+
+	requestScheme, credentials, found := strings.Cut(strings.TrimSpace(authorization), " ")
+	credentials = strings.TrimSpace(credentials)
+	if !found || !strings.EqualFold(requestScheme, scheme) || credentials == "" {
+		return "", false
+	}
+
+	return credentials, true
+}
+
+// compares two strings in constant time, without revealing their lengths
+func constantTimeEqual(a string, b string) bool {
+	aHash := sha256.Sum256([]byte(a))
+	bHash := sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(aHash[:], bHash[:]) == 1
+}
+
+// formats s as a quoted-string for use in a header parameter. Control
+// characters cannot be sent in a header, so they are removed.
+func quoteString(s string) string {
+	s = strings.Map(func(c rune) rune {
+		if c < 0x20 || c == 0x7f {
+			return -1
+		}
+		return c
+	}, s)
+
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
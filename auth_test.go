@@ -0,0 +1,120 @@
+package simplehttp
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func basicAuthorization(username string, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+func TestBasicAuth(t *testing.T) {
+	auth := NewBasicAuth("admin area", map[string]string{"alice": "s3cret:with:colons"})
+
+	res, next := invokeMiddleware(auth.Middleware, Request{headers: headers{"Authorization": basicAuthorization("alice", "s3cret:with:colons")}})
+	if next == nil || res.statusCode != 200 {
+		t.Fatalf("expected valid credentials to be accepted. Actual status '%d'", res.statusCode)
+	}
+
+	principal, _ := next.Principal()
+	if principal.Name != "alice" || principal.Scheme != "Basic" {
+		t.Fatalf("incorrect principal. Actual '%+v'", principal)
+	}
+
+	invalid := []string{
+		"",
+		basicAuthorization("alice", "wrong"),
+		basicAuthorization("bob", ""),
+		"Basic not-base64!",
+		"Bearer abc",
+	}
+
+	for _, authorization := range invalid {
+		res, next = invokeMiddleware(auth.Middleware, Request{headers: headers{"Authorization": authorization}})
+		if next != nil || res.statusCode != 401 {
+			t.Fatalf("expected '%s' to be rejected. Actual status '%d'", authorization, res.statusCode)
+		}
+
+		if res.headers["WWW-Authenticate"] != `Basic realm="admin area", charset="UTF-8"` {
+			t.Fatalf("incorrect WWW-Authenticate. Actual '%s'", res.headers["WWW-Authenticate"])
+		}
+	}
+}
+
+func TestBasicAuth_Validate(t *testing.T) {
+	auth := NewBasicAuth("", nil)
+	auth.Validate = func(username string, password string) bool {
+		return username == "bob" && password == "hunter2"
+	}
+
+	_, next := invokeMiddleware(auth.Middleware, Request{headers: headers{
+		"Authorization": "basic " + base64.StdEncoding.EncodeToString([]byte("bob:hunter2")),
+	}})
+	if next == nil {
+		t.Fatalf("expected the credentials to be accepted by Validate")
+	}
+
+	res, _ := invokeMiddleware(auth.Middleware, Request{headers: headers{}})
+	if res.headers["WWW-Authenticate"] != `Basic realm="Restricted", charset="UTF-8"` {
+		t.Fatalf("incorrect default realm. Actual '%s'", res.headers["WWW-Authenticate"])
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	auth := NewBearerAuth("api", func(_ Request, token string) (Principal, error) {
+		if token != "valid-token" {
+			return Principal{}, errors.New(`token "expired"`)
+		}
+		return Principal{Name: "service", Details: 42}, nil
+	})
+
+	res, next := invokeMiddleware(auth.Middleware, Request{headers: headers{"Authorization": "Bearer valid-token"}})
+	if next == nil || res.statusCode != 200 {
+		t.Fatalf("expected the token to be accepted. Actual status '%d'", res.statusCode)
+	}
+
+	principal, _ := next.Principal()
+	if principal.Name != "service" || principal.Scheme != "Bearer" || principal.Details != 42 {
+		t.Fatalf("incorrect principal. Actual '%+v'", principal)
+	}
+
+	res, next = invokeMiddleware(auth.Middleware, Request{headers: headers{}})
+	if next != nil || res.statusCode != 401 || res.headers["WWW-Authenticate"] != `Bearer realm="api"` {
+		t.Fatalf("expected a challenge without a token. Actual '%s'", res.headers["WWW-Authenticate"])
+	}
+
+	logger := &recordingStructuredLogger{}
+	auth.Logger = logger
+	res, next = invokeMiddleware(auth.Middleware, Request{headers: headers{"Authorization": "Bearer other-token"}})
+	expected := `Bearer realm="api", error="invalid_token", error_description="The access token is invalid"`
+	if next != nil || res.statusCode != 401 || res.headers["WWW-Authenticate"] != expected {
+		t.Fatalf("incorrect challenge. Expected '%s' | Actual '%s'", expected, res.headers["WWW-Authenticate"])
+	}
+
+	if len(logger.entries) != 1 || logger.entries[0].fields[1].Value.(error).Error() != `token "expired"` {
+		t.Fatalf("expected the validator's error to be logged. Actual %v", logger.entries)
+	}
+}
+
+func TestQuoteString(t *testing.T) {
+	quoted := quoteString("a \"b\" \\c\r\nSet-Cookie: x=1\x7f")
+	expected := `"a \"b\" \\cSet-Cookie: x=1"`
+	if quoted != expected {
+		t.Fatalf("incorrect quoted-string. Expected '%s' | Actual '%s'", expected, quoted)
+	}
+}
+
+func TestRequest_Principal(t *testing.T) {
+	_, ok := Request{}.Principal()
+	if ok {
+		t.Fatalf("expected an unauthenticated request to have no principal")
+	}
+}
+
+func TestConstantTimeEqual(t *testing.T) {
+	if !constantTimeEqual("secret", "secret") || constantTimeEqual("secret", "secret2") || constantTimeEqual("", "x") {
+		t.Fatalf("incorrect comparison")
+	}
+}
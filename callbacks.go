@@ -1,6 +1,9 @@
 package simplehttp
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // Potential errors when dealing with callbacks
 type callbackRuntimeError struct {
@@ -61,11 +64,23 @@ func newCallbackNotRegisteredError(httpMethod uint, path string) error {
 // will automatically return a 500 Internal Server Error response.
 type CallbackFunc = func(Request, *Response) error
 
+// MiddlewareFunc is the function signature that represents middleware to be
+// registered on the [Server] using [Server.Use]. Middleware receives the next
+// [CallbackFunc] in the chain and returns a CallbackFunc that wraps it. The
+// returned CallbackFunc can inspect or modify the [Request] and [*Response]
+// before and after calling next, or respond on its own without calling next.
+// Because a Request is immutable, middleware passes request-scoped values
+// along by calling next with a copy of the Request (see [Request.WithContext]).
+type MiddlewareFunc = func(next CallbackFunc) CallbackFunc
+
 type callbackMap struct {
 	callbacks map[uint]map[string]CallbackFunc
 	// ex. [GET]["/"] = func(...)
 	// ex. [GET]["/login"] = func(...)
 	// ex. [POST]["/login"] = func(...)
+
+	prefixCallbacks map[uint]map[string]CallbackFunc
+	// ex. [GET]["/static"] = func(...) handles "/static" and everything below "/static/"
 }
 
 func newCallbackMap() callbackMap {
@@ -73,9 +88,18 @@ func newCallbackMap() callbackMap {
 	callbacks[get] = make(map[string]CallbackFunc)
 	callbacks[post] = make(map[string]CallbackFunc)
 	callbacks[put] = make(map[string]CallbackFunc)
-	callbacks[delete] = make(map[string]CallbackFunc)
+	callbacks[del] = make(map[string]CallbackFunc)
+	callbacks[options] = make(map[string]CallbackFunc)
+
+	prefixCallbacks := make(map[uint]map[string]CallbackFunc)
+	prefixCallbacks[get] = make(map[string]CallbackFunc)
+	prefixCallbacks[post] = make(map[string]CallbackFunc)
+	prefixCallbacks[put] = make(map[string]CallbackFunc)
+	prefixCallbacks[del] = make(map[string]CallbackFunc)
+	prefixCallbacks[options] = make(map[string]CallbackFunc)
 	return callbackMap{
 		callbacks,
+		prefixCallbacks,
 	}
 }
 
@@ -89,8 +113,69 @@ func (cbm *callbackMap) registerCallback(method uint, path string, callback Call
 	return nil
 }
 
-func (cbm *callbackMap) invokeCallback(method uint, path string, req Request, res *Response) error {
+// registers a callback that handles the prefix itself as well as every path
+// below it. Callbacks registered for an exact path take precedence.
+func (cbm *callbackMap) registerPrefixCallback(method uint, prefix string, callback CallbackFunc) error {
+	prefix = strings.TrimSuffix(prefix, "/")
+	_, exists := cbm.prefixCallbacks[method][prefix]
+	if exists {
+		return newCallbackAlreadyRegisteredError(method, prefix+"/")
+	}
+
+	cbm.prefixCallbacks[method][prefix] = callback
+	return nil
+}
+
+// finds the callback registered for the exact path, falling back
+// to the callback with the longest matching prefix.
+func (cbm *callbackMap) findCallback(method uint, path string) (CallbackFunc, bool) {
 	callback, exists := cbm.callbacks[method][path]
+	if exists {
+		return callback, true
+	}
+
+	prefix, exists := cbm.findPrefix(method, path)
+	if !exists {
+		return nil, false
+	}
+
+	return cbm.prefixCallbacks[method][prefix], true
+}
+
+// finds the longest registered prefix that matches the path
+func (cbm *callbackMap) findPrefix(method uint, path string) (string, bool) {
+	longest := ""
+	found := false
+	for prefix := range cbm.prefixCallbacks[method] {
+		if path != prefix && !strings.HasPrefix(path, prefix+"/") {
+			continue
+		}
+
+		if !found || len(prefix) > len(longest) {
+			longest = prefix
+			found = true
+		}
+	}
+
+	return longest, found
+}
+
+// returns the path of the callback that handles the path, as it was
+// registered. Prefixes end in "/*". Returns an empty string if there is none.
+func (cbm *callbackMap) route(method uint, path string) string {
+	if _, exists := cbm.callbacks[method][path]; exists {
+		return path
+	}
+
+	if prefix, exists := cbm.findPrefix(method, path); exists {
+		return prefix + "/*"
+	}
+
+	return ""
+}
+
+func (cbm *callbackMap) invokeCallback(method uint, path string, req Request, res *Response) error {
+	callback, exists := cbm.findCallback(method, path)
 	if !exists {
 		return newCallbackNotRegisteredError(method, path)
 	}
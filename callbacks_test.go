@@ -9,17 +9,32 @@ func dummyCallback(_ Request, _ *Response) error {
 	return nil
 }
 
+// invokes middleware for req with a callback that records the request it
+// receives. Returns the response, and the request passed to the callback or
+// nil if the middleware responded without invoking it.
+func invokeMiddleware(middleware MiddlewareFunc, req Request) (Response, *Request) {
+	var next *Request
+	callback := middleware(func(req Request, _ *Response) error {
+		next = &req
+		return nil
+	})
+
+	res := newResponse()
+	callback(req, &res)
+	return res, next
+}
+
 func TestRegisterCallback(t *testing.T) {
 	cbm := newCallbackMap()
 	cbm.registerCallback(get, "/get1", dummyCallback)
 	cbm.registerCallback(post, "/post1", dummyCallback)
 	cbm.registerCallback(put, "/put1", dummyCallback)
-	cbm.registerCallback(delete, "/delete1", dummyCallback)
+	cbm.registerCallback(del, "/delete1", dummyCallback)
 
 	cbm.registerCallback(get, "/get2", dummyCallback)
 	cbm.registerCallback(post, "/post2", dummyCallback)
 	cbm.registerCallback(put, "/put2", dummyCallback)
-	cbm.registerCallback(delete, "/delete2", dummyCallback)
+	cbm.registerCallback(del, "/delete2", dummyCallback)
 
 	if len(cbm.callbacks[get]) != 2 {
 		t.Fatalf("expected 2 entries in the 'get' map but found %d", len(cbm.callbacks[get]))
@@ -30,8 +45,8 @@ func TestRegisterCallback(t *testing.T) {
 	if len(cbm.callbacks[put]) != 2 {
 		t.Fatalf("expected 2 entries in the 'put' map but found %d", len(cbm.callbacks[put]))
 	}
-	if len(cbm.callbacks[delete]) != 2 {
-		t.Fatalf("expected 2 entries in the 'delete' map but found %d", len(cbm.callbacks[delete]))
+	if len(cbm.callbacks[del]) != 2 {
+		t.Fatalf("expected 2 entries in the 'delete' map but found %d", len(cbm.callbacks[del]))
 	}
 }
 
@@ -86,3 +101,31 @@ func TestCallbackRuntimeError(t *testing.T) {
 		t.Fatalf("did not receive an error from invoking a callback that returned an error")
 	}
 }
+
+func TestInvokePrefixCallback(t *testing.T) {
+	invoked := ""
+	cbm := newCallbackMap()
+	cbm.registerPrefixCallback(get, "/static/", func(_ Request, _ *Response) error {
+		invoked = "/static"
+		return nil
+	})
+	cbm.registerPrefixCallback(get, "/static/img", func(_ Request, _ *Response) error {
+		invoked = "/static/img"
+		return nil
+	})
+
+	cbm.invokeCallback(get, "/static/css/site.css", Request{}, &Response{})
+	if invoked != "/static" {
+		t.Fatalf("incorrect callback invoked. Expected '/static' | Actual '%s'", invoked)
+	}
+
+	cbm.invokeCallback(get, "/static/img/logo.png", Request{}, &Response{})
+	if invoked != "/static/img" {
+		t.Fatalf("incorrect callback invoked. Expected '/static/img' | Actual '%s'", invoked)
+	}
+
+	err := cbm.invokeCallback(get, "/staticfile", Request{}, &Response{})
+	if err == nil {
+		t.Fatalf("did not receive an error from invoking a path outside of the prefix")
+	}
+}
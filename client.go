@@ -0,0 +1,714 @@
+package simplehttp
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultClientTimeout time.Duration = 30 * time.Second
+const defaultDialTimeout time.Duration = 10 * time.Second
+const defaultMaxRedirects int = 10
+const defaultMaxIdleConnsPerHost int = 2
+const defaultIdleConnTimeout time.Duration = 90 * time.Second
+const defaultMaxResponseBytes uint = 10 * 1024 * 1024 // 10 MB
+
+// the maximum number of interim 1xx responses skipped before a final response
+const maxInterimResponses int = 5
+
+// A Client sends HTTP/1.1 requests to HTTP/1.0 and HTTP/1.1 servers, such as
+// a [Server], and returns their responses as a [Response]. A Client should
+// only be created using the [NewClient] method to ensure it is properly
+// initialized. Requests are built using the methods named after HTTP methods,
+// such as [Client.Get]:
+//
+//	client := simplehttp.NewClient()
+//	res, err := client.Post("http://localhost:8080/users").
+//		Header("Accept", "application/json").
+//		JSON(user).
+//		Send()
+//
+// Connections are kept alive and reused for later requests to the same host
+// when the server allows it. A Client is safe for concurrent use.
+type Client struct {
+	// Timeout is the maximum time a request can take, including following
+	// redirects and reading the response's body. If 0, requests do not time
+	// out unless their context does. Defaults to 30 seconds.
+	Timeout time.Duration
+	// DialTimeout is the maximum time opening a connection can take.
+	// Defaults to 10 seconds.
+	DialTimeout time.Duration
+	// MaxRedirects is the maximum number of redirects followed for a request.
+	// If 0, redirects are not followed and the redirect response is returned.
+	// Defaults to 10.
+	MaxRedirects int
+	// KeepAlive determines whether connections are reused for later requests.
+	// Defaults to true.
+	KeepAlive bool
+	// MaxIdleConnsPerHost is the maximum number of idle connections kept for
+	// each host. Defaults to 2.
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout is how long an idle connection is kept before it is
+	// closed. Defaults to 90 seconds.
+	IdleConnTimeout time.Duration
+	// MaxResponseBytes is the maximum number of bytes a response's body can be
+	// before the request fails. Defaults to 10 MB.
+	MaxResponseBytes uint
+	// TLSConfig is the configuration used for https requests. If nil, the
+	// default configuration is used.
+	TLSConfig *tls.Config
+	mutex     sync.Mutex
+	idle      map[string][]*clientConn
+}
+
+// Creates and initializes a new [Client].
+func NewClient() *Client {
+	return &Client{
+		Timeout:             defaultClientTimeout,
+		DialTimeout:         defaultDialTimeout,
+		MaxRedirects:        defaultMaxRedirects,
+		KeepAlive:           true,
+		MaxIdleConnsPerHost: defaultMaxIdleConnsPerHost,
+		IdleConnTimeout:     defaultIdleConnTimeout,
+		MaxResponseBytes:    defaultMaxResponseBytes,
+		idle:                make(map[string][]*clientConn),
+	}
+}
+
+// a connection that can be reused for several requests
+type clientConn struct {
+	conn      net.Conn
+	reader    *bufio.Reader
+	idleSince time.Time
+}
+
+// A RequestBuilder builds a request to be sent by a [Client]. It is created
+// by a method such as [Client.Get], and its methods can be chained together
+// before calling [RequestBuilder.Send].
+type RequestBuilder struct {
+	client  *Client
+	method  string
+	url     string
+	headers headers
+	query   url.Values
+	body    string
+	timeout time.Duration
+	ctx     context.Context
+	err     error
+}
+
+// Starts building a GET request to the provided url.
+func (c *Client) Get(url string) *RequestBuilder {
+	return c.NewRequest("GET", url)
+}
+
+// Starts building a POST request to the provided url.
+func (c *Client) Post(url string) *RequestBuilder {
+	return c.NewRequest("POST", url)
+}
+
+// Starts building a PUT request to the provided url.
+func (c *Client) Put(url string) *RequestBuilder {
+	return c.NewRequest("PUT", url)
+}
+
+// Starts building a DELETE request to the provided url.
+func (c *Client) Delete(url string) *RequestBuilder {
+	return c.NewRequest("DELETE", url)
+}
+
+// Starts building an OPTIONS request to the provided url.
+func (c *Client) Options(url string) *RequestBuilder {
+	return c.NewRequest("OPTIONS", url)
+}
+
+// Starts building a request with the provided method, such as "GET", to the
+// provided url. The url must be an absolute http or https URL.
+func (c *Client) NewRequest(method string, url string) *RequestBuilder {
+	return &RequestBuilder{
+		client:  c,
+		method:  method,
+		url:     url,
+		headers: make(headers),
+		query:   make(map[string][]string),
+	}
+}
+
+// Closes the connections that are being kept alive for later requests.
+func (c *Client) CloseIdleConnections() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for key, conns := range c.idle {
+		for _, cc := range conns {
+			cc.conn.Close()
+		}
+		delete(c.idle, key)
+	}
+}
+
+// Sets a header on the request, replacing any previous value. If the key or
+// value contains a character that is not allowed in a header, such as a line
+// break, the error is returned by [RequestBuilder.Send].
+func (b *RequestBuilder) Header(key string, value string) *RequestBuilder {
+	b.headers[key] = value
+	return b
+}
+
+// Adds a parameter to the request's URL.
+func (b *RequestBuilder) Query(key string, value string) *RequestBuilder {
+	b.query.Add(key, value)
+	return b
+}
+
+// Sets the request's Authorization header to use HTTP Basic authentication.
+func (b *RequestBuilder) BasicAuth(username string, password string) *RequestBuilder {
+	credentials := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	return b.Header("Authorization", "Basic "+credentials)
+}
+
+// Sets the request's body.
+func (b *RequestBuilder) Body(body string) *RequestBuilder {
+	b.body = body
+	return b
+}
+
+// Sets the request's body to obj marshalled to JSON using [json.Marshal],
+// and sets the Content-Type header to "application/json". If obj could not
+// be marshalled, the error is returned by [RequestBuilder.Send].
+func (b *RequestBuilder) JSON(obj any) *RequestBuilder {
+	marshalled, err := json.Marshal(obj)
+	if err != nil {
+		b.err = fmt.Errorf("error while marshalling object: %s", err)
+		return b
+	}
+
+	b.body = string(marshalled)
+	return b.Header("Content-Type", "application/json")
+}
+
+// Sets the maximum time the request can take, replacing [Client.Timeout].
+func (b *RequestBuilder) Timeout(timeout time.Duration) *RequestBuilder {
+	b.timeout = timeout
+	return b
+}
+
+// Sets the request's context. The request is cancelled if ctx is done, and
+// if ctx carries a [SpanContext], such as the context of a request handled
+// by a [Server] with a Tracer, the trace is continued by the request.
+func (b *RequestBuilder) Context(ctx context.Context) *RequestBuilder {
+	b.ctx = ctx
+	return b
+}
+
+// Sends the request and returns the server's response, following any
+// redirects. An error is returned if the request could not be built or sent,
+// such as when its URL or headers contain control characters, the response
+// was invalid or too large, the request timed out, or more than
+// [Client.MaxRedirects] redirects were followed.
+func (b *RequestBuilder) Send() (Response, error) {
+	if b.err != nil {
+		return Response{}, b.err
+	}
+
+	method, err := parseHttpMethod(b.method)
+	if err != nil {
+		return Response{}, fmt.Errorf("unable to send a %s request: %v", b.method, err)
+	}
+
+	target, err := url.Parse(b.url)
+	if err != nil {
+		return Response{}, err
+	}
+
+	if len(b.query) > 0 {
+		query := target.Query()
+		for key, values := range b.query {
+			for _, value := range values {
+				query.Add(key, value)
+			}
+		}
+		target.RawQuery = query.Encode()
+	}
+
+	ctx := b.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	timeout := b.client.Timeout
+	if b.timeout > 0 {
+		timeout = b.timeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	reqHeaders := make(headers)
+	for key, value := range b.headers {
+		reqHeaders[key] = value
+	}
+	InjectTraceContext(ctx, reqHeaders)
+
+	body := b.body
+	for redirects := 0; ; redirects++ {
+		res, err := b.client.roundTrip(ctx, method, target, reqHeaders, body)
+		if err != nil {
+			return Response{}, err
+		}
+
+		location, exists := res.headers.get("Location")
+		if !isRedirect(res.statusCode) || !exists || b.client.MaxRedirects <= 0 {
+			return res, nil
+		}
+
+		if redirects >= b.client.MaxRedirects {
+			return Response{}, fmt.Errorf("stopped after %d redirects", b.client.MaxRedirects)
+		}
+
+		next, err := target.Parse(location)
+		if err != nil {
+			return Response{}, fmt.Errorf("invalid redirect location `%s`: %v", location, err)
+		}
+
+		if !keepsCredentials(target, next) {
+			deleteHeader(reqHeaders, "Authorization")
+			deleteHeader(reqHeaders, "Proxy-Authorization")
+			deleteHeader(reqHeaders, "Cookie")
+		}
+
+		// like browsers, change to a GET request unless the redirect requires the method is kept
+		if res.statusCode == StatusSeeOther || (method == post && res.statusCode != StatusTemporaryRedirect &&
+			res.statusCode != StatusPermanentRedirect) {
+			method = get
+			body = ""
+			deleteHeader(reqHeaders, "Content-Type")
+		}
+
+		target = next
+	}
+}
+
+// credentials are only sent to the host they were intended for, and are never
+// sent unencrypted when they were intended for an https URL
+func keepsCredentials(from *url.URL, to *url.URL) bool {
+	if from.Host != to.Host {
+		return false
+	}
+	return from.Scheme != "https" || to.Scheme == "https"
+}
+
+func isRedirect(status uint) bool {
+	switch status {
+	case StatusMovedPermanently, StatusFound, StatusSeeOther, StatusTemporaryRedirect, StatusPermanentRedirect:
+		return true
+	default:
+		return false
+	}
+}
+
+// deletes a header, ignoring the case of its key
+func deleteHeader(h headers, key string) {
+	for k := range h {
+		if strings.EqualFold(k, key) {
+			delete(h, k)
+		}
+	}
+}
+
+// sends a single request over a new or idle connection and reads its response
+func (c *Client) roundTrip(ctx context.Context, method uint, target *url.URL, reqHeaders headers, body string) (Response, error) {
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return Response{}, fmt.Errorf("unsupported URL scheme `%s`", target.Scheme)
+	}
+
+	if target.Hostname() == "" {
+		return Response{}, fmt.Errorf("the URL `%s` does not have a host", target.String())
+	}
+
+	req := Request{
+		method:      method,
+		uri:         url.URL{Path: target.Path, RawPath: target.RawPath, RawQuery: target.RawQuery},
+		httpVersion: "HTTP/1.1",
+		headers:     make(headers),
+		body:        body,
+	}
+	if req.uri.Path == "" {
+		req.uri.Path = "/"
+	}
+
+	for key, value := range reqHeaders {
+		req.headers[key] = value
+	}
+	req.headers["Host"] = target.Host
+	if _, exists := req.headers.get("User-Agent"); !exists {
+		req.headers["User-Agent"] = "simplehttp"
+	}
+	if body != "" || method == post || method == put {
+		req.headers["Content-Length"] = strconv.Itoa(len(body))
+	}
+	if !c.KeepAlive {
+		req.headers["Connection"] = "close"
+	}
+
+	err := validateRequestHead(req)
+	if err != nil {
+		return Response{}, err
+	}
+
+	key := target.Scheme + "://" + hostPort(target)
+	for attempt := 0; ; attempt++ {
+		cc, reused, err := c.getConn(ctx, key, target)
+		if err != nil {
+			return Response{}, err
+		}
+
+		res, reusable, err := c.exchange(ctx, cc, req)
+		if err == nil {
+			if reusable && c.KeepAlive {
+				c.putConn(key, cc)
+			} else {
+				cc.conn.Close()
+			}
+			return res, nil
+		}
+
+		cc.conn.Close()
+		if ctx.Err() != nil {
+			return Response{}, fmt.Errorf("request to `%s` failed: %w", target.String(), ctx.Err())
+		}
+
+		// the server may have closed an idle connection just before it was
+		// reused, so requests that can safely be repeated are tried again
+		if !reused || method == post || attempt > 0 {
+			return Response{}, fmt.Errorf("request to `%s` failed: %v", target.String(), err)
+		}
+	}
+}
+
+// checks that the request line and headers do not contain characters that
+// would change the meaning of the request, such as line breaks
+func validateRequestHead(req Request) error {
+	invalidInTarget := func(c rune) bool { return c <= ' ' || c == 0x7f }
+	if strings.ContainsFunc(req.uri.String(), invalidInTarget) {
+		return fmt.Errorf("the request target %q contains an invalid character", req.uri.String())
+	}
+
+	for key, value := range req.headers {
+		if key == "" || strings.ContainsFunc(key, func(c rune) bool { return invalidInTarget(c) || c == ':' }) {
+			return fmt.Errorf("the header key %q contains an invalid character", key)
+		}
+
+		if strings.ContainsFunc(value, func(c rune) bool { return (c < ' ' && c != '\t') || c == 0x7f }) {
+			return fmt.Errorf("the value of the %s header contains an invalid character", key)
+		}
+	}
+	return nil
+}
+
+// writes the request to the connection and reads the response. Returns
+// whether the connection can be reused for another request.
+func (c *Client) exchange(ctx context.Context, cc *clientConn, req Request) (Response, bool, error) {
+	deadline, _ := ctx.Deadline()
+	cc.conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() {
+		cc.conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	_, err := io.WriteString(cc.conn, req.String())
+	if err != nil {
+		return Response{}, false, err
+	}
+
+	return readResponse(cc.reader, c.MaxResponseBytes)
+}
+
+// returns an idle connection to the host, or opens a new one
+func (c *Client) getConn(ctx context.Context, key string, target *url.URL) (*clientConn, bool, error) {
+	c.mutex.Lock()
+	for len(c.idle[key]) > 0 {
+		conns := c.idle[key]
+		cc := conns[len(conns)-1]
+		c.idle[key] = conns[:len(conns)-1]
+
+		if time.Since(cc.idleSince) < c.IdleConnTimeout {
+			c.mutex.Unlock()
+			return cc, true, nil
+		}
+		cc.conn.Close()
+	}
+	c.mutex.Unlock()
+
+	dialer := &net.Dialer{Timeout: c.DialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", hostPort(target))
+	if err != nil {
+		return nil, false, err
+	}
+
+	if target.Scheme == "https" {
+		config := &tls.Config{}
+		if c.TLSConfig != nil {
+			config = c.TLSConfig.Clone()
+		}
+		if config.ServerName == "" {
+			config.ServerName = target.Hostname()
+		}
+
+		tlsConn := tls.Client(conn, config)
+		err = tlsConn.HandshakeContext(ctx)
+		if err != nil {
+			conn.Close()
+			return nil, false, err
+		}
+		conn = tlsConn
+	}
+
+	return &clientConn{conn: conn, reader: bufio.NewReader(conn)}, false, nil
+}
+
+// keeps a connection for a later request to the same host
+func (c *Client) putConn(key string, cc *clientConn) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.idle == nil {
+		c.idle = make(map[string][]*clientConn)
+	}
+
+	if len(c.idle[key]) >= c.MaxIdleConnsPerHost {
+		cc.conn.Close()
+		return
+	}
+
+	cc.idleSince = time.Now()
+	c.idle[key] = append(c.idle[key], cc)
+}
+
+// returns the host and port of a URL, using the scheme's default port
+// if the URL does not have one
+func hostPort(target *url.URL) string {
+	port := target.Port()
+	if port == "" {
+		port = "80"
+		if target.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(target.Hostname(), port)
+}
+
+// reads a response from reader, skipping up to maxInterimResponses interim 1xx
+// responses. Returns whether the connection can be reused for another request.
+func readResponse(reader *bufio.Reader, maxBytes uint) (Response, bool, error) {
+	for interim := 0; ; interim++ {
+		head, err := readResponseHead(reader, maxBytes)
+		if err != nil {
+			return Response{}, false, err
+		}
+
+		res, err := parseResponseHead(head)
+		if err != nil {
+			return Response{}, false, err
+		}
+
+		if res.statusCode < 200 && res.statusCode != StatusSwitchingProtocols {
+			if interim >= maxInterimResponses {
+				return Response{}, false, fmt.Errorf("received more than %d interim responses", maxInterimResponses)
+			}
+			continue
+		}
+
+		delimited, err := readResponseBody(reader, &res, maxBytes)
+		if err != nil {
+			return Response{}, false, err
+		}
+
+		return res, delimited && keepsAlive(res), nil
+	}
+}
+
+// reads the status line and headers of a response, up to the blank line
+// that separates them from the body
+func readResponseHead(reader *bufio.Reader, maxBytes uint) (string, error) {
+	var head strings.Builder
+	for !strings.HasSuffix(head.String(), doubleLineEnd) {
+		line, err := readLimitedLine(reader, maxBytes-uint(head.Len()))
+		if errors.Is(err, errLineTooLong) {
+			return "", fmt.Errorf("response headers exceeded the maximum response size")
+		}
+		if err != nil {
+			return "", err
+		}
+
+		// accept bare line feeds, which some servers send
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r") + lineEnd
+		head.WriteString(line)
+	}
+	return head.String(), nil
+}
+
+var errLineTooLong = errors.New("line exceeded the maximum length")
+
+// reads a line ending in a line feed from reader, returning errLineTooLong
+// as soon as the line is longer than maxBytes
+func readLimitedLine(reader *bufio.Reader, maxBytes uint) (string, error) {
+	var line []byte
+	for {
+		fragment, err := reader.ReadSlice('\n')
+		line = append(line, fragment...)
+		if uint(len(line)) > maxBytes {
+			return "", errLineTooLong
+		}
+
+		if err != bufio.ErrBufferFull {
+			return string(line), err
+		}
+	}
+}
+
+// reads the body of a response, using its Content-Length or
+// Transfer-Encoding headers to find where it ends. Returns whether the end of
+// the body was known, rather than found by the server closing the connection.
+// After a 101 Switching Protocols response, the connection no longer uses
+// HTTP, so the end of the body is never known.
+func readResponseBody(reader *bufio.Reader, res *Response, maxBytes uint) (bool, error) {
+	if res.statusCode == StatusSwitchingProtocols {
+		return false, nil
+	}
+
+	if res.statusCode == StatusNoContent || res.statusCode == StatusNotModified {
+		return true, nil
+	}
+
+	transferEncoding, _ := res.headers.get("Transfer-Encoding")
+	if hasToken(transferEncoding, "chunked") {
+		body, err := readChunkedBody(reader, maxBytes)
+		if err != nil {
+			return false, err
+		}
+
+		deleteHeader(res.headers, "Transfer-Encoding")
+		res.headers["Content-Length"] = strconv.Itoa(len(body))
+		res.body = body
+		return true, nil
+	}
+
+	if contentLengthStr, exists := res.headers.get("Content-Length"); exists {
+		contentLength, err := strconv.ParseUint(contentLengthStr, 10, 0)
+		if err != nil {
+			return false, &invalidMessage{fmt.Sprintf(
+				"invalid value in Content-Length header: `%s`", contentLengthStr)}
+		}
+
+		if uint(contentLength) > maxBytes {
+			return false, fmt.Errorf("response body exceeded the maximum response size")
+		}
+
+		body := make([]byte, contentLength)
+		_, err = io.ReadFull(reader, body)
+		if err != nil {
+			return false, err
+		}
+
+		res.body = string(body)
+		return true, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(reader, int64(maxBytes)+1))
+	if err != nil {
+		return false, err
+	}
+
+	if uint(len(body)) > maxBytes {
+		return false, fmt.Errorf("response body exceeded the maximum response size")
+	}
+
+	res.body = string(body)
+	return false, nil
+}
+
+// reads a body sent with the chunked transfer coding, see RFC 9112 Section 7.1
+func readChunkedBody(reader *bufio.Reader, maxBytes uint) (string, error) {
+	var body strings.Builder
+	tooLarge := fmt.Errorf("response body exceeded the maximum response size")
+	for {
+		line, err := readLimitedLine(reader, maxBytes)
+		if errors.Is(err, errLineTooLong) {
+			return "", tooLarge
+		}
+		if err != nil {
+			return "", err
+		}
+
+		sizeStr, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseUint(sizeStr, 16, 63)
+		if err != nil {
+			return "", &invalidMessage{fmt.Sprintf("invalid chunk size: `%s`", sizeStr)}
+		}
+
+		if size == 0 {
+			break
+		}
+
+		if uint(body.Len())+uint(size) > maxBytes {
+			return "", tooLarge
+		}
+
+		chunk := make([]byte, size+2)
+		_, err = io.ReadFull(reader, chunk)
+		if err != nil {
+			return "", err
+		}
+
+		if string(chunk[size:]) != lineEnd {
+			return "", &invalidMessage{"chunk was not followed by a line-end"}
+		}
+		body.Write(chunk[:size])
+	}
+
+	// skip any trailer fields, up to the final blank line
+	trailerBytes := uint(0)
+	for {
+		line, err := readLimitedLine(reader, maxBytes-trailerBytes)
+		if errors.Is(err, errLineTooLong) {
+			return "", tooLarge
+		}
+		if err != nil {
+			return "", err
+		}
+		trailerBytes += uint(len(line))
+
+		if strings.TrimSpace(line) == "" {
+			return body.String(), nil
+		}
+	}
+}
+
+// checks if the server allows the connection to be reused after a response.
+// HTTP/1.1 connections are persistent unless closed, and HTTP/1.0
+// connections are only persistent if the server asks to keep them alive.
+func keepsAlive(res Response) bool {
+	connection, _ := res.headers.get("Connection")
+	if hasToken(connection, "close") {
+		return false
+	}
+
+	if res.httpVersion == "HTTP/1.0" {
+		return hasToken(connection, "keep-alive")
+	}
+	return true
+}
@@ -0,0 +1,363 @@
+package simplehttp
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// serves the Server on a local port and returns its base URL
+func listenTestServer(t *testing.T, server *Server) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.handleConnection(conn)
+		}
+	}()
+
+	return "http://" + listener.Addr().String()
+}
+
+func TestClient_Get(t *testing.T) {
+	server := NewServer(0)
+	server.Get("/hello", func(req Request, res *Response) error {
+		res.SetStatus(StatusAccepted)
+		res.SetText("Hello, " + req.Parameters()["name"][0] + " from " + req.Headers()["X-Client"])
+		return nil
+	})
+	baseUrl := listenTestServer(t, &server)
+
+	res, err := NewClient().Get(baseUrl+"/hello").
+		Query("name", "alice").
+		Header("X-Client", "tests").
+		Send()
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+
+	if res.StatusCode() != 202 || res.ReasonPhrase() != "Accepted" {
+		t.Fatalf("incorrect status. Expected '202 Accepted' | Actual '%d %s'", res.StatusCode(), res.ReasonPhrase())
+	}
+
+	if res.Body() != "Hello, alice from tests" || res.Headers()["Content-Type"] != "text/plain" {
+		t.Fatalf("incorrect response. Actual '%s' %v", res.Body(), res.Headers())
+	}
+}
+
+func TestClient_PostJSON(t *testing.T) {
+	server := NewServer(0)
+	server.Post("/users", func(req Request, res *Response) error {
+		contentType, body, _ := strings.Cut(req.Headers()["Content-Type"]+"|"+req.Body(), "|")
+		return res.SetJson(map[string]string{"contentType": contentType, "body": body})
+	})
+	baseUrl := listenTestServer(t, &server)
+
+	res, err := NewClient().Post(baseUrl + "/users").JSON(map[string]string{"name": "alice"}).Send()
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+
+	expected := `{"body":"{\"name\":\"alice\"}","contentType":"application/json"}`
+	if res.Body() != expected {
+		t.Fatalf("incorrect body. Expected '%s' | Actual '%s'", expected, res.Body())
+	}
+
+	_, err = NewClient().Post(baseUrl + "/users").JSON(make(chan int)).Send()
+	if err == nil {
+		t.Fatalf("expected an error for a body that cannot be marshalled")
+	}
+}
+
+func TestClient_Redirects(t *testing.T) {
+	server := NewServer(0)
+	server.Post("/old", func(req Request, res *Response) error {
+		return res.Redirect("/new?from=old", StatusFound)
+	})
+	server.Post("/moved", func(req Request, res *Response) error {
+		return res.Redirect("/new", StatusTemporaryRedirect)
+	})
+	server.Get("/new", func(req Request, res *Response) error {
+		res.SetText("GET " + req.RawParameters() + " " + req.Body())
+		return nil
+	})
+	server.Post("/new", func(req Request, res *Response) error {
+		res.SetText("POST " + req.Body())
+		return nil
+	})
+	server.Get("/loop", func(req Request, res *Response) error {
+		return res.Redirect("/loop", StatusMovedPermanently)
+	})
+	baseUrl := listenTestServer(t, &server)
+	client := NewClient()
+
+	res, err := client.Post(baseUrl + "/old").Body("data").Send()
+	if err != nil || res.Body() != "GET from=old " {
+		t.Fatalf("expected a 302 to change the method to GET. Actual '%s' (%v)", res.Body(), err)
+	}
+
+	res, err = client.Post(baseUrl + "/moved").Body("data").Send()
+	if err != nil || res.Body() != "POST data" {
+		t.Fatalf("expected a 307 to keep the method and body. Actual '%s' (%v)", res.Body(), err)
+	}
+
+	_, err = client.Get(baseUrl + "/loop").Send()
+	if err == nil || !strings.Contains(err.Error(), "10 redirects") {
+		t.Fatalf("expected an error after too many redirects. Actual %v", err)
+	}
+
+	client.MaxRedirects = 0
+	res, err = client.Get(baseUrl + "/loop").Send()
+	if err != nil || res.StatusCode() != 301 || res.Headers()["Location"] != "/loop" {
+		t.Fatalf("expected the redirect to be returned. Actual %d %v (%v)", res.StatusCode(), res.Headers(), err)
+	}
+}
+
+func TestClient_RedirectStripsCredentials(t *testing.T) {
+	other := NewServer(0)
+	other.Get("/", func(req Request, res *Response) error {
+		authorization, _ := req.headers.get("Authorization")
+		proxyAuthorization, _ := req.headers.get("Proxy-Authorization")
+		cookie, _ := req.headers.get("Cookie")
+		res.SetText(authorization + proxyAuthorization + cookie)
+		return nil
+	})
+	otherUrl := listenTestServer(t, &other)
+
+	server := NewServer(0)
+	server.Get("/", func(req Request, res *Response) error {
+		return res.Redirect(otherUrl+"/", StatusFound)
+	})
+	baseUrl := listenTestServer(t, &server)
+
+	res, err := NewClient().Get(baseUrl+"/").
+		Header("Authorization", "Bearer token").
+		Header("Proxy-Authorization", "Basic cHJveHk=").
+		Header("Cookie", "session=abc").
+		Send()
+	if err != nil || res.Body() != "" {
+		t.Fatalf("expected credentials not to be sent to another host. Actual '%s' (%v)", res.Body(), err)
+	}
+}
+
+func TestClient_Timeout(t *testing.T) {
+	server := NewServer(0)
+	server.Get("/slow", func(req Request, res *Response) error {
+		time.Sleep(500 * time.Millisecond)
+		return nil
+	})
+	baseUrl := listenTestServer(t, &server)
+
+	start := time.Now()
+	_, err := NewClient().Get(baseUrl + "/slow").Timeout(50 * time.Millisecond).Send()
+	if err == nil || time.Since(start) > 400*time.Millisecond {
+		t.Fatalf("expected the request to time out. Actual %v after %v", err, time.Since(start))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = NewClient().Get(baseUrl + "/slow").Context(ctx).Send()
+	if err == nil {
+		t.Fatalf("expected a cancelled request to fail")
+	}
+}
+
+func TestClient_KeepAliveAndChunked(t *testing.T) {
+	var connections atomic.Int32
+	httpServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("chunk one, "))
+		w.(http.Flusher).Flush()
+		w.Write([]byte("chunk two"))
+	}))
+	httpServer.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	httpServer.Start()
+	defer httpServer.Close()
+
+	client := NewClient()
+	defer client.CloseIdleConnections()
+	for i := 0; i < 3; i++ {
+		res, err := client.Get(httpServer.URL).Send()
+		if err != nil {
+			t.Fatalf("did not expect an error but received: %v", err)
+		}
+
+		if res.Body() != "chunk one, chunk two" || res.Headers()["Content-Length"] != "20" {
+			t.Fatalf("incorrect chunked body. Actual '%s' %v", res.Body(), res.Headers())
+		}
+	}
+
+	if connections.Load() != 1 {
+		t.Fatalf("expected the connection to be reused. Actual %d connections", connections.Load())
+	}
+
+	client.KeepAlive = false
+	client.CloseIdleConnections()
+	client.Get(httpServer.URL).Send()
+	client.Get(httpServer.URL).Send()
+	if connections.Load() != 3 {
+		t.Fatalf("expected a new connection for every request. Actual %d connections", connections.Load())
+	}
+}
+
+func TestClient_InjectsTraceContext(t *testing.T) {
+	server := NewServer(0)
+	server.Get("/", func(req Request, res *Response) error {
+		res.SetText(req.Headers()["traceparent"])
+		return nil
+	})
+	baseUrl := listenTestServer(t, &server)
+
+	parent := NewSpanContext(SpanContext{})
+	ctx := ContextWithSpanContext(context.Background(), parent)
+	res, err := NewClient().Get(baseUrl + "/").Context(ctx).Send()
+	if err != nil || res.Body() != parent.TraceParent() {
+		t.Fatalf("incorrect traceparent. Expected '%s' | Actual '%s' (%v)", parent.TraceParent(), res.Body(), err)
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	client := NewClient()
+
+	tests := []*RequestBuilder{
+		client.NewRequest("PATCH", "http://localhost/"),
+		client.Get("ftp://localhost/"),
+		client.Get("/relative"),
+		client.Get("http://127.0.0.1:1/"),
+	}
+
+	for _, test := range tests {
+		_, err := test.Send()
+		if err == nil {
+			t.Fatalf("expected an error for %s %s", test.method, test.url)
+		}
+	}
+}
+
+func TestClient_InvalidRequests(t *testing.T) {
+	server := NewServer(0)
+	server.Get("/", func(req Request, res *Response) error {
+		res.SetText("hello")
+		return nil
+	})
+	baseUrl := listenTestServer(t, &server)
+	client := NewClient()
+
+	tests := []*RequestBuilder{
+		client.Get(baseUrl+"/").Header("X-Test", "value\r\nInjected: true"),
+		client.Get(baseUrl+"/").Header("X-Test\r\nInjected", "true"),
+		client.Get(baseUrl+"/").Header("Bad Key", "value"),
+		client.Get(baseUrl + "/?name=alice smith"),
+	}
+
+	for _, test := range tests {
+		_, err := test.Send()
+		if err == nil || !strings.Contains(err.Error(), "invalid character") {
+			t.Fatalf("expected an error for invalid characters in %s %v. Actual %v", test.url, test.headers, err)
+		}
+	}
+
+	res, err := client.Get(baseUrl+"/").Query("name", "alice smith\r\n").Header("X-Test", "a\tb").Send()
+	if err != nil || res.Body() != "hello" {
+		t.Fatalf("expected a valid request to be sent. Actual '%s' (%v)", res.Body(), err)
+	}
+}
+
+func TestKeepsCredentials(t *testing.T) {
+	tests := []struct {
+		from     string
+		to       string
+		expected bool
+	}{
+		{"https://example.com/a", "https://example.com/b", true},
+		{"http://example.com/a", "https://example.com/b", true},
+		{"https://example.com/a", "http://example.com/b", false},
+		{"https://example.com/a", "https://other.com/b", false},
+		{"http://example.com/a", "http://example.com:8080/b", false},
+	}
+
+	for _, test := range tests {
+		from, _ := url.Parse(test.from)
+		to, _ := url.Parse(test.to)
+		if actual := keepsCredentials(from, to); actual != test.expected {
+			t.Fatalf("incorrect result for %s -> %s. Expected '%t' | Actual '%t'", test.from, test.to, test.expected, actual)
+		}
+	}
+}
+
+func TestReadResponse(t *testing.T) {
+	raw := "HTTP/1.1 100 Continue\r\n\r\n" +
+		"HTTP/1.0 200 OK\r\nConnection: keep-alive\nContent-Length: 5\r\n\r\nhello" +
+		"HTTP/1.0 404 Not Found\r\n\r\nuntil the end"
+	reader := bufio.NewReader(strings.NewReader(raw))
+
+	res, reusable, err := readResponse(reader, 1024)
+	if err != nil || res.statusCode != 200 || res.body != "hello" || !reusable {
+		t.Fatalf("incorrect response. Actual %d '%s' %t (%v)", res.statusCode, res.body, reusable, err)
+	}
+
+	res, reusable, err = readResponse(reader, 1024)
+	if err != nil || res.statusCode != 404 || res.body != "until the end" || reusable {
+		t.Fatalf("incorrect response. Actual %d '%s' %t (%v)", res.statusCode, res.body, reusable, err)
+	}
+
+	_, _, err = readResponse(bufio.NewReader(strings.NewReader("HTTP/1.1 200 OK\r\nContent-Length: 10\r\n\r\n")), 5)
+	if err == nil {
+		t.Fatalf("expected an error for a body larger than the maximum")
+	}
+
+	res, reusable, err = readResponse(bufio.NewReader(strings.NewReader(
+		"HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")), 1024)
+	if err != nil || res.statusCode != 101 || reusable {
+		t.Fatalf("expected a connection that switched protocols not to be reused. Actual %d %t (%v)",
+			res.statusCode, reusable, err)
+	}
+}
+
+func TestReadResponse_InterimResponses(t *testing.T) {
+	interim := strings.Repeat("HTTP/1.1 102 Processing\r\n\r\n", maxInterimResponses)
+	final := "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok"
+
+	res, _, err := readResponse(bufio.NewReader(strings.NewReader(interim+final)), 1024)
+	if err != nil || res.statusCode != 200 {
+		t.Fatalf("expected the interim responses to be skipped. Actual %d (%v)", res.statusCode, err)
+	}
+
+	_, _, err = readResponse(bufio.NewReader(strings.NewReader(interim+"HTTP/1.1 102 Processing\r\n\r\n"+final)), 1024)
+	if err == nil {
+		t.Fatalf("expected an error after too many interim responses")
+	}
+}
+
+func TestReadResponse_LongLines(t *testing.T) {
+	tests := []string{
+		"HTTP/1.1 200 OK\r\nX-Long: " + strings.Repeat("a", 10000),
+		"HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n" + strings.Repeat("0", 10000),
+		"HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n0\r\nX-Trailer: " + strings.Repeat("a", 10000),
+	}
+
+	for _, raw := range tests {
+		_, _, err := readResponse(bufio.NewReader(strings.NewReader(raw)), 1024)
+		if err == nil || !strings.Contains(err.Error(), "maximum response size") {
+			t.Fatalf("expected an error for a line longer than the maximum. Actual %v", err)
+		}
+	}
+}
@@ -77,6 +77,12 @@ func main() {
 		return nil
 	})
 
+	// serves every file in ./files below /static, ex. /static/index.html
+	_, err := server.Static("/static", "./files")
+	if err != nil {
+		fmt.Println(err)
+	}
+
 	server.Post("/", func(req simplehttp.Request, res *simplehttp.Response) error {
 		fmt.Println("we're in the POST / callback!")
 
@@ -88,7 +94,7 @@ func main() {
 		return fmt.Errorf("i am an error within a user callback")
 	})
 
-	err := server.Start()
+	err = server.Start()
 	if err != nil {
 		fmt.Println("There was an error starting the server:", err)
 	}
@@ -0,0 +1,251 @@
+package simplehttp
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const defaultCompressionMinSize int = 1024
+
+// An EncoderFunc returns a writer that compresses the data written to it and
+// writes the result to w. Closing the returned writer must write any
+// remaining data to w, but must not close w.
+type EncoderFunc = func(w io.Writer) (io.WriteCloser, error)
+
+// A Compressor compresses response bodies using a content-coding negotiated
+// with the client's Accept-Encoding header. A Compressor should only be
+// created using the [NewCompressor] method to ensure it is properly
+// initialized, and is enabled by assigning it to [Server.Compressor].
+//
+// The gzip and deflate codings are registered by default. Additional codings,
+// such as br, can be added using [Compressor.RegisterEncoder]. When the client
+// accepts several codings with equal preference, the coding registered first
+// is used.
+//
+// Streamed responses (see [Response.Stream]) are compressed regardless of
+// MinSize, and each write to the stream is flushed to the client.
+type Compressor struct {
+	// MinSize is the minimum size in bytes of a body for it to be compressed.
+	// Small bodies are often larger once compressed. Defaults to 1024.
+	MinSize int
+	// ContentTypes lists the Content-Types that will be compressed. An entry
+	// ending in "/*", such as "text/*", matches every subtype. Parameters such as
+	// charset are ignored when matching. Server-Sent Events ("text/event-stream")
+	// are never compressed, since clients and proxies read them as they arrive.
+	ContentTypes []string
+	encoders     map[string]EncoderFunc
+	codings      []string
+}
+
+// Creates and initializes a new [Compressor] with the gzip and deflate
+// content-codings registered. Text, JSON, JavaScript, XML, and SVG responses
+// of at least 1kB are compressed.
+func NewCompressor() *Compressor {
+	c := &Compressor{
+		MinSize: defaultCompressionMinSize,
+		ContentTypes: []string{
+			"text/*",
+			"application/json",
+			"application/javascript",
+			"application/xml",
+			"image/svg+xml",
+		},
+		encoders: make(map[string]EncoderFunc),
+		codings:  make([]string, 0),
+	}
+
+	c.RegisterEncoder("gzip", func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriterLevel(w, gzip.DefaultCompression)
+	})
+	c.RegisterEncoder("deflate", func(w io.Writer) (io.WriteCloser, error) {
+		return zlib.NewWriterLevel(w, zlib.DefaultCompression)
+	})
+
+	return c
+}
+
+// Registers an encoder for the provided content-coding, such as "br".
+// Registering an encoder for a coding that already has one replaces it.
+func (c *Compressor) RegisterEncoder(coding string, encoder EncoderFunc) {
+	coding = strings.ToLower(strings.TrimSpace(coding))
+	if _, exists := c.encoders[coding]; !exists {
+		c.codings = append(c.codings, coding)
+	}
+	c.encoders[coding] = encoder
+}
+
+// negotiate chooses a registered content-coding acceptable to the client based on
+// the Accept-Encoding header. Returns an empty string if none are acceptable.
+func (c *Compressor) negotiate(acceptEncoding string) string {
+	accepted := parseQualityList(acceptEncoding)
+
+	best := ""
+	bestQuality := 0.0
+	for _, coding := range c.codings {
+		quality := 0.0
+		for _, qv := range accepted {
+			if qv.value == coding {
+				quality = qv.quality
+				break
+			}
+			if qv.value == "*" {
+				quality = qv.quality
+			}
+		}
+
+		if quality > bestQuality {
+			best = coding
+			bestQuality = quality
+		}
+	}
+
+	return best
+}
+
+func (c *Compressor) compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if mediaType == "" || mediaType == "text/event-stream" {
+		return false
+	}
+
+	for _, allowed := range c.ContentTypes {
+		allowed = strings.ToLower(allowed)
+		if prefix, found := strings.CutSuffix(allowed, "/*"); found {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+		} else if mediaType == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compress replaces the body of res with its compressed form if it is
+// large enough, has a compressible Content-Type, and the client accepts
+// one of the registered codings. Compressing changes the bytes of the body,
+// so a strong ETag is made weak.
+func (c *Compressor) compress(req Request, res *Response) error {
+	if res.statusCode != 200 || len(res.body) < c.MinSize {
+		return nil
+	}
+
+	if _, exists := res.headers.get("Content-Encoding"); exists {
+		return nil
+	}
+
+	if !c.compressible(res.headers["Content-Type"]) {
+		return nil
+	}
+
+	addVary(res, "Accept-Encoding")
+
+	acceptEncoding, _ := req.headers.get("Accept-Encoding")
+	coding := c.negotiate(acceptEncoding)
+	if coding == "" {
+		return nil
+	}
+
+	var compressed strings.Builder
+	encoder, err := c.encoders[coding](&compressed)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(encoder, res.body)
+	if err != nil {
+		return err
+	}
+
+	err = encoder.Close()
+	if err != nil {
+		return err
+	}
+
+	res.body = compressed.String()
+	res.headers["Content-Length"] = strconv.Itoa(len(res.body))
+	res.headers["Content-Encoding"] = coding
+	if etag, ok := parseEntityTag(res.headers["ETag"]); ok && !etag.weak {
+		etag.weak = true
+		res.headers["ETag"] = etag.String()
+	}
+
+	return nil
+}
+
+// wrapStream compresses the body of res if the callback streams it
+func (c *Compressor) wrapStream(req Request, res *Response) {
+	startStream := res.startStream
+	if startStream == nil {
+		return
+	}
+
+	res.startStream = func(res *Response) (io.Writer, error) {
+		_, encoded := res.headers.get("Content-Encoding")
+		if encoded || !c.compressible(res.headers["Content-Type"]) {
+			return startStream(res)
+		}
+
+		addVary(res, "Accept-Encoding")
+
+		acceptEncoding, _ := req.headers.get("Accept-Encoding")
+		coding := c.negotiate(acceptEncoding)
+		if coding == "" {
+			return startStream(res)
+		}
+
+		res.headers["Content-Encoding"] = coding
+		stream, err := startStream(res)
+		if err != nil {
+			return nil, err
+		}
+
+		encoder, err := c.encoders[coding](stream)
+		if err != nil {
+			return nil, err
+		}
+
+		return flushingEncoder{encoder}, nil
+	}
+}
+
+// flushingEncoder flushes the encoder after every write, if it supports
+// flushing, so streamed data reaches the client immediately.
+type flushingEncoder struct {
+	io.WriteCloser
+}
+
+func (fe flushingEncoder) Write(p []byte) (int, error) {
+	n, err := fe.WriteCloser.Write(p)
+	if err != nil {
+		return n, err
+	}
+
+	if flusher, ok := fe.WriteCloser.(interface{ Flush() error }); ok {
+		err = flusher.Flush()
+	}
+	return n, err
+}
+
+// adds a field name to the Vary header, keeping any existing names
+func addVary(res *Response, field string) {
+	vary, exists := res.headers["Vary"]
+	if !exists || vary == "" {
+		res.headers["Vary"] = field
+		return
+	}
+
+	for _, existing := range strings.Split(vary, ",") {
+		existing = strings.TrimSpace(existing)
+		if existing == "*" || strings.EqualFold(existing, field) {
+			return
+		}
+	}
+
+	res.headers["Vary"] = vary + ", " + field
+}
@@ -0,0 +1,171 @@
+package simplehttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCompressor_Negotiate(t *testing.T) {
+	c := NewCompressor()
+	headers := map[string]string{
+		"":                              "",
+		"gzip":                          "gzip",
+		"deflate, gzip":                 "gzip",
+		"gzip;q=0.5, deflate":           "deflate",
+		"*":                             "gzip",
+		"*, gzip;q=0":                   "deflate",
+		"identity":                      "",
+		"br;q=1.0, gzip;q=0.8, *;q=0.1": "gzip",
+	}
+
+	for acceptEncoding, expected := range headers {
+		actual := c.negotiate(acceptEncoding)
+		if actual != expected {
+			t.Fatalf("incorrect coding for '%s'. Expected '%s' | Actual '%s'", acceptEncoding, expected, actual)
+		}
+	}
+}
+
+func TestCompressor_Gzip(t *testing.T) {
+	c := NewCompressor()
+	req := Request{method: get, headers: headers{"Accept-Encoding": "gzip, deflate"}}
+	res := newResponse()
+	res.SetJson(`{"message": "` + strings.Repeat("hello world ", 200) + `"}`)
+	original := res.body
+	res.SetETag("v1", false)
+
+	err := c.compress(req, &res)
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+
+	if res.headers["Content-Encoding"] != "gzip" || res.headers["Vary"] != "Accept-Encoding" {
+		t.Fatalf("incorrect headers. Actual %v", res.headers)
+	}
+
+	if res.headers["ETag"] != `W/"v1"` {
+		t.Fatalf("expected the ETag to become weak | Actual '%s'", res.headers["ETag"])
+	}
+
+	reader, err := gzip.NewReader(strings.NewReader(res.body))
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+
+	decompressed, _ := io.ReadAll(reader)
+	if string(decompressed) != original {
+		t.Fatalf("the decompressed body did not match the original body")
+	}
+}
+
+func TestCompressor_SkipsSmallAndIncompressibleBodies(t *testing.T) {
+	c := NewCompressor()
+	req := Request{method: get, headers: headers{"Accept-Encoding": "gzip"}}
+
+	res := newResponse()
+	res.SetJson(`{"small": true}`)
+	c.compress(req, &res)
+	if _, exists := res.headers["Content-Encoding"]; exists {
+		t.Fatalf("did not expect a small body to be compressed")
+	}
+
+	res = newResponse()
+	res.SetJson(`{"message": "` + strings.Repeat("hello world ", 200) + `"}`)
+	res.headers["Content-Type"] = "image/png"
+	c.compress(req, &res)
+	if _, exists := res.headers["Content-Encoding"]; exists {
+		t.Fatalf("did not expect an image to be compressed")
+	}
+}
+
+func TestCompressor_RegisterEncoder(t *testing.T) {
+	c := NewCompressor()
+	c.RegisterEncoder("upper", func(w io.Writer) (io.WriteCloser, error) {
+		return upperEncoder{w}, nil
+	})
+
+	req := Request{method: get, headers: headers{"Accept-Encoding": "upper"}}
+	res := newResponse()
+	res.SetJson(`{"message": "` + strings.Repeat("hello world ", 200) + `"}`)
+	c.compress(req, &res)
+
+	if res.headers["Content-Encoding"] != "upper" || !strings.Contains(res.body, "HELLO WORLD") {
+		t.Fatalf("expected the custom encoder to be used")
+	}
+}
+
+type upperEncoder struct {
+	w io.Writer
+}
+
+func (u upperEncoder) Write(p []byte) (int, error) {
+	return u.w.Write(bytes.ToUpper(p))
+}
+
+func (u upperEncoder) Close() error {
+	return nil
+}
+
+func TestCompressor_Stream(t *testing.T) {
+	var client bytes.Buffer
+	c := NewCompressor()
+	req := Request{method: get, headers: headers{"Accept-Encoding": "deflate"}}
+	res := newResponse()
+	res.headers["Content-Type"] = "text/plain"
+	res.startStream = func(res *Response) (io.Writer, error) {
+		client.WriteString(res.head())
+		return &client, nil
+	}
+
+	c.wrapStream(req, &res)
+	stream, _ := res.Stream()
+	io.WriteString(stream, "streamed data")
+	res.closeStream()
+
+	if res.headers["Content-Encoding"] != "deflate" {
+		t.Fatalf("incorrect Content-Encoding. Expected 'deflate' | Actual '%s'", res.headers["Content-Encoding"])
+	}
+
+	_, body, _ := strings.Cut(client.String(), doubleLineEnd)
+	reader, err := zlib.NewReader(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+
+	decompressed, _ := io.ReadAll(reader)
+	if string(decompressed) != "streamed data" {
+		t.Fatalf("incorrect body. Expected 'streamed data' | Actual '%s'", decompressed)
+	}
+}
+
+func TestCompressor_SkipsEventStreams(t *testing.T) {
+	var client bytes.Buffer
+	c := NewCompressor()
+	req := Request{method: get, headers: headers{"Accept-Encoding": "gzip"}}
+	res := newResponse()
+	res.startStream = func(res *Response) (io.Writer, error) {
+		client.WriteString(res.head())
+		return &client, nil
+	}
+
+	c.wrapStream(req, &res)
+	events, err := res.EventStream()
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+	events.SetHeartbeat(0)
+	events.Send(Event{Data: "hello"})
+	res.closeStream()
+
+	if _, exists := res.headers["Content-Encoding"]; exists {
+		t.Fatalf("expected the event stream not to be compressed. Actual headers %v", res.headers)
+	}
+
+	if !strings.Contains(client.String(), "data: hello\n\n") {
+		t.Fatalf("expected the event to be sent uncompressed. Actual '%s'", client.String())
+	}
+}
@@ -0,0 +1,262 @@
+package simplehttp
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ETagMode determines whether the [Server] automatically generates an
+// ETag header for responses that do not already have one.
+type ETagMode int
+
+const (
+	// NoETags disables automatic ETag generation. This is the default.
+	NoETags ETagMode = iota
+	// WeakETags generates weak ETags (ex. W/"1a-0f3c...").
+	WeakETags
+	// StrongETags generates strong ETags (ex. "0f3c...").
+	StrongETags
+)
+
+// An entity tag as described in RFC 9110 Section 8.8.3.
+type entityTag struct {
+	weak   bool
+	opaque string // includes the surrounding double quotes
+}
+
+func (et entityTag) String() string {
+	if et.weak {
+		return "W/" + et.opaque
+	}
+	return et.opaque
+}
+
+// two entity tags match under strong comparison if neither is weak and their opaque tags are identical
+func (et entityTag) strongMatch(other entityTag) bool {
+	return !et.weak && !other.weak && et.opaque == other.opaque
+}
+
+// two entity tags match under weak comparison if their opaque tags are identical
+func (et entityTag) weakMatch(other entityTag) bool {
+	return et.opaque == other.opaque
+}
+
+// Sets the Response's ETag header to the provided tag. The tag must not
+// contain double quotes; they will be added. If weak is true, the ETag will be
+// marked as weak, meaning the response is semantically equivalent but not
+// byte-for-byte identical to other responses with the same tag.
+// An error is returned if the tag contains invalid characters.
+func (r *Response) SetETag(tag string, weak bool) error {
+	for _, c := range tag {
+		if c == '"' || c < 0x21 || c == 0x7f {
+			return fmt.Errorf("ETag contains an invalid character: %q", c)
+		}
+	}
+
+	r.headers["ETag"] = entityTag{weak, `"` + tag + `"`}.String()
+	return nil
+}
+
+// Sets the Response's Last-Modified header to the provided time.
+func (r *Response) SetLastModified(modified time.Time) {
+	r.headers["Last-Modified"] = formatHttpDate(modified)
+}
+
+// generates an ETag from the response's body if it does not already have one
+func generateETag(res *Response, mode ETagMode) {
+	if mode == NoETags || res.statusCode != 200 {
+		return
+	}
+
+	if _, exists := res.headers["ETag"]; exists {
+		return
+	}
+
+	hash := sha1.Sum([]byte(res.body))
+	if mode == WeakETags {
+		res.SetETag(fmt.Sprintf("%x-%s", len(res.body), hex.EncodeToString(hash[:10])), true)
+	} else {
+		res.SetETag(hex.EncodeToString(hash[:]), false)
+	}
+}
+
+// Reports whether the request's If-Match, If-Unmodified-Since, and
+// If-None-Match headers allow it to change the target resource, given the
+// resource's current etag and lastModified time. The etag must be formatted as
+// it is in an ETag header (ex. "v1" or W/"v1", including the double quotes), and
+// is empty if the resource does not have one. lastModified is the zero time if
+// it is unknown.
+//
+// The server checks state-changing requests automatically when
+// [Server.ResourceState] is provided. Otherwise, a callback for a request such
+// as a PUT or DELETE should call CheckPreconditions before making any changes,
+// and respond with a 412 Precondition Failed if it returns false.
+func (r Request) CheckPreconditions(etag string, lastModified time.Time) bool {
+	tag, hasETag := parseEntityTag(etag)
+	return preconditionStatus(r, tag, hasETag, lastModified, !lastModified.IsZero(), true) != 412
+}
+
+// checks the If-Match, If-Unmodified-Since, and If-None-Match headers of a
+// state-changing request against Server.ResourceState, before its callback runs
+func (s *Server) preconditionsPass(req Request) bool {
+	if s.ResourceState == nil || req.method == get || req.method == options {
+		return true
+	}
+
+	_, hasIfMatch := req.headers.get("If-Match")
+	_, hasIfUnmodifiedSince := req.headers.get("If-Unmodified-Since")
+	_, hasIfNoneMatch := req.headers.get("If-None-Match")
+	if !hasIfMatch && !hasIfUnmodifiedSince && !hasIfNoneMatch {
+		return true
+	}
+
+	etag, lastModified, exists := s.ResourceState(req)
+	tag, hasETag := parseEntityTag(etag)
+	return preconditionStatus(req, tag, hasETag, lastModified, !lastModified.IsZero(), exists) != 412
+}
+
+// evaluatePreconditions compares the conditional request headers
+// (If-Match, If-Unmodified-Since, If-None-Match, and If-Modified-Since) of a
+// GET request with the ETag and Last-Modified headers of res. If a precondition
+// fails, res is changed to a 304 Not Modified or 412 Precondition Failed
+// response without a body. Preconditions are only evaluated for successful
+// (2xx) responses. Other methods change state in their callback, so they
+// are checked before it runs, see Server.preconditionsPass.
+func evaluatePreconditions(req Request, res *Response) {
+	if req.method != get || res.statusCode < 200 || res.statusCode > 299 {
+		return
+	}
+
+	etag, hasETag := parseEntityTag(res.headers["ETag"])
+	lastModified, lastModifiedErr := parseHttpDate(res.headers["Last-Modified"])
+
+	switch preconditionStatus(req, etag, hasETag, lastModified, lastModifiedErr == nil, true) {
+	case 304:
+		setNotModified(res)
+	case 412:
+		setPreconditionFailed(res)
+	}
+}
+
+// preconditionStatus evaluates the conditional request headers against the
+// resource's current state, following the order in RFC 9110 Section 13.2.2.
+// resourceExists is false if the resource does not currently have a representation.
+// Returns 304 or 412 if a precondition fails, or 0 if they all pass.
+func preconditionStatus(req Request, etag entityTag, hasETag bool, lastModified time.Time, hasLastModified bool,
+	resourceExists bool) int {
+	safeMethod := req.method == get
+
+	if ifMatch, exists := req.headers.get("If-Match"); exists {
+		if !etagListMatches(ifMatch, etag, hasETag, resourceExists, entityTag.strongMatch) {
+			return 412
+		}
+	} else if ifUnmodifiedSince, exists := req.headers.get("If-Unmodified-Since"); exists && hasLastModified {
+		since, err := parseHttpDate(ifUnmodifiedSince)
+		if err == nil && lastModified.Truncate(time.Second).After(since) {
+			return 412
+		}
+	}
+
+	if ifNoneMatch, exists := req.headers.get("If-None-Match"); exists {
+		if etagListMatches(ifNoneMatch, etag, hasETag, resourceExists, entityTag.weakMatch) {
+			if safeMethod {
+				return 304
+			}
+			return 412
+		}
+		return 0
+	}
+
+	if ifModifiedSince, exists := req.headers.get("If-Modified-Since"); exists && safeMethod && hasLastModified {
+		since, err := parseHttpDate(ifModifiedSince)
+		if err == nil && !lastModified.Truncate(time.Second).After(since) {
+			return 304
+		}
+	}
+
+	return 0
+}
+
+// reports whether any entity tag in the comma separated list matches etag using match.
+// The list "*" matches any current representation, so it only matches if exists is true.
+func etagListMatches(list string, etag entityTag, hasETag bool, exists bool,
+	match func(entityTag, entityTag) bool) bool {
+	if strings.TrimSpace(list) == "*" {
+		return exists
+	}
+
+	if !hasETag {
+		return false
+	}
+
+	for _, candidate := range parseEntityTagList(list) {
+		if match(candidate, etag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseEntityTag(value string) (entityTag, bool) {
+	tags := parseEntityTagList(value)
+	if len(tags) != 1 {
+		return entityTag{}, false
+	}
+	return tags[0], true
+}
+
+// parses a comma separated list of entity tags, skipping any that are malformed.
+// Entity tags are allowed to contain commas, so the list cannot simply be split.
+func parseEntityTagList(list string) []entityTag {
+	tags := make([]entityTag, 0)
+
+	for {
+		list = strings.TrimLeft(list, " \t,")
+		if list == "" {
+			return tags
+		}
+
+		weak := strings.HasPrefix(list, "W/")
+		if weak {
+			list = list[2:]
+		}
+
+		if !strings.HasPrefix(list, `"`) {
+			// skip to the next element of the list
+			next := strings.Index(list, ",")
+			if next == -1 {
+				return tags
+			}
+			list = list[next:]
+			continue
+		}
+
+		end := strings.Index(list[1:], `"`)
+		if end == -1 {
+			return tags
+		}
+
+		tags = append(tags, entityTag{weak, list[:end+2]})
+		list = list[end+2:]
+	}
+}
+
+func setNotModified(res *Response) {
+	res.SetStatus(304)
+	res.body = ""
+	delete(res.headers, "Content-Length")
+	delete(res.headers, "Content-Type")
+}
+
+func setPreconditionFailed(res *Response) {
+	res.SetStatus(412)
+	res.body = ""
+	res.headers["Content-Length"] = "0"
+	delete(res.headers, "Content-Type")
+	delete(res.headers, "ETag")
+	delete(res.headers, "Last-Modified")
+}
@@ -0,0 +1,250 @@
+package simplehttp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetETag(t *testing.T) {
+	res := newResponse()
+
+	res.SetETag("abc", false)
+	if res.headers["ETag"] != `"abc"` {
+		t.Fatalf("incorrect ETag. Expected '\"abc\"' | Actual '%s'", res.headers["ETag"])
+	}
+
+	res.SetETag("abc", true)
+	if res.headers["ETag"] != `W/"abc"` {
+		t.Fatalf("incorrect ETag. Expected 'W/\"abc\"' | Actual '%s'", res.headers["ETag"])
+	}
+
+	err := res.SetETag(`a"bc`, false)
+	if err == nil {
+		t.Fatalf("expected an error for an ETag containing a double quote, but it was nil")
+	}
+}
+
+func TestGenerateETag(t *testing.T) {
+	res := newResponse()
+	res.SetJson(`{"foo": "bar"}`)
+
+	generateETag(&res, StrongETags)
+	strong := res.headers["ETag"]
+	if len(strong) != 42 || strong[0] != '"' {
+		t.Fatalf("expected a strong ETag of a SHA-1 hash | Actual '%s'", strong)
+	}
+
+	delete(res.headers, "ETag")
+	generateETag(&res, WeakETags)
+	if res.headers["ETag"][:4] != `W/"e` {
+		t.Fatalf("expected a weak ETag beginning with the body's length | Actual '%s'", res.headers["ETag"])
+	}
+
+	delete(res.headers, "ETag")
+	generateETag(&res, NoETags)
+	if _, exists := res.headers["ETag"]; exists {
+		t.Fatalf("did not expect an ETag to be generated")
+	}
+}
+
+func TestParseEntityTagList(t *testing.T) {
+	tags := parseEntityTagList(`"a", W/"b,c" , malformed, "d"`)
+	expected := []string{`"a"`, `W/"b,c"`, `"d"`}
+
+	if len(tags) != len(expected) {
+		t.Fatalf("expected %d tags | Actual %v", len(expected), tags)
+	}
+
+	for i, tag := range tags {
+		if tag.String() != expected[i] {
+			t.Fatalf("incorrect tag. Expected '%s' | Actual '%s'", expected[i], tag.String())
+		}
+	}
+}
+
+func TestEvaluatePreconditions_IfNoneMatch(t *testing.T) {
+	req := Request{method: get, headers: headers{"If-None-Match": `"v0", W/"v1"`}}
+	res := newResponse()
+	res.SetHtml("<h1>Hello World!</h1>")
+	res.SetETag("v1", false)
+	res.SetLastModified(time.Date(2024, 5, 12, 5, 53, 22, 0, time.UTC))
+
+	evaluatePreconditions(req, &res)
+
+	if res.statusCode != 304 || res.body != "" {
+		t.Fatalf("expected a 304 without a body | Actual '%d %s'", res.statusCode, res.body)
+	}
+
+	if res.headers["ETag"] != `"v1"` {
+		t.Fatalf("a 304 response should keep its ETag | Actual '%s'", res.headers["ETag"])
+	}
+
+	req = Request{method: get, headers: headers{"If-None-Match": `"v2"`}}
+	res = newResponse()
+	res.SetHtml("<h1>Hello World!</h1>")
+	res.SetETag("v1", false)
+	res.SetLastModified(time.Date(2024, 5, 12, 5, 53, 22, 0, time.UTC))
+	evaluatePreconditions(req, &res)
+
+	if res.statusCode != 200 {
+		t.Fatalf("expected a 200 for a non-matching ETag | Actual '%d'", res.statusCode)
+	}
+
+	req = Request{method: put, headers: headers{"If-None-Match": "*"}}
+	res = newResponse()
+	res.SetHtml("<h1>Hello World!</h1>")
+	res.SetETag("v1", false)
+	res.SetLastModified(time.Date(2024, 5, 12, 5, 53, 22, 0, time.UTC))
+	evaluatePreconditions(req, &res)
+
+	if res.statusCode != 200 {
+		t.Fatalf("expected preconditions to be ignored after a PUT callback | Actual '%d'", res.statusCode)
+	}
+}
+
+func TestEvaluatePreconditions_IfModifiedSince(t *testing.T) {
+	req := Request{method: get, headers: headers{"if-modified-since": "Sun, 12 May 2024 05:53:22 GMT"}}
+	res := newResponse()
+	res.SetHtml("<h1>Hello World!</h1>")
+	res.SetETag("v1", false)
+	res.SetLastModified(time.Date(2024, 5, 12, 5, 53, 22, 0, time.UTC))
+	evaluatePreconditions(req, &res)
+
+	if res.statusCode != 304 {
+		t.Fatalf("expected a 304 for an unmodified resource | Actual '%d'", res.statusCode)
+	}
+
+	req = Request{method: get, headers: headers{"If-Modified-Since": "Sat, 11 May 2024 05:53:22 GMT"}}
+	res = newResponse()
+	res.SetHtml("<h1>Hello World!</h1>")
+	res.SetETag("v1", false)
+	res.SetLastModified(time.Date(2024, 5, 12, 5, 53, 22, 0, time.UTC))
+	evaluatePreconditions(req, &res)
+
+	if res.statusCode != 200 {
+		t.Fatalf("expected a 200 for a modified resource | Actual '%d'", res.statusCode)
+	}
+
+	// If-None-Match takes precedence over If-Modified-Since
+	req = Request{method: get, headers: headers{
+		"If-None-Match":     `"v2"`,
+		"If-Modified-Since": "Sun, 12 May 2024 05:53:22 GMT",
+	}}
+	res = newResponse()
+	res.SetHtml("<h1>Hello World!</h1>")
+	res.SetETag("v1", false)
+	res.SetLastModified(time.Date(2024, 5, 12, 5, 53, 22, 0, time.UTC))
+	evaluatePreconditions(req, &res)
+
+	if res.statusCode != 200 {
+		t.Fatalf("expected If-Modified-Since to be ignored | Actual '%d'", res.statusCode)
+	}
+}
+
+func TestEvaluatePreconditions_IfMatch(t *testing.T) {
+	req := Request{method: get, headers: headers{"If-Match": `W/"v1"`}}
+	res := newResponse()
+	res.SetHtml("<h1>Hello World!</h1>")
+	res.SetETag("v1", false)
+	res.SetLastModified(time.Date(2024, 5, 12, 5, 53, 22, 0, time.UTC))
+	evaluatePreconditions(req, &res)
+
+	if res.statusCode != 412 || res.body != "" {
+		t.Fatalf("expected a 412 because weak ETags never match strongly | Actual '%d'", res.statusCode)
+	}
+}
+
+func TestRequest_CheckPreconditions(t *testing.T) {
+	modified := time.Date(2024, 5, 12, 5, 53, 22, 0, time.UTC)
+
+	tests := []struct {
+		headers  headers
+		expected bool
+	}{
+		{headers{"If-Match": `"v1"`}, true},
+		{headers{"If-Match": `"v0", "v1"`}, true},
+		{headers{"If-Match": `W/"v1"`}, false},
+		{headers{"If-Match": `"v2"`}, false},
+		{headers{"If-Match": "*"}, true},
+		{headers{"If-None-Match": `"v1"`}, false},
+		{headers{"If-None-Match": "*"}, false},
+		{headers{"If-None-Match": `"v2"`}, true},
+		{headers{"If-Unmodified-Since": "Sun, 12 May 2024 05:53:22 GMT"}, true},
+		{headers{"If-Unmodified-Since": "Sat, 11 May 2024 05:53:22 GMT"}, false},
+		{headers{"If-Modified-Since": "Sun, 12 May 2024 05:53:22 GMT"}, true},
+		{headers{}, true},
+	}
+
+	for _, test := range tests {
+		req := Request{method: put, headers: test.headers}
+		actual := req.CheckPreconditions(`"v1"`, modified.Add(500*time.Millisecond))
+		if actual != test.expected {
+			t.Fatalf("incorrect result for %v. Expected '%t' | Actual '%t'", test.headers, test.expected, actual)
+		}
+	}
+
+	req := Request{method: del, headers: headers{"If-Match": `"v1"`, "If-Unmodified-Since": "Sat, 11 May 2024 05:53:22 GMT"}}
+	if req.CheckPreconditions("", time.Time{}) {
+		t.Fatalf("expected If-Match to fail for a resource without an ETag")
+	}
+}
+
+func TestServer_ResourceState(t *testing.T) {
+	modified := time.Date(2024, 5, 12, 5, 53, 22, 0, time.UTC)
+	exists := true
+	invoked := false
+
+	server := NewServer(0)
+	server.ResourceState = func(req Request) (string, time.Time, bool) {
+		if !exists {
+			return "", time.Time{}, false
+		}
+		return `"v1"`, modified, true
+	}
+	server.Put("/doc", func(req Request, res *Response) error {
+		invoked = true
+		return nil
+	})
+
+	tests := []struct {
+		header   string
+		exists   bool
+		expected string
+	}{
+		{"If-Match: \"v1\"", true, "HTTP/1.0 200 OK"},
+		{"If-Match: \"v2\"", true, "HTTP/1.0 412 Precondition Failed"},
+		{"If-Match: *", false, "HTTP/1.0 412 Precondition Failed"},
+		{"If-Unmodified-Since: Sat, 11 May 2024 05:53:22 GMT", true, "HTTP/1.0 412 Precondition Failed"},
+		{"If-None-Match: *", true, "HTTP/1.0 412 Precondition Failed"},
+		{"If-None-Match: *", false, "HTTP/1.0 200 OK"},
+	}
+
+	for _, test := range tests {
+		exists = test.exists
+		invoked = false
+		response := sendRawRequest(&server, "PUT /doc HTTP/1.0"+lineEnd+test.header+doubleLineEnd)
+		if !strings.HasPrefix(response, test.expected) {
+			t.Fatalf("incorrect response for '%s'. Expected '%s' | Actual '%s'", test.header, test.expected, response)
+		}
+
+		if invoked != strings.HasSuffix(test.expected, "200 OK") {
+			t.Fatalf("expected the callback to be invoked only when the preconditions pass. Header '%s'", test.header)
+		}
+	}
+
+	response := sendRawRequest(&server, "PUT /missing HTTP/1.0"+lineEnd+"If-Match: \"v2\""+doubleLineEnd)
+	if !strings.HasPrefix(response, "HTTP/1.0 404 Not Found") {
+		t.Fatalf("expected unregistered paths to be unaffected. Actual '%s'", response)
+	}
+}
+
+func TestEvaluatePreconditions_IgnoresErrorResponses(t *testing.T) {
+	req := Request{method: get, headers: headers{"If-None-Match": "*"}}
+	res := new404StatusResponse()
+	evaluatePreconditions(req, &res)
+
+	if res.statusCode != 404 {
+		t.Fatalf("expected preconditions to be ignored for a 404 | Actual '%d'", res.statusCode)
+	}
+}
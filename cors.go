@@ -0,0 +1,213 @@
+package simplehttp
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CORS is middleware that implements Cross-Origin Resource Sharing, allowing
+// browsers to call the [Server] from pages served by other origins.
+// A CORS should only be created using the [NewCORS] method to ensure it is
+// properly initialized, and is registered using [Server.Use]:
+//
+//	cors := simplehttp.NewCORS()
+//	cors.AllowedOrigins = []string{"https://app.example.com"}
+//	server.Use(cors.Middleware)
+//
+// CORS answers preflight requests (OPTIONS requests with an
+// Access-Control-Request-Method header) itself, so callbacks do not need to be
+// registered for them. Other requests from an allowed origin are passed on to
+// the next callback with the appropriate Access-Control-* headers added to the
+// response. These headers are kept if the callback returns an error, so the
+// browser allows scripts to read the error response. Requests from origins
+// that are not allowed receive no Access-Control-* headers, which causes the
+// browser to block them.
+type CORS struct {
+	// AllowedOrigins lists origins that are allowed, such as
+	// "https://example.com". An origin may contain a single "*" wildcard, such
+	// as "https://*.example.com", and the origin "*" allows every origin unless
+	// AllowCredentials is true. Defaults to "*".
+	AllowedOrigins []string
+	// AllowedOriginPatterns lists regular expressions that allowed origins match.
+	AllowedOriginPatterns []*regexp.Regexp
+	// AllowOriginFunc is called for origins that are not allowed by
+	// AllowedOrigins or AllowedOriginPatterns. It returns true if the origin
+	// is allowed.
+	AllowOriginFunc func(origin string) bool
+	// AllowedMethods lists the methods allowed in cross-origin requests.
+	// Defaults to GET, POST, PUT, and DELETE.
+	AllowedMethods []string
+	// AllowedHeaders lists the request headers allowed in cross-origin requests.
+	// If empty, any headers requested in a preflight request are allowed.
+	AllowedHeaders []string
+	// ExposedHeaders lists the response headers that the browser allows
+	// scripts to read.
+	ExposedHeaders []string
+	// AllowCredentials allows cross-origin requests to include cookies and
+	// HTTP authentication. When true, the allowed origin is always echoed back
+	// rather than using "*", and the origin "*" in AllowedOrigins is ignored so
+	// that only origins that are explicitly allowed can make authenticated requests.
+	AllowCredentials bool
+	// MaxAge is the number of seconds the browser may cache the result of a
+	// preflight request. If 0, the Access-Control-Max-Age header is not sent.
+	MaxAge int
+}
+
+// Creates and initializes a new [CORS] that allows every origin to make
+// GET, POST, PUT, and DELETE requests.
+func NewCORS() *CORS {
+	return &CORS{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
+	}
+}
+
+// Middleware is the [MiddlewareFunc] to be registered on the [Server] using
+// [Server.Use].
+func (c *CORS) Middleware(next CallbackFunc) CallbackFunc {
+	return func(req Request, res *Response) error {
+		origin, exists := req.headers.get("Origin")
+		if !exists {
+			return next(req, res)
+		}
+
+		requestMethod, isPreflight := req.headers.get("Access-Control-Request-Method")
+		isPreflight = isPreflight && req.method == options
+
+		if isPreflight {
+			c.handlePreflight(req, res, origin, requestMethod)
+			return nil
+		}
+
+		addVary(res, "Origin")
+		res.keepHeader("Vary", res.headers["Vary"])
+		if c.originAllowed(origin) {
+			c.setAllowOrigin(res, origin)
+			if len(c.ExposedHeaders) > 0 {
+				res.keepHeader("Access-Control-Expose-Headers", strings.Join(c.ExposedHeaders, ", "))
+			}
+		}
+
+		return next(req, res)
+	}
+}
+
+func (c *CORS) handlePreflight(req Request, res *Response, origin string, requestMethod string) {
+	res.SetStatus(204)
+	addVary(res, "Origin")
+	addVary(res, "Access-Control-Request-Method")
+	addVary(res, "Access-Control-Request-Headers")
+
+	if !c.originAllowed(origin) || !c.methodAllowed(requestMethod) {
+		return
+	}
+
+	requestHeaders, _ := req.headers.get("Access-Control-Request-Headers")
+	allowedHeaders, ok := c.allowHeaders(requestHeaders)
+	if !ok {
+		return
+	}
+
+	c.setAllowOrigin(res, origin)
+	res.headers["Access-Control-Allow-Methods"] = strings.Join(c.AllowedMethods, ", ")
+	if allowedHeaders != "" {
+		res.headers["Access-Control-Allow-Headers"] = allowedHeaders
+	}
+
+	if c.MaxAge > 0 {
+		res.headers["Access-Control-Max-Age"] = strconv.Itoa(c.MaxAge)
+	}
+}
+
+func (c *CORS) setAllowOrigin(res *Response, origin string) {
+	if c.AllowCredentials {
+		res.keepHeader("Access-Control-Allow-Origin", origin)
+		res.keepHeader("Access-Control-Allow-Credentials", "true")
+		return
+	}
+
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			res.keepHeader("Access-Control-Allow-Origin", "*")
+			return
+		}
+	}
+
+	res.keepHeader("Access-Control-Allow-Origin", origin)
+}
+
+func (c *CORS) originAllowed(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		// reflecting every origin along with credentials would allow any
+		// site to make authenticated requests
+		if allowed == "*" && c.AllowCredentials {
+			continue
+		}
+
+		if matchOrigin(allowed, origin) {
+			return true
+		}
+	}
+
+	for _, pattern := range c.AllowedOriginPatterns {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+
+	return c.AllowOriginFunc != nil && c.AllowOriginFunc(origin)
+}
+
+// matches an origin against an allowed origin that may contain a single "*" wildcard
+func matchOrigin(allowed string, origin string) bool {
+	allowed = strings.ToLower(allowed)
+	origin = strings.ToLower(origin)
+
+	prefix, suffix, hasWildcard := strings.Cut(allowed, "*")
+	if !hasWildcard {
+		return allowed == origin
+	}
+
+	return len(origin) >= len(prefix)+len(suffix) &&
+		strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix)
+}
+
+func (c *CORS) methodAllowed(method string) bool {
+	for _, allowed := range c.AllowedMethods {
+		if allowed == method {
+			return true
+		}
+	}
+	return false
+}
+
+// allowHeaders checks the headers requested in a preflight request.
+// Returns the value for the Access-Control-Allow-Headers header, and
+// false if any of the requested headers are not allowed.
+func (c *CORS) allowHeaders(requestHeaders string) (string, bool) {
+	if len(c.AllowedHeaders) == 0 {
+		return requestHeaders, true
+	}
+
+	for _, requested := range strings.Split(requestHeaders, ",") {
+		requested = strings.TrimSpace(requested)
+		if requested == "" {
+			continue
+		}
+
+		allowed := false
+		for _, header := range c.AllowedHeaders {
+			if header == "*" || strings.EqualFold(header, requested) {
+				allowed = true
+				break
+			}
+		}
+
+		if !allowed {
+			return "", false
+		}
+	}
+
+	return strings.Join(c.AllowedHeaders, ", "), true
+}
@@ -0,0 +1,170 @@
+package simplehttp
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestMatchOrigin(t *testing.T) {
+	matches := map[[2]string]bool{
+		{"https://example.com", "https://example.com"}:       true,
+		{"https://example.com", "https://EXAMPLE.com"}:       true,
+		{"https://example.com", "http://example.com"}:        false,
+		{"https://*.example.com", "https://api.example.com"}: true,
+		{"https://*.example.com", "https://example.com"}:     false,
+		{"https://*.example.com", "https://evil.com"}:        false,
+		{"*", "https://anything.com"}:                        true,
+	}
+
+	for pair, expected := range matches {
+		if matchOrigin(pair[0], pair[1]) != expected {
+			t.Fatalf("incorrect match of '%s' against '%s'. Expected '%v'", pair[1], pair[0], expected)
+		}
+	}
+}
+
+func TestCORS_SimpleRequest(t *testing.T) {
+	cors := NewCORS()
+	cors.AllowedOrigins = []string{"https://app.example.com"}
+	cors.ExposedHeaders = []string{"X-Total-Count"}
+
+	res, next := invokeMiddleware(cors.Middleware, Request{method: get, headers: headers{"Origin": "https://app.example.com"}})
+	if next == nil {
+		t.Fatalf("expected the next callback to be invoked")
+	}
+
+	if res.headers["Access-Control-Allow-Origin"] != "https://app.example.com" {
+		t.Fatalf("incorrect Access-Control-Allow-Origin. Actual '%s'", res.headers["Access-Control-Allow-Origin"])
+	}
+
+	if res.headers["Access-Control-Expose-Headers"] != "X-Total-Count" || res.headers["Vary"] != "Origin" {
+		t.Fatalf("incorrect headers. Actual %v", res.headers)
+	}
+
+	res, next = invokeMiddleware(cors.Middleware, Request{method: get, headers: headers{"Origin": "https://evil.com"}})
+	if next == nil {
+		t.Fatalf("expected the next callback to be invoked")
+	}
+
+	if _, exists := res.headers["Access-Control-Allow-Origin"]; exists {
+		t.Fatalf("did not expect a disallowed origin to receive Access-Control-Allow-Origin")
+	}
+}
+
+func TestCORS_WildcardAndCredentials(t *testing.T) {
+	cors := NewCORS()
+
+	res, _ := invokeMiddleware(cors.Middleware, Request{method: get, headers: headers{"Origin": "https://app.example.com"}})
+	if res.headers["Access-Control-Allow-Origin"] != "*" {
+		t.Fatalf("incorrect Access-Control-Allow-Origin. Expected '*' | Actual '%s'",
+			res.headers["Access-Control-Allow-Origin"])
+	}
+
+	cors.AllowCredentials = true
+	res, _ = invokeMiddleware(cors.Middleware, Request{method: get, headers: headers{"Origin": "https://app.example.com"}})
+	if _, exists := res.headers["Access-Control-Allow-Origin"]; exists {
+		t.Fatalf("did not expect the wildcard origin to allow credentials. Actual %v", res.headers)
+	}
+
+	cors.AllowedOrigins = []string{"*", "https://app.example.com"}
+	res, _ = invokeMiddleware(cors.Middleware, Request{method: get, headers: headers{"Origin": "https://app.example.com"}})
+	if res.headers["Access-Control-Allow-Origin"] != "https://app.example.com" ||
+		res.headers["Access-Control-Allow-Credentials"] != "true" {
+		t.Fatalf("expected the origin to be echoed with credentials. Actual %v", res.headers)
+	}
+}
+
+func TestCORS_KeepsHeadersOnErrorResponses(t *testing.T) {
+	server := NewServer(0)
+	cors := NewCORS()
+	server.Use(cors.Middleware)
+	server.Get("/fail", func(_ Request, _ *Response) error {
+		return fmt.Errorf("failed")
+	})
+
+	responses := map[string]string{
+		"/fail":    "HTTP/1.0 500 Internal Server Error",
+		"/missing": "HTTP/1.0 404 Not Found",
+	}
+
+	for path, statusLine := range responses {
+		response := sendRawRequest(&server, "GET "+path+" HTTP/1.0"+lineEnd+"Origin: https://app.example.com"+doubleLineEnd)
+		if !strings.HasPrefix(response, statusLine+lineEnd) {
+			t.Fatalf("incorrect status line. Expected '%s' | Actual '%s'", statusLine, response)
+		}
+
+		if !strings.Contains(response, "Access-Control-Allow-Origin: *"+lineEnd) ||
+			!strings.Contains(response, "Vary: Origin"+lineEnd) {
+			t.Fatalf("expected the CORS headers to be kept. Actual '%s'", response)
+		}
+	}
+}
+
+func TestCORS_OriginPatternAndFunc(t *testing.T) {
+	cors := NewCORS()
+	cors.AllowedOrigins = nil
+	cors.AllowedOriginPatterns = []*regexp.Regexp{regexp.MustCompile(`^https://[a-z]+\.example\.org$`)}
+	cors.AllowOriginFunc = func(origin string) bool {
+		return origin == "http://localhost:3000"
+	}
+
+	for _, origin := range []string{"https://app.example.org", "http://localhost:3000"} {
+		res, _ := invokeMiddleware(cors.Middleware, Request{method: get, headers: headers{"Origin": origin}})
+		if res.headers["Access-Control-Allow-Origin"] != origin {
+			t.Fatalf("expected '%s' to be allowed. Actual %v", origin, res.headers)
+		}
+	}
+}
+
+func TestCORS_Preflight(t *testing.T) {
+	cors := NewCORS()
+	cors.AllowedOrigins = []string{"https://app.example.com"}
+	cors.AllowedHeaders = []string{"Content-Type", "Authorization"}
+	cors.MaxAge = 600
+
+	res, next := invokeMiddleware(cors.Middleware, Request{method: options, headers: headers{
+		"Origin":                         "https://app.example.com",
+		"Access-Control-Request-Method":  "PUT",
+		"Access-Control-Request-Headers": "content-type",
+	}})
+
+	if next != nil {
+		t.Fatalf("did not expect the next callback to be invoked for a preflight request")
+	}
+
+	if res.statusCode != 204 {
+		t.Fatalf("incorrect status. Expected '204' | Actual '%d'", res.statusCode)
+	}
+
+	expected := map[string]string{
+		"Access-Control-Allow-Origin":  "https://app.example.com",
+		"Access-Control-Allow-Methods": "GET, POST, PUT, DELETE",
+		"Access-Control-Allow-Headers": "Content-Type, Authorization",
+		"Access-Control-Max-Age":       "600",
+	}
+	for key, value := range expected {
+		if res.headers[key] != value {
+			t.Fatalf("incorrect %s. Expected '%s' | Actual '%s'", key, value, res.headers[key])
+		}
+	}
+}
+
+func TestCORS_PreflightRejected(t *testing.T) {
+	cors := NewCORS()
+	cors.AllowedHeaders = []string{"Content-Type"}
+
+	requests := []headers{
+		{"Origin": "https://app.example.com", "Access-Control-Request-Method": "PATCH"},
+		{"Origin": "https://app.example.com", "Access-Control-Request-Method": "GET",
+			"Access-Control-Request-Headers": "X-Secret"},
+	}
+
+	for _, reqHeaders := range requests {
+		res, _ := invokeMiddleware(cors.Middleware, Request{method: options, headers: reqHeaders})
+		if _, exists := res.headers["Access-Control-Allow-Origin"]; exists {
+			t.Fatalf("expected the preflight request to be rejected. Actual %v", res.headers)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package simplehttp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CSRFMode is the pattern a [CSRF] uses to check the token sent with a request.
+type CSRFMode int
+
+const (
+	// DoubleSubmitCookie stores the token in a cookie, and requires requests
+	// to send the same token in a header or form field. The server does not
+	// need to remember any tokens.
+	DoubleSubmitCookie CSRFMode = iota
+	// SynchronizerToken derives a token for each session from the session's
+	// ID and [CSRF.Secret], and requires requests to send the token of their
+	// session in a header or form field. Sessions are identified by
+	// [CSRF.SessionKey]. Tokens are not stored, and a session's token changes
+	// whenever its ID does, for example when the user logs in or out.
+	SynchronizerToken
+)
+
+const csrfTokenBytes int = 32
+
+// CSRF is middleware that protects against Cross-Site Request Forgery.
+// A CSRF should only be created using the [NewCSRF] method to ensure it is
+// properly initialized, and is registered using [Server.Use]:
+//
+//	csrf := simplehttp.NewCSRF()
+//	server.Use(csrf.Middleware)
+//
+// Requests using a safe method (GET and OPTIONS) are always allowed. Requests
+// using any other method are rejected with a 403 Forbidden response unless:
+//   - their Origin header, or the origin of their Referer header if there is
+//     no Origin header, is the server's own origin or one of the TrustedOrigins.
+//     Requests with neither header are only checked by their token.
+//   - they send the expected token in the header named HeaderName, or in the
+//     form field named FieldName of an application/x-www-form-urlencoded body.
+//
+// The expected token is available to callbacks, for example to render it in a
+// hidden form field, using [Request.CSRFToken].
+type CSRF struct {
+	// Mode is the pattern used to check tokens. Defaults to [DoubleSubmitCookie].
+	Mode CSRFMode
+	// CookieName is the name of the cookie that holds the token in
+	// DoubleSubmitCookie mode. Defaults to "csrf_token".
+	CookieName string
+	// CookieHttpOnly determines whether the cookie that holds the token is
+	// marked HttpOnly, which prevents scripts from reading it. Scripts that
+	// send the token in the HeaderName header must read it from the cookie,
+	// so it defaults to false.
+	CookieHttpOnly bool
+	// HeaderName is the name of the request header the token can be sent in.
+	// Defaults to "X-CSRF-Token".
+	HeaderName string
+	// FieldName is the name of the form field the token can be sent in.
+	// Defaults to "csrf_token".
+	FieldName string
+	// TrustedOrigins lists origins, such as "https://app.example.com", that
+	// are allowed to make requests in addition to the server's own origin.
+	TrustedOrigins []string
+	// SessionKey returns the ID of the session a request belongs to, for
+	// example the value of a session cookie. It is required in
+	// SynchronizerToken mode. Requests for which it returns an empty string
+	// have no token, so their unsafe requests are rejected.
+	SessionKey func(Request) string
+	// Secret is the key used to derive the token of each session in
+	// SynchronizerToken mode. Defaults to a random key, so tokens change when
+	// the server restarts. Servers that share sessions must use the same Secret.
+	Secret []byte
+}
+
+// Creates and initializes a new [CSRF] that uses the [DoubleSubmitCookie] pattern.
+func NewCSRF() *CSRF {
+	return &CSRF{
+		Mode:       DoubleSubmitCookie,
+		CookieName: "csrf_token",
+		HeaderName: "X-CSRF-Token",
+		FieldName:  "csrf_token",
+		Secret:     newCSRFSecret(),
+	}
+}
+
+type csrfTokenKey struct{}
+
+// Returns the CSRF token that must be sent with unsafe requests, for example
+// in a hidden form field. Returns an empty string if the request was not
+// handled by a [CSRF].
+func (r Request) CSRFToken() string {
+	token, _ := r.Context().Value(csrfTokenKey{}).(string)
+	return token
+}
+
+// Middleware is the [MiddlewareFunc] to be registered on the [Server] using
+// [Server.Use].
+func (c *CSRF) Middleware(next CallbackFunc) CallbackFunc {
+	return func(req Request, res *Response) error {
+		token := c.token(req, res)
+		req = req.WithContext(context.WithValue(req.Context(), csrfTokenKey{}, token))
+
+		if req.method == get || req.method == options {
+			return next(req, res)
+		}
+
+		if !c.originAllowed(req) || token == "" || !constantTimeEqual(c.submittedToken(req), token) {
+			res.SetStatus(403)
+			return nil
+		}
+
+		return next(req, res)
+	}
+}
+
+// token returns the token expected for the request, creating one if the
+// client or session does not have one yet
+func (c *CSRF) token(req Request, res *Response) string {
+	if c.Mode == SynchronizerToken {
+		session := ""
+		if c.SessionKey != nil {
+			session = c.SessionKey(req)
+		}
+		if session == "" || len(c.Secret) == 0 {
+			return ""
+		}
+
+		mac := hmac.New(sha256.New, c.Secret)
+		mac.Write([]byte(session))
+		return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	}
+
+	token, exists := requestCookie(req, c.CookieName)
+	if exists && len(token) == base64.RawURLEncoding.EncodedLen(csrfTokenBytes) {
+		return token
+	}
+
+	// a new token is only usable by the client's next request,
+	// so an unsafe request without a cookie is always rejected
+	token = newCSRFToken()
+	cookie := &http.Cookie{
+		Name:     c.CookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: c.CookieHttpOnly,
+		Secure:   req.Scheme() == "https",
+		SameSite: http.SameSiteLaxMode,
+	}
+	// the cookie is kept if the callback fails, so the client still receives its token
+	res.keepCookie(cookie)
+
+	if req.method != get && req.method != options {
+		return ""
+	}
+	return token
+}
+
+// returns the token sent with the request in the header or form field
+func (c *CSRF) submittedToken(req Request) string {
+	if token, exists := req.headers.get(c.HeaderName); exists {
+		return token
+	}
+
+	contentType, _ := req.headers.get("Content-Type")
+	if !strings.HasPrefix(strings.ToLower(contentType), "application/x-www-form-urlencoded") {
+		return ""
+	}
+
+	form, err := url.ParseQuery(req.Body())
+	if err != nil {
+		return ""
+	}
+	return form.Get(c.FieldName)
+}
+
+// checks the Origin header, or the Referer header if there is no Origin
+// header, against the server's own origin and the trusted origins
+func (c *CSRF) originAllowed(req Request) bool {
+	origin, exists := req.headers.get("Origin")
+	if !exists {
+		referer, hasReferer := req.headers.get("Referer")
+		if !hasReferer {
+			return true
+		}
+
+		refererUrl, err := url.Parse(referer)
+		if err != nil {
+			return false
+		}
+		origin = refererUrl.Scheme + "://" + refererUrl.Host
+	}
+
+	if strings.EqualFold(origin, req.Scheme()+"://"+req.Host()) {
+		return true
+	}
+
+	for _, trusted := range c.TrustedOrigins {
+		if strings.EqualFold(origin, trusted) {
+			return true
+		}
+	}
+	return false
+}
+
+func newCSRFSecret() []byte {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	return secret
+}
+
+func newCSRFToken() string {
+	b := make([]byte, csrfTokenBytes)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// returns the value of the named cookie from the request's Cookie header
+func requestCookie(req Request, name string) (string, bool) {
+	cookies, exists := req.headers.get("Cookie")
+	if !exists {
+		return "", false
+	}
+
+	for _, cookie := range strings.Split(cookies, ";") {
+		cookieName, value, found := strings.Cut(strings.TrimSpace(cookie), "=")
+		if found && cookieName == name {
+			return strings.Trim(value, `"`), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,177 @@
+package simplehttp
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// returns the value of the cookie set by the response
+func csrfCookieValue(res Response) string {
+	if len(res.cookies) == 0 {
+		return ""
+	}
+
+	value, _, _ := strings.Cut(strings.TrimPrefix(res.cookies[0], "csrf_token="), ";")
+	return value
+}
+
+func TestCSRF_DoubleSubmitCookie(t *testing.T) {
+	c := NewCSRF()
+
+	res, next := invokeMiddleware(c.Middleware, Request{method: get, headers: headers{}})
+	if next == nil || next.CSRFToken() == "" {
+		t.Fatalf("expected a safe request to be allowed with a new token")
+	}
+
+	token := next.CSRFToken()
+	if csrfCookieValue(res) != token || strings.Contains(res.cookies[0], "HttpOnly") ||
+		!strings.Contains(res.cookies[0], "SameSite=Lax") {
+		t.Fatalf("incorrect cookie. Actual %v", res.cookies)
+	}
+
+	res, next = invokeMiddleware(c.Middleware, Request{method: post, headers: headers{
+		"Cookie":       "csrf_token=" + token,
+		"X-CSRF-Token": token,
+	}})
+	if next == nil || len(res.cookies) != 0 {
+		t.Fatalf("expected the request with a matching header to be allowed. Actual status '%d'", res.statusCode)
+	}
+
+	_, next = invokeMiddleware(c.Middleware, Request{method: post, headers: headers{
+		"Cookie":       "session=abc; csrf_token=" + token,
+		"Content-Type": "application/x-www-form-urlencoded",
+	}, body: "name=alice&csrf_token=" + token})
+	if next == nil {
+		t.Fatalf("expected the request with a matching form field to be allowed")
+	}
+
+	c.CookieHttpOnly = true
+	res, _ = invokeMiddleware(c.Middleware, Request{method: get, headers: headers{}})
+	if len(res.cookies) != 1 || !strings.Contains(res.cookies[0], "HttpOnly") {
+		t.Fatalf("expected an HttpOnly cookie. Actual %v", res.cookies)
+	}
+}
+
+func TestCSRF_KeepsOtherCookies(t *testing.T) {
+	server := NewServer(0)
+	server.Use(NewCSRF().Middleware)
+	server.Get("/login", func(_ Request, res *Response) error {
+		return res.SetCookie(&http.Cookie{Name: "session", Value: "abc"})
+	})
+
+	response := sendRawRequest(&server, "GET /login HTTP/1.0"+doubleLineEnd)
+	if !strings.Contains(response, "Set-Cookie: csrf_token=") || !strings.Contains(response, "Set-Cookie: session=abc"+lineEnd) {
+		t.Fatalf("expected both cookies to be set. Actual '%s'", response)
+	}
+}
+
+func TestCSRF_KeepsCookieOnErrorResponses(t *testing.T) {
+	server := NewServer(0)
+	server.Use(NewCSRF().Middleware)
+	server.Get("/form", func(_ Request, res *Response) error {
+		res.SetCookie(&http.Cookie{Name: "session", Value: "abc"})
+		return errors.New("failed")
+	})
+
+	response := sendRawRequest(&server, "GET /form HTTP/1.0"+doubleLineEnd)
+	if !strings.HasPrefix(response, "HTTP/1.0 500") || !strings.Contains(response, "Set-Cookie: csrf_token=") {
+		t.Fatalf("expected the error response to set the token cookie. Actual '%s'", response)
+	}
+
+	if strings.Contains(response, "session=abc") {
+		t.Fatalf("expected cookies set by the callback to be dropped. Actual '%s'", response)
+	}
+}
+
+func TestCSRF_Rejected(t *testing.T) {
+	c := NewCSRF()
+	_, next := invokeMiddleware(c.Middleware, Request{method: get, headers: headers{}})
+	token := next.CSRFToken()
+	_, next = invokeMiddleware(c.Middleware, Request{method: get, headers: headers{}})
+	otherToken := next.CSRFToken()
+
+	rejected := map[string]headers{
+		"missing token":    {"Cookie": "csrf_token=" + token},
+		"mismatched token": {"Cookie": "csrf_token=" + token, "X-CSRF-Token": otherToken},
+		"missing cookie":   {"X-CSRF-Token": token},
+		"cross-origin":     {"Cookie": "csrf_token=" + token, "X-CSRF-Token": token, "Origin": "https://evil.com"},
+		"cross-origin referer": {"Cookie": "csrf_token=" + token, "X-CSRF-Token": token,
+			"Referer": "https://evil.com/page"},
+	}
+
+	for name, reqHeaders := range rejected {
+		res, next := invokeMiddleware(c.Middleware, Request{method: del, headers: reqHeaders})
+		if next != nil || res.statusCode != 403 {
+			t.Fatalf("expected the request with a %s to be rejected. Actual status '%d'", name, res.statusCode)
+		}
+	}
+}
+
+func TestCSRF_Origins(t *testing.T) {
+	c := NewCSRF()
+	c.TrustedOrigins = []string{"https://app.example.com"}
+	_, next := invokeMiddleware(c.Middleware, Request{method: get, headers: headers{}})
+	token := next.CSRFToken()
+
+	allowed := []headers{
+		{"Origin": "http://example.com"},
+		{"Origin": "https://app.example.com"},
+		{"Referer": "http://example.com/form?page=2"},
+	}
+
+	for _, reqHeaders := range allowed {
+		reqHeaders["Host"] = "example.com"
+		reqHeaders["Cookie"] = "csrf_token=" + token
+		reqHeaders["X-CSRF-Token"] = token
+		_, next := invokeMiddleware(c.Middleware, Request{method: put, headers: reqHeaders})
+		if next == nil {
+			t.Fatalf("expected the request to be allowed. Headers '%v'", reqHeaders)
+		}
+	}
+}
+
+func TestCSRF_SynchronizerToken(t *testing.T) {
+	c := NewCSRF()
+	c.Mode = SynchronizerToken
+	c.SessionKey = func(req Request) string {
+		session, _ := requestCookie(req, "session")
+		return session
+	}
+
+	res, next := invokeMiddleware(c.Middleware, Request{method: get, headers: headers{"Cookie": "session=abc"}})
+	token := next.CSRFToken()
+	if token == "" || len(res.cookies) != 0 {
+		t.Fatalf("expected a token without a cookie. Actual token '%s'", token)
+	}
+
+	_, next = invokeMiddleware(c.Middleware, Request{method: post, headers: headers{"Cookie": "session=abc", "X-CSRF-Token": token}})
+	if next == nil || next.CSRFToken() != token {
+		t.Fatalf("expected the session's token to be accepted")
+	}
+
+	_, next = invokeMiddleware(c.Middleware, Request{method: post, headers: headers{"Cookie": "session=other", "X-CSRF-Token": token}})
+	if next != nil {
+		t.Fatalf("expected another session's token to be rejected")
+	}
+
+	_, next = invokeMiddleware(c.Middleware, Request{method: post, headers: headers{"X-CSRF-Token": token}})
+	if next != nil {
+		t.Fatalf("expected a request without a session to be rejected")
+	}
+
+	other := NewCSRF()
+	other.Mode = SynchronizerToken
+	other.SessionKey = c.SessionKey
+	_, next = invokeMiddleware(other.Middleware, Request{method: post, headers: headers{"Cookie": "session=abc", "X-CSRF-Token": token}})
+	if next != nil {
+		t.Fatalf("expected a token derived with another secret to be rejected")
+	}
+
+	other.Secret = c.Secret
+	_, next = invokeMiddleware(other.Middleware, Request{method: post, headers: headers{"Cookie": "session=abc", "X-CSRF-Token": token}})
+	if next == nil {
+		t.Fatalf("expected a token derived with the same secret to be accepted")
+	}
+}
@@ -0,0 +1,117 @@
+package simplehttp
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const defaultMaxDecompressedBytes uint = 10 * 1024 * 1024 // 10 MB
+
+// Potential errors when decompressing a request's body
+type unsupportedEncodingError struct {
+	coding string
+}
+
+func (err unsupportedEncodingError) Error() string {
+	return fmt.Sprintf("unsupported Content-Encoding '%s'", err.coding)
+}
+
+type decompressedTooLargeError struct {
+	maxBytes uint
+}
+
+func (err decompressedTooLargeError) Error() string {
+	return fmt.Sprintf("decompressed request body exceeded the maximum of %d bytes", err.maxBytes)
+}
+
+// decompressRequest decodes the body of a request that has a Content-Encoding
+// header. Codings are removed in the reverse order they were applied. The
+// returned Request has the decoded body, no Content-Encoding header, and an
+// updated Content-Length header. The original message is still available from
+// [Request.RawMessage]. Reading stops once the decoded body exceeds maxBytes.
+func decompressRequest(req Request, maxBytes uint) (Request, error) {
+	encodingKey, contentEncoding := "", ""
+	for key, value := range req.headers {
+		if strings.EqualFold(key, "Content-Encoding") {
+			encodingKey, contentEncoding = key, value
+		}
+	}
+
+	if encodingKey == "" {
+		return req, nil
+	}
+
+	codings := strings.Split(contentEncoding, ",")
+	body := req.body
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+
+		var err error
+		body, err = decodeBody(body, coding, maxBytes)
+		if err != nil {
+			return Request{}, err
+		}
+	}
+
+	headers := make(headers)
+	for key, value := range req.headers {
+		if key != encodingKey {
+			headers[key] = value
+		}
+	}
+	headers["Content-Length"] = strconv.Itoa(len(body))
+
+	req.headers = headers
+	req.body = body
+	return req, nil
+}
+
+func decodeBody(body string, coding string, maxBytes uint) (string, error) {
+	var decoder io.Reader
+	var err error
+
+	switch coding {
+	case "identity", "":
+		return body, nil
+	case "gzip", "x-gzip":
+		decoder, err = gzip.NewReader(strings.NewReader(body))
+	case "deflate":
+		decoder, err = newDeflateReader(body)
+	default:
+		return "", unsupportedEncodingError{coding}
+	}
+
+	if err != nil {
+		return "", &invalidMessage{fmt.Sprintf("unable to decode %s request body: %v", coding, err)}
+	}
+
+	// read one byte past the limit to tell if it was exceeded
+	decoded, err := io.ReadAll(io.LimitReader(decoder, int64(maxBytes)+1))
+	if err != nil {
+		return "", &invalidMessage{fmt.Sprintf("unable to decode %s request body: %v", coding, err)}
+	}
+
+	if uint(len(decoded)) > maxBytes {
+		return "", decompressedTooLargeError{maxBytes}
+	}
+
+	return string(decoded), nil
+}
+
+// The deflate coding is defined as the zlib format, but some clients send
+// raw deflate data, so fall back to that if there is no zlib header.
+func newDeflateReader(body string) (io.Reader, error) {
+	reader := bufio.NewReader(strings.NewReader(body))
+	header, err := reader.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint(header[0])<<8|uint(header[1]))%31 == 0 {
+		return zlib.NewReader(reader)
+	}
+
+	return flate.NewReader(reader), nil
+}
@@ -0,0 +1,120 @@
+package simplehttp
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func gzipString(s string) string {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(s))
+	w.Close()
+	return buf.String()
+}
+
+func TestDecompressRequest_Gzip(t *testing.T) {
+	body := gzipString("hello world!")
+	req := Request{method: post, headers: headers{"Content-Encoding": "gzip", "Content-Length": "32"}, body: body}
+
+	decompressed, err := decompressRequest(req, 1024)
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+
+	if decompressed.body != "hello world!" {
+		t.Fatalf("incorrect body. Expected 'hello world!' | Actual '%s'", decompressed.body)
+	}
+
+	if _, exists := decompressed.headers["Content-Encoding"]; exists {
+		t.Fatalf("expected the Content-Encoding header to be removed")
+	}
+
+	if decompressed.headers["Content-Length"] != "12" {
+		t.Fatalf("incorrect Content-Length. Expected '12' | Actual '%s'", decompressed.headers["Content-Length"])
+	}
+
+	if req.headers["Content-Encoding"] != "gzip" {
+		t.Fatalf("the original request should not be modified")
+	}
+}
+
+func TestDecompressRequest_Deflate(t *testing.T) {
+	var zlibBuf, rawBuf bytes.Buffer
+	zw := zlib.NewWriter(&zlibBuf)
+	zw.Write([]byte("zlib body"))
+	zw.Close()
+
+	fw, _ := flate.NewWriter(&rawBuf, flate.DefaultCompression)
+	fw.Write([]byte("raw body"))
+	fw.Close()
+
+	bodies := map[string]string{zlibBuf.String(): "zlib body", rawBuf.String(): "raw body"}
+	for body, expected := range bodies {
+		req := Request{method: post, headers: headers{"content-encoding": "deflate"}, body: body}
+		decompressed, err := decompressRequest(req, 1024)
+		if err != nil {
+			t.Fatalf("did not expect an error but received: %v", err)
+		}
+
+		if decompressed.body != expected {
+			t.Fatalf("incorrect body. Expected '%s' | Actual '%s'", expected, decompressed.body)
+		}
+	}
+}
+
+func TestDecompressRequest_MultipleCodings(t *testing.T) {
+	body := gzipString(gzipString("twice"))
+	req := Request{method: post, headers: headers{"Content-Encoding": "gzip, identity, gzip"}, body: body}
+
+	decompressed, err := decompressRequest(req, 1024)
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+
+	if decompressed.body != "twice" {
+		t.Fatalf("incorrect body. Expected 'twice' | Actual '%s'", decompressed.body)
+	}
+}
+
+func TestDecompressRequest_Errors(t *testing.T) {
+	req := Request{method: post, headers: headers{"Content-Encoding": "br"}, body: "?"}
+	_, err := decompressRequest(req, 1024)
+	if !errors.As(err, &unsupportedEncodingError{}) {
+		t.Fatalf("expected an unsupportedEncodingError | Actual %v", err)
+	}
+
+	req = Request{method: post, headers: headers{"Content-Encoding": "gzip"}, body: gzipString(strings.Repeat("a", 2048))}
+	_, err = decompressRequest(req, 1024)
+	if !errors.As(err, &decompressedTooLargeError{}) {
+		t.Fatalf("expected a decompressedTooLargeError | Actual %v", err)
+	}
+
+	req = Request{method: post, headers: headers{"Content-Encoding": "gzip"}, body: "not gzip"}
+	_, err = decompressRequest(req, 1024)
+	invalidErr := &invalidMessage{}
+	if !errors.As(err, &invalidErr) {
+		t.Fatalf("expected an invalidMessage | Actual %v", err)
+	}
+}
+
+func TestServer_UnsupportedContentEncoding(t *testing.T) {
+	server := NewServer(0)
+	server.Post("/ingest", dummyCallback)
+
+	uri, _ := url.ParseRequestURI("/ingest")
+	req := Request{method: post, uri: *uri, headers: headers{"Content-Encoding": "compress"}, body: "?"}
+	res := newResponse()
+	server.dispatch(req, &res)
+
+	if res.statusCode != 415 || res.reasonPhrase != "Unsupported Media Type" {
+		t.Fatalf("incorrect status. Expected '415 Unsupported Media Type' | Actual '%d %s'",
+			res.statusCode, res.reasonPhrase)
+	}
+}
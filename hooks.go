@@ -0,0 +1,116 @@
+package simplehttp
+
+import (
+	"io"
+	"time"
+)
+
+// Hooks are optional functions that the [Server] calls at points in the
+// lifecycle of every request it handles. Hooks are registered using
+// [Server.AddHooks], and any function that is nil is skipped.
+// Hooks are called from the goroutine handling the connection, so they
+// must be safe to call concurrently and should return quickly.
+type Hooks struct {
+	// ConnectionOpened is called when the server accepts a connection.
+	ConnectionOpened func(remoteAddr string)
+	// ConnectionClosed is called when the server closes a connection.
+	ConnectionClosed func(remoteAddr string)
+	// ReadFailed is called when a request could not be read from a
+	// connection, for example because it was malformed or too large.
+	ReadFailed func(remoteAddr string, err error)
+	// RequestStarted is called once a request has been read, before it is
+	// passed to the middleware and callback.
+	RequestStarted func(Request)
+	// RequestCompleted is called once a response has been sent to the client.
+	RequestCompleted func(CompletedRequest)
+}
+
+// CompletedRequest describes a request that the [Server] has finished handling.
+type CompletedRequest struct {
+	// Request is the request that was received.
+	Request Request
+	// Route is the path of the callback that handled the request, as it
+	// was registered. Paths handled by a prefix, such as [Server.Static], end
+	// in "/*". Route is empty if no callback was registered for the path.
+	Route string
+	// StatusCode is the Status-Code of the response that was sent.
+	StatusCode uint
+	// BytesRead is the number of bytes in the request that was received.
+	BytesRead int64
+	// BytesWritten is the number of bytes in the body of the response that was sent.
+	BytesWritten int64
+	// Start is the time the server started reading the request.
+	Start time.Time
+	// Duration is the time taken to read the request and send the response.
+	Duration time.Duration
+}
+
+// Registers hooks that will be called during the lifecycle of every request
+// handled by the Server. Hooks are called in the order they were registered.
+// See [Hooks] for details.
+func (s *Server) AddHooks(hooks Hooks) {
+	s.hooks = append(s.hooks, hooks)
+}
+
+func (s *Server) connectionOpened(remoteAddr string) {
+	for _, hooks := range s.hooks {
+		if hooks.ConnectionOpened != nil {
+			hooks.ConnectionOpened(remoteAddr)
+		}
+	}
+}
+
+func (s *Server) connectionClosed(remoteAddr string) {
+	for _, hooks := range s.hooks {
+		if hooks.ConnectionClosed != nil {
+			hooks.ConnectionClosed(remoteAddr)
+		}
+	}
+}
+
+func (s *Server) readFailed(remoteAddr string, err error) {
+	for _, hooks := range s.hooks {
+		if hooks.ReadFailed != nil {
+			hooks.ReadFailed(remoteAddr, err)
+		}
+	}
+}
+
+func (s *Server) requestStarted(req Request) {
+	for _, hooks := range s.hooks {
+		if hooks.RequestStarted != nil {
+			hooks.RequestStarted(req)
+		}
+	}
+}
+
+// builds the CompletedRequest for a response that has been sent and passes it to the hooks
+func (s *Server) requestCompleted(req Request, res Response, bytesWritten int64, start time.Time) {
+	completed := CompletedRequest{
+		Request:      req,
+		Route:        s.callbackMap.route(req.method, req.Path()),
+		StatusCode:   res.statusCode,
+		BytesRead:    int64(len(req.rawMessage)),
+		BytesWritten: bytesWritten,
+		Start:        start,
+		Duration:     time.Since(start),
+	}
+
+	for _, hooks := range s.hooks {
+		if hooks.RequestCompleted != nil {
+			hooks.RequestCompleted(completed)
+		}
+	}
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,352 @@
+package simplehttp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WrapHandler adapts an [http.Handler] so that it can be registered as a
+// callback on the [Server]. The [Request] is translated into an [*http.Request]
+// and everything the handler writes to its [http.ResponseWriter] (status,
+// headers, and body) is copied onto the [*Response]. If the handler flushes
+// the writer using [http.Flusher], the response is streamed to the client
+// (see [Response.Stream]) and any further writes are sent immediately.
+//
+// If the handler writes an invalid status code, which would cause net/http to
+// panic, the error is returned so the Server sends a 500 Internal Server Error
+// response instead.
+//
+// This allows existing net/http handlers, such as those in net/http/pprof,
+// to be served by a Server.
+func WrapHandler(handler http.Handler) CallbackFunc {
+	return func(req Request, res *Response) error {
+		httpReq, err := req.httpRequest()
+		if err != nil {
+			return err
+		}
+
+		w := &responseWriter{res: res, header: make(http.Header)}
+		handler.ServeHTTP(w, httpReq)
+		w.finish()
+		return w.err
+	}
+}
+
+// Handler returns an [http.Handler] that serves requests using the callbacks
+// registered on the Server. This allows simplehttp routes to be mounted inside
+// an existing [http.ServeMux] or wrapped by net/http middleware. Requests
+// with a method that is not supported by the Server receive a
+// 501 Not Implemented response. [Server.MaxRequestBytes] limits the size of
+// the request body. Handler panics if [Server.TrustedProxies] is invalid.
+func (s *Server) Handler() http.Handler {
+	err := s.loadTrustedProxies()
+	if err != nil {
+		panic(err)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		req, err := newRequestFromHttp(r, s.MaxRequestBytes)
+		if err != nil {
+			s.log(LevelWarn, "Unable to translate the request",
+				LogField{"remote_addr", r.RemoteAddr}, LogField{"error", err})
+
+			maxBytesErr := &http.MaxBytesError{}
+			if errors.As(err, &maxBytesErr) {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+			} else {
+				w.WriteHeader(http.StatusNotImplemented)
+			}
+			return
+		}
+
+		req = s.resolveClient(req)
+		req = s.assignRequestID(req, newRequestID())
+		req = s.SecurityHeaders.addNonce(req)
+		req, trace := s.startTrace(req, start)
+		trace.phase("read", start, time.Now())
+		s.requestStarted(req)
+		res := newResponse()
+		res.views = s.views
+		streamed := &countingWriter{w: flushWriter{w}}
+		res.startStream = func(res *Response) (io.Writer, error) {
+			s.finishHeaders(req, res)
+			writeHttpHead(w, *res)
+			return streamed, nil
+		}
+		res.clientGone = r.Context().Done
+
+		s.tracedDispatch(req, &res, trace)
+
+		writeStart := time.Now()
+		if res.upgrade != nil && res.statusCode == StatusSwitchingProtocols {
+			s.serveHijacked(w, req, res, start)
+			trace.phase("write", writeStart, time.Now())
+			trace.end(res)
+			return
+		}
+
+		if res.stream != nil {
+			err = res.closeStream()
+			if err != nil {
+				s.log(LevelError, "Unable to finish streaming the response",
+					LogField{"remote_addr", r.RemoteAddr}, LogField{"request_id", req.id}, LogField{"error", err})
+			}
+			trace.phase("write", writeStart, time.Now())
+			trace.end(res)
+			s.requestCompleted(req, res, streamed.n, start)
+			return
+		}
+
+		s.finishHeaders(req, &res)
+		writeHttpHead(w, res)
+		io.WriteString(w, res.body)
+		trace.phase("write", writeStart, time.Now())
+		trace.end(res)
+		s.requestCompleted(req, res, int64(len(res.body)), start)
+	})
+}
+
+// takes over the connection from the http.ResponseWriter to send a 101
+// Switching Protocols response, and passes the connection to res.upgrade
+func (s *Server) serveHijacked(w http.ResponseWriter, req Request, res Response, start time.Time) {
+	requestID := LogField{"request_id", req.id}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		s.log(LevelError, "Unable to upgrade the connection because it cannot be hijacked", requestID)
+		s.writeUpgradeFailure(w, req, start)
+		return
+	}
+
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		s.log(LevelError, "Unable to upgrade the connection", requestID, LogField{"error", err})
+		s.writeUpgradeFailure(w, req, start)
+		return
+	}
+	defer conn.Close()
+
+	s.finishHeaders(req, &res)
+	_, err = conn.Write([]byte(res.head()))
+	s.requestCompleted(req, res, int64(len(res.head())), start)
+	if err == nil {
+		conn.SetDeadline(time.Time{})
+		err = res.upgrade(conn, buffered.Reader)
+	}
+	if err != nil {
+		s.log(LevelError, "The upgraded connection returned an error", requestID, LogField{"error", err})
+	}
+}
+
+// sends a 500 Internal Server Error response when the connection could not be
+// taken over to switch protocols, and completes the request
+func (s *Server) writeUpgradeFailure(w http.ResponseWriter, req Request, start time.Time) {
+	res := new500StatusResponse()
+	s.finishHeaders(req, &res)
+	writeHttpHead(w, res)
+	io.WriteString(w, res.body)
+	s.requestCompleted(req, res, int64(len(res.body)), start)
+}
+
+// converts a Request into the equivalent *http.Request
+func (r Request) httpRequest() (*http.Request, error) {
+	httpReq, err := http.NewRequestWithContext(
+		r.Context(), r.Method(), r.uri.String(), strings.NewReader(r.body))
+	if err != nil {
+		return nil, err
+	}
+
+	if major, minor, ok := http.ParseHTTPVersion(r.httpVersion); ok {
+		httpReq.Proto = r.httpVersion
+		httpReq.ProtoMajor = major
+		httpReq.ProtoMinor = minor
+	}
+
+	for key, value := range r.headers {
+		httpReq.Header.Set(key, value)
+	}
+
+	httpReq.Host = httpReq.Header.Get("Host")
+	httpReq.Header.Del("Host")
+	httpReq.ContentLength = int64(len(r.body))
+	httpReq.RequestURI = r.uri.String()
+
+	return httpReq, nil
+}
+
+// converts an *http.Request into the equivalent Request. The body is read
+// entirely, and an error is returned if it is larger than maxBytes.
+func newRequestFromHttp(r *http.Request, maxBytes uint) (Request, error) {
+	method, err := parseHttpMethod(r.Method)
+	if err != nil {
+		return Request{}, err
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, int64(maxBytes)))
+	if err != nil {
+		return Request{}, err
+	}
+
+	headers := make(headers)
+	for key, values := range r.Header {
+		headers[key] = strings.Join(values, ", ")
+	}
+
+	if r.Host != "" {
+		headers["Host"] = r.Host
+	}
+
+	if len(body) > 0 {
+		headers["Content-Length"] = strconv.Itoa(len(body))
+	}
+
+	req := Request{
+		method:      method,
+		uri:         *r.URL,
+		httpVersion: r.Proto,
+		headers:     headers,
+		body:        string(body),
+		ctx:         r.Context(),
+		remoteAddr:  r.RemoteAddr,
+	}
+	if r.TLS != nil {
+		req.scheme = "https"
+	}
+	req.rawMessage = req.String()
+
+	return req, nil
+}
+
+// writes the status and headers of res to w. The Connection header
+// is skipped since net/http manages the connection itself.
+func writeHttpHead(w http.ResponseWriter, res Response) {
+	for key, value := range res.headers {
+		if key == "Connection" {
+			continue
+		}
+		w.Header().Set(key, value)
+	}
+
+	for _, cookie := range res.cookies {
+		w.Header().Add("Set-Cookie", cookie)
+	}
+
+	w.WriteHeader(int(res.statusCode))
+}
+
+// flushWriter flushes the underlying http.ResponseWriter after every write
+// so that streamed data reaches the client immediately.
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (fw flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if flusher, ok := fw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return n, err
+}
+
+// responseWriter implements http.ResponseWriter on top of a Response.
+// The body is buffered until the handler returns, unless the handler calls
+// Flush, in which case the response starts streaming.
+type responseWriter struct {
+	res         *Response
+	header      http.Header
+	wroteHeader bool
+	body        strings.Builder
+	stream      io.Writer
+	// err is set if the handler writes an invalid status code
+	err error
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+
+	w.wroteHeader = true
+	err := w.res.SetStatusWithReason(uint(statusCode), getReasonPhrase(uint(statusCode)))
+	if err != nil {
+		w.err = fmt.Errorf("invalid WriteHeader code %d: %w", statusCode, err)
+	}
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.stream != nil {
+		return w.stream.Write(p)
+	}
+
+	return w.body.Write(p)
+}
+
+// Flush starts streaming the response. If the Response is not attached to
+// a client connection, the body continues to be buffered.
+func (w *responseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.stream != nil || w.err != nil {
+		return
+	}
+
+	w.copyHeaders()
+	stream, err := w.res.Stream()
+	if err != nil {
+		return
+	}
+
+	w.stream = stream
+	if w.body.Len() > 0 {
+		io.WriteString(stream, w.body.String())
+		w.body.Reset()
+	}
+}
+
+// copies the buffered body and headers onto the Response once the handler returns
+func (w *responseWriter) finish() {
+	if w.stream != nil {
+		return
+	}
+
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	w.copyHeaders()
+	body := w.body.String()
+	if _, exists := w.res.headers["Content-Type"]; !exists && body != "" {
+		w.res.headers["Content-Type"] = http.DetectContentType([]byte(body))
+	}
+
+	w.res.body = body
+	w.res.headers["Content-Length"] = strconv.Itoa(len(body))
+}
+
+func (w *responseWriter) copyHeaders() {
+	for key, values := range w.header {
+		if key == "Set-Cookie" {
+			// cookies are removed once copied, so they are only added once
+			w.res.cookies = append(w.res.cookies, values...)
+			w.header.Del(key)
+			continue
+		}
+
+		w.res.headers[key] = strings.Join(values, ", ")
+	}
+}
@@ -0,0 +1,198 @@
+package simplehttp
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestWrapHandler(t *testing.T) {
+	uri, _ := url.ParseRequestURI("/wrapped?key=value")
+	req := Request{
+		method:      post,
+		uri:         *uri,
+		httpVersion: "HTTP/1.0",
+		headers:     headers{"Host": "client:8080", "X-Custom": "custom"},
+		body:        "request body",
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != "POST" || r.URL.Path != "/wrapped" || r.URL.Query().Get("key") != "value" {
+			t.Errorf("incorrect request line. Actual '%s %s'", r.Method, r.URL)
+		}
+		if r.Host != "client:8080" || r.Header.Get("X-Custom") != "custom" {
+			t.Errorf("incorrect request headers. Actual %v", r.Header)
+		}
+		if string(body) != "request body" {
+			t.Errorf("incorrect request body. Expected 'request body' | Actual '%s'", body)
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, "hello from net/http")
+	})
+
+	res := newResponse()
+	err := WrapHandler(handler)(req, &res)
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+
+	if res.statusCode != 201 {
+		t.Fatalf("incorrect status code. Expected '201' | Actual '%d'", res.statusCode)
+	}
+
+	if res.body != "hello from net/http" || res.headers["Content-Length"] != "19" {
+		t.Fatalf("incorrect body. Actual '%s' with Content-Length '%s'",
+			res.body, res.headers["Content-Length"])
+	}
+
+	if res.headers["Content-Type"] != "text/plain" {
+		t.Fatalf("incorrect Content-Type. Expected 'text/plain' | Actual '%s'", res.headers["Content-Type"])
+	}
+}
+
+func TestWrapHandler_Cookies(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "first", Value: "1"})
+		http.SetCookie(w, &http.Cookie{Name: "second", Value: "2"})
+	})
+
+	res := newResponse()
+	WrapHandler(handler)(Request{uri: url.URL{Path: "/"}, headers: headers{}}, &res)
+
+	expected := "Set-Cookie: first=1" + lineEnd + "Set-Cookie: second=2" + doubleLineEnd
+	if len(res.cookies) != 2 || !strings.HasSuffix(res.head(), expected) {
+		t.Fatalf("expected each cookie to be sent separately. Actual '%s'", res.head())
+	}
+}
+
+func TestWrapHandler_InvalidStatus(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(1000)
+		w.(http.Flusher).Flush()
+		io.WriteString(w, "body")
+	})
+
+	var client bytes.Buffer
+	res := newResponse()
+	res.startStream = func(res *Response) (io.Writer, error) {
+		return &client, nil
+	}
+
+	err := WrapHandler(handler)(Request{uri: url.URL{Path: "/"}, headers: headers{}}, &res)
+	if err == nil {
+		t.Fatalf("expected an error for an invalid status code, but it was nil")
+	}
+
+	if res.stream != nil || client.Len() != 0 {
+		t.Fatalf("expected the response not to be streamed. Actual '%s'", client.String())
+	}
+}
+
+func TestWrapHandler_Streaming(t *testing.T) {
+	var client bytes.Buffer
+	res := newResponse()
+	res.startStream = func(res *Response) (io.Writer, error) {
+		client.WriteString(res.head())
+		return &client, nil
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "first,")
+		w.(http.Flusher).Flush()
+
+		if !strings.HasSuffix(client.String(), "first,") {
+			t.Errorf("expected data to be sent after flushing. Actual '%s'", client.String())
+		}
+		io.WriteString(w, "second")
+	})
+
+	WrapHandler(handler)(Request{method: get, uri: url.URL{Path: "/"}}, &res)
+
+	if !strings.HasSuffix(client.String(), doubleLineEnd+"first,second") {
+		t.Fatalf("incorrect streamed response. Actual '%s'", client.String())
+	}
+
+	if strings.Contains(client.String(), "Content-Length") {
+		t.Fatalf("a streamed response should not have a Content-Length")
+	}
+}
+
+func TestServer_Handler(t *testing.T) {
+	server := NewServer(0)
+	server.Post("/echo", func(req Request, res *Response) error {
+		res.SetStatus(202)
+		res.SetHeader("Echo-Header", req.Headers()["X-Custom"])
+		res.SetHtml(req.Body())
+		return nil
+	})
+
+	httpReq := httptest.NewRequest("POST", "/echo", strings.NewReader("<p>echo</p>"))
+	httpReq.Header.Set("X-Custom", "custom")
+	recorder := httptest.NewRecorder()
+
+	server.Handler().ServeHTTP(recorder, httpReq)
+
+	if recorder.Code != 202 {
+		t.Fatalf("incorrect status code. Expected '202' | Actual '%d'", recorder.Code)
+	}
+
+	if recorder.Body.String() != "<p>echo</p>" {
+		t.Fatalf("incorrect body. Expected '<p>echo</p>' | Actual '%s'", recorder.Body.String())
+	}
+
+	if recorder.Header().Get("Echo-Header") != "custom" {
+		t.Fatalf("incorrect header. Expected 'custom' | Actual '%s'", recorder.Header().Get("Echo-Header"))
+	}
+}
+
+func TestServer_Handler_NotFound(t *testing.T) {
+	server := NewServer(0)
+	recorder := httptest.NewRecorder()
+
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/missing", nil))
+
+	if recorder.Code != 404 {
+		t.Fatalf("incorrect status code. Expected '404' | Actual '%d'", recorder.Code)
+	}
+}
+
+func TestServer_Handler_UpgradeWithoutHijacker(t *testing.T) {
+	server, _ := newEchoServer()
+	metrics := NewMetrics()
+	server.AddHooks(metrics.Hooks())
+	recorder := httptest.NewRecorder()
+
+	req := httptest.NewRequest("GET", "/echo", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Key", testWebSocketKey)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	server.Handler().ServeHTTP(recorder, req)
+
+	if recorder.Code != 500 {
+		t.Fatalf("incorrect status code. Expected '500' | Actual '%d'", recorder.Code)
+	}
+
+	if !strings.Contains(metrics.render(), "simplehttp_requests_in_flight 0\n") ||
+		!strings.Contains(metrics.render(), `status="500"`) {
+		t.Fatalf("expected the request to be completed. Actual '%s'", metrics.render())
+	}
+}
+
+func TestServer_Handler_UnsupportedMethod(t *testing.T) {
+	server := NewServer(0)
+	recorder := httptest.NewRecorder()
+
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest("TRACE", "/", nil))
+
+	if recorder.Code != 501 {
+		t.Fatalf("incorrect status code. Expected '501' | Actual '%d'", recorder.Code)
+	}
+}
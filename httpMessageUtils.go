@@ -2,6 +2,8 @@ package simplehttp
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -31,23 +33,43 @@ type headers map[string]string
 func (h headers) String() string {
 	// TODO: HTTP/1.0 RFC says "it is 'good practice' to send General-Header fields first,
 	//   followed by Request-Header or Response-Header fields prior to the Entity-Header fields"
-	ret := ""
-	headerCount := len(h)
-	i := 1
+	lines := make([]string, 0, len(h))
 	for k, v := range h {
-		if i == headerCount {
-			ret += fmt.Sprintf("%s: %s", k, v)
-		} else {
-			ret += fmt.Sprintf("%s: %s%s", k, v, lineEnd)
+		// a line break would end the header early, and the rest of it would be
+		// read as another header, so headers containing one are never sent
+		if strings.ContainsAny(k, "\r\n") || strings.ContainsAny(v, "\r\n") {
+			continue
 		}
-		i++
+
+		lines = append(lines, fmt.Sprintf("%s: %s", k, v))
 	}
 
-	return ret
+	return strings.Join(lines, lineEnd)
+}
+
+// returns the value of the header with the provided key. Header
+// field names are case-insensitive, so an exact match is tried first
+// before falling back to a case-insensitive comparison.
+func (h headers) get(key string) (string, bool) {
+	if value, exists := h[key]; exists {
+		return value, true
+	}
+
+	for k, v := range h {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+
+	return "", false
 }
 
 func parseHeaders(message string) (map[string]string, error) {
 	headers := make(map[string]string)
+	if message == "" {
+		return headers, nil
+	}
+
 	lines := strings.Split(message, lineEnd)
 
 	for _, line := range lines {
@@ -75,41 +97,70 @@ func formatHttpDate(time time.Time) string {
 	return fmt.Sprintf("%s, %s GMT", dayName, dateAndTime)
 }
 
-func getReasonPhrase(status uint) string {
-	switch status {
-	case 200:
-		return "OK"
-	case 201:
-		return "Created"
-	case 202:
-		return "Accepted"
-	case 204:
-		return "No Content"
-	case 300:
-		return "Multiple Choices"
-	case 301:
-		return "Moved Permanently"
-	case 302:
-		return "Moved Temporarily"
-	case 304:
-		return "Not Modified"
-	case 400:
-		return "Bad Request"
-	case 401:
-		return "Unauthorized"
-	case 403:
-		return "Forbidden"
-	case 404:
-		return "Not Found"
-	case 500:
-		return "Internal Server Error"
-	case 501:
-		return "Not Implemented"
-	case 502:
-		return "Bad Gateway"
-	case 503:
-		return "Service Unavailable"
-	default:
-		return "unknown"
+// an element of a header whose value is a list of weighted values, such as
+// Accept-Encoding: gzip;q=1.0, identity;q=0.5
+type qualityValue struct {
+	value   string
+	params  map[string]string
+	quality float64
+}
+
+// parses a comma separated list of values with optional parameters and
+// quality weights. Elements without a q parameter have a quality of 1.
+// Values are lower-cased, and the result is sorted by descending quality,
+// keeping the original order for values of equal quality.
+func parseQualityList(list string) []qualityValue {
+	values := make([]qualityValue, 0)
+
+	for _, element := range strings.Split(list, ",") {
+		parts := strings.Split(element, ";")
+		value := strings.ToLower(strings.TrimSpace(parts[0]))
+		if value == "" {
+			continue
+		}
+
+		qv := qualityValue{value: value, params: make(map[string]string), quality: 1}
+		for _, param := range parts[1:] {
+			key, paramValue, _ := strings.Cut(param, "=")
+			key = strings.ToLower(strings.TrimSpace(key))
+			paramValue = strings.Trim(strings.TrimSpace(paramValue), `"`)
+
+			if key != "q" {
+				qv.params[key] = paramValue
+				continue
+			}
+
+			quality, err := strconv.ParseFloat(paramValue, 64)
+			if err == nil && quality >= 0 && quality <= 1 {
+				qv.quality = quality
+			}
+		}
+
+		values = append(values, qv)
 	}
+
+	sort.SliceStable(values, func(i, j int) bool {
+		return values[i].quality > values[j].quality
+	})
+
+	return values
+}
+
+// parses a date in any of the three formats HTTP/1.0 allows:
+// IMF-fixdate, RFC 850, and ANSI C's asctime()
+func parseHttpDate(date string) (time.Time, error) {
+	formats := []string{
+		"Mon, 02 Jan 2006 15:04:05 GMT",
+		"Monday, 02-Jan-06 15:04:05 GMT",
+		"Mon Jan _2 15:04:05 2006",
+	}
+
+	for _, format := range formats {
+		parsed, err := time.Parse(format, strings.TrimSpace(date))
+		if err == nil {
+			return parsed, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unable to parse HTTP date: `%s`", date)
 }
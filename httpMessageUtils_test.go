@@ -58,3 +58,89 @@ func TestParseHeaders(t *testing.T) {
 		t.Fatalf("incorrect header value. Expected 'extra-whitespace' | Actual '%s'", headers["Header-4"])
 	}
 }
+
+func TestParseHttpDate(t *testing.T) {
+	expected, _ := time.Parse(time.RFC3339, "1994-11-06T08:49:37Z")
+	dates := []string{
+		"Sun, 06 Nov 1994 08:49:37 GMT",
+		"Sunday, 06-Nov-94 08:49:37 GMT",
+		"Sun Nov  6 08:49:37 1994",
+	}
+
+	for _, date := range dates {
+		actual, err := parseHttpDate(date)
+		if err != nil {
+			t.Fatalf("did not expect an error, but received the following: %v", err)
+		}
+		if !actual.Equal(expected) {
+			t.Fatalf("expected '%v' | actual '%v'", expected, actual)
+		}
+	}
+
+	_, err := parseHttpDate("yesterday")
+	if err == nil {
+		t.Fatalf("expected an error, but it was nil")
+	}
+}
+
+func TestHeadersGet(t *testing.T) {
+	h := headers{"Content-Type": "text/html", "x-lowercase": "value"}
+
+	value, exists := h.get("content-type")
+	if !exists || value != "text/html" {
+		t.Fatalf("incorrect header value. Expected 'text/html' | Actual '%s'", value)
+	}
+
+	value, exists = h.get("X-Lowercase")
+	if !exists || value != "value" {
+		t.Fatalf("incorrect header value. Expected 'value' | Actual '%s'", value)
+	}
+
+	_, exists = h.get("Missing")
+	if exists {
+		t.Fatalf("did not expect to find a missing header")
+	}
+}
+
+func TestParseQualityList(t *testing.T) {
+	values := parseQualityList("text/html;level=1, application/json;q=0.5, */*;q=0.1, Text/Plain")
+	expected := []string{"text/html", "text/plain", "application/json", "*/*"}
+
+	if len(values) != len(expected) {
+		t.Fatalf("expected %d values | Actual %v", len(expected), values)
+	}
+
+	for i, value := range values {
+		if value.value != expected[i] {
+			t.Fatalf("incorrect value. Expected '%s' | Actual '%s'", expected[i], value.value)
+		}
+	}
+
+	if values[0].params["level"] != "1" || values[2].quality != 0.5 {
+		t.Fatalf("incorrect parameters. Actual %v", values)
+	}
+}
+
+func TestParseHeaders_Empty(t *testing.T) {
+	headers, err := parseHeaders("")
+	if err != nil {
+		t.Fatalf("did not expect an error, but received the following: %v", err)
+	}
+
+	if len(headers) != 0 {
+		t.Fatalf("expected no headers, but found %d", len(headers))
+	}
+}
+
+func TestHeadersString_SkipsLineBreaks(t *testing.T) {
+	h := headers{
+		"Location":        "/home",
+		"X-Injected":      "a\r\nSet-Cookie: pwned=1",
+		"X-Bad\nKey":      "value",
+		"X-Bare-Carriage": "a\rb",
+	}
+
+	if h.String() != "Location: /home" {
+		t.Fatalf("incorrect headers. Expected 'Location: /home' | Actual '%s'", h.String())
+	}
+}
@@ -5,10 +5,11 @@ import (
 )
 
 const (
-	get    = iota
-	post   = iota
-	put    = iota
-	delete = iota
+	get     = iota
+	post    = iota
+	put     = iota
+	del     = iota
+	options = iota
 )
 
 func parseHttpMethod(method string) (uint, error) {
@@ -20,7 +21,9 @@ func parseHttpMethod(method string) (uint, error) {
 	case "PUT":
 		return put, nil
 	case "DELETE":
-		return delete, nil
+		return del, nil
+	case "OPTIONS":
+		return options, nil
 	default:
 		return 0, fmt.Errorf("unsupported HTTP method")
 	}
@@ -34,8 +37,10 @@ func getHttpMethodString(method uint) string {
 		return "POST"
 	case put:
 		return "PUT"
-	case delete:
+	case del:
 		return "DELETE"
+	case options:
+		return "OPTIONS"
 	default:
 		return ""
 	}
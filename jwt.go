@@ -0,0 +1,489 @@
+package simplehttp
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JWT signature algorithms supported by a [JWTValidator].
+const (
+	HS256 string = "HS256"
+	RS256 string = "RS256"
+	ES256 string = "ES256"
+)
+
+const defaultJWTClockSkew time.Duration = time.Minute
+
+// JWTClaims are the claims in the payload of a JSON Web Token. Numeric claims
+// are decoded as float64, as with [encoding/json].
+type JWTClaims map[string]any
+
+// Returns the value of the named claim if it is a string.
+func (c JWTClaims) String(name string) string {
+	value, _ := c[name].(string)
+	return value
+}
+
+// Returns the "sub" (subject) claim.
+func (c JWTClaims) Subject() string {
+	return c.String("sub")
+}
+
+// Returns the "iss" (issuer) claim.
+func (c JWTClaims) Issuer() string {
+	return c.String("iss")
+}
+
+// Returns the "aud" (audience) claim, which may be a single string or a list.
+func (c JWTClaims) Audience() []string {
+	switch aud := c["aud"].(type) {
+	case string:
+		return []string{aud}
+	case []any:
+		audience := make([]string, 0, len(aud))
+		for _, value := range aud {
+			if s, ok := value.(string); ok {
+				audience = append(audience, s)
+			}
+		}
+		return audience
+	default:
+		return nil
+	}
+}
+
+// Returns the time of the named NumericDate claim, such as "exp". The second
+// return value is false if the claim is not present or is not a number.
+func (c JWTClaims) Time(name string) (time.Time, bool) {
+	seconds, ok := c[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+// Returns the claims of the JSON Web Token that authenticated the request.
+// The second return value is false if the request was not authenticated by
+// a [JWTValidator].
+func (r Request) Claims() (JWTClaims, bool) {
+	principal, ok := r.Principal()
+	if !ok {
+		return nil, false
+	}
+
+	claims, ok := principal.Details.(JWTClaims)
+	return claims, ok
+}
+
+// JWTKey is a key used to verify the signatures of JSON Web Tokens.
+type JWTKey struct {
+	// ID identifies the key, and is matched against the "kid" header of tokens.
+	// It may be empty if the key set only contains one key per algorithm.
+	ID string
+	// Algorithm is the algorithm the key is used with: [HS256], [RS256], or [ES256].
+	// Tokens signed with any other algorithm are not verified with the key.
+	Algorithm string
+	// Key is the secret []byte for HS256, an *rsa.PublicKey for RS256, or
+	// an *ecdsa.PublicKey using the P-256 curve for ES256.
+	Key any
+}
+
+// JWTKeySet is a set of keys used to verify JSON Web Tokens. Keys can be
+// added and removed while the server is running, so keys can be rotated by
+// adding the new key before tokens are signed with it, and removing the old
+// key once tokens signed with it have expired.
+// A JWTKeySet should only be created using the [NewJWTKeySet] or
+// [LoadJWKSFile] methods. It is safe to use concurrently.
+type JWTKeySet struct {
+	mutex sync.RWMutex
+	keys  []JWTKey
+}
+
+// Creates a new [JWTKeySet] containing the provided keys.
+func NewJWTKeySet(keys ...JWTKey) *JWTKeySet {
+	return &JWTKeySet{keys: append([]JWTKey{}, keys...)}
+}
+
+// Reads a JSON Web Key Set, as described by RFC 7517, from the file at path
+// and returns a [JWTKeySet] containing its keys. See [JWTKeySet.SetJWKS].
+func LoadJWKSFile(path string) (*JWTKeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ks := NewJWTKeySet()
+	err = ks.SetJWKS(data)
+	if err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Adds a key to the set, replacing any key with the same ID.
+func (ks *JWTKeySet) Add(key JWTKey) {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+
+	for i, existing := range ks.keys {
+		if existing.ID == key.ID {
+			ks.keys[i] = key
+			return
+		}
+	}
+	ks.keys = append(ks.keys, key)
+}
+
+// Removes the key with the provided ID from the set.
+func (ks *JWTKeySet) Remove(id string) {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+
+	keys := make([]JWTKey, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		if key.ID != id {
+			keys = append(keys, key)
+		}
+	}
+	ks.keys = keys
+}
+
+// Replaces every key in the set with the keys in a JSON Web Key Set, as
+// described by RFC 7517. RSA keys, EC keys using the P-256 curve, and
+// symmetric ("oct") keys are supported, and other keys are skipped. If the
+// data cannot be parsed, an error is returned and the set is not changed.
+// To pick up changes to a JWKS file, read it again and pass it to SetJWKS.
+func (ks *JWTKeySet) SetJWKS(data []byte) error {
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	err := json.Unmarshal(data, &jwks)
+	if err != nil {
+		return fmt.Errorf("malformed JWKS: %v", err)
+	}
+
+	keys := make([]JWTKey, 0, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, supported, err := jwk.toJWTKey()
+		if err != nil {
+			return err
+		}
+
+		if supported {
+			keys = append(keys, key)
+		}
+	}
+
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+	ks.keys = keys
+	return nil
+}
+
+// returns the keys that can verify a token signed with alg, with the ID kid
+func (ks *JWTKeySet) find(alg string, kid string) []JWTKey {
+	ks.mutex.RLock()
+	defer ks.mutex.RUnlock()
+
+	found := make([]JWTKey, 0)
+	for _, key := range ks.keys {
+		if key.Algorithm == alg && (kid == "" || key.ID == kid) {
+			found = append(found, key)
+		}
+	}
+	return found
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	K   string `json:"k"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// converts a JSON Web Key to a JWTKey. The second return value is false if the
+// key's type or algorithm is not supported.
+func (jwk jsonWebKey) toJWTKey() (JWTKey, bool, error) {
+	malformed := func(err error) (JWTKey, bool, error) {
+		return JWTKey{}, false, fmt.Errorf("malformed JWK '%s': %v", jwk.Kid, err)
+	}
+
+	key := JWTKey{ID: jwk.Kid, Algorithm: jwk.Alg}
+	switch jwk.Kty {
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(jwk.K)
+		if err != nil {
+			return malformed(err)
+		}
+		key.Key = secret
+		if key.Algorithm == "" {
+			key.Algorithm = HS256
+		}
+
+	case "RSA":
+		n, err1 := decodeBigInt(jwk.N)
+		e, err2 := decodeBigInt(jwk.E)
+		if err := errors.Join(err1, err2); err != nil {
+			return malformed(err)
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return malformed(fmt.Errorf("exponent is too large"))
+		}
+		key.Key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		if key.Algorithm == "" {
+			key.Algorithm = RS256
+		}
+
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return JWTKey{}, false, nil
+		}
+		x, err1 := decodeBigInt(jwk.X)
+		y, err2 := decodeBigInt(jwk.Y)
+		if err := errors.Join(err1, err2); err != nil {
+			return malformed(err)
+		}
+		if x.BitLen() > 256 || y.BitLen() > 256 {
+			return malformed(fmt.Errorf("point is not on the P-256 curve"))
+		}
+		point := append([]byte{4}, x.FillBytes(make([]byte, 32))...)
+		_, err := ecdh.P256().NewPublicKey(append(point, y.FillBytes(make([]byte, 32))...))
+		if err != nil {
+			return malformed(fmt.Errorf("point is not on the P-256 curve"))
+		}
+		key.Key = &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		if key.Algorithm == "" {
+			key.Algorithm = ES256
+		}
+
+	default:
+		return JWTKey{}, false, nil
+	}
+
+	supported := key.Algorithm == HS256 || key.Algorithm == RS256 || key.Algorithm == ES256
+	return key, supported, nil
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("missing value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// JWTValidator verifies JSON Web Tokens, as described by RFC 7519, signed
+// with the HS256, RS256, or ES256 algorithms. It checks the token's
+// signature against its Keys, and its "exp", "nbf", "iss", and "aud" claims.
+// A JWTValidator should only be created using the [NewJWTValidator] method
+// to ensure it is properly initialized. Its Middleware requires every request
+// to include a valid token using the Bearer authentication scheme:
+//
+//	keys, err := simplehttp.LoadJWKSFile("jwks.json")
+//	validator := simplehttp.NewJWTValidator(keys)
+//	validator.Issuer = "https://id.example.com"
+//	server.Get("/profile", validator.Middleware(profile))
+//
+// Callbacks can read the token's claims using [Request.Claims].
+type JWTValidator struct {
+	// Keys are the keys used to verify signatures.
+	Keys *JWTKeySet
+	// Issuer is the required value of the "iss" claim. If empty, the claim
+	// is not checked.
+	Issuer string
+	// Audience is a value the "aud" claim must contain. If empty, the claim
+	// is not checked.
+	Audience string
+	// ClockSkew is the leeway allowed when checking the "exp" and "nbf"
+	// claims, to account for differences between clocks. Defaults to 1 minute.
+	ClockSkew time.Duration
+	// RequireExpiration rejects tokens without an "exp" claim. Defaults to true.
+	RequireExpiration bool
+	now               func() time.Time
+}
+
+// Creates and initializes a new [JWTValidator] that verifies tokens using keys.
+func NewJWTValidator(keys *JWTKeySet) *JWTValidator {
+	return &JWTValidator{
+		Keys:              keys,
+		ClockSkew:         defaultJWTClockSkew,
+		RequireExpiration: true,
+		now:               time.Now,
+	}
+}
+
+// Middleware is the [MiddlewareFunc] to be registered on the [Server] using
+// [Server.Use]. It is a [BearerAuth] that uses [JWTValidator.ValidateToken].
+func (v *JWTValidator) Middleware(next CallbackFunc) CallbackFunc {
+	return NewBearerAuth("", v.ValidateToken).Middleware(next)
+}
+
+// ValidateToken is a [TokenValidator] that verifies a JSON Web Token and
+// returns a [Principal] with the token's subject as its Name, and its
+// [JWTClaims] as its Details.
+func (v *JWTValidator) ValidateToken(_ Request, token string) (Principal, error) {
+	claims, err := v.Validate(token)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	return Principal{Name: claims.Subject(), Scheme: "Bearer", Details: claims}, nil
+}
+
+// Verifies a JSON Web Token in compact serialization and returns its claims.
+func (v *JWTValidator) Validate(token string) (JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	err := decodeJWTSegment(parts[0], &header)
+	if err != nil {
+		return nil, fmt.Errorf("malformed token header")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature")
+	}
+
+	// the algorithm must match the key, so a token cannot choose how it is verified
+	keys := v.Keys.find(header.Alg, header.Kid)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("unsupported or unknown signing algorithm")
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, key := range keys {
+		if verifyJWTSignature(key, signed, signature) {
+			verified = true
+			break
+		}
+	}
+
+	if !verified {
+		return nil, fmt.Errorf("invalid token signature")
+	}
+
+	var claims JWTClaims
+	err = decodeJWTSegment(parts[1], &claims)
+	if err != nil || claims == nil {
+		return nil, fmt.Errorf("malformed token claims")
+	}
+
+	err = v.checkClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *JWTValidator) checkClaims(claims JWTClaims) error {
+	now := v.now()
+
+	expires, hasExpiration := claims.Time("exp")
+	if !hasExpiration && (v.RequireExpiration || claims["exp"] != nil) {
+		return fmt.Errorf("token does not have a valid expiration time")
+	}
+	if hasExpiration && !now.Before(expires.Add(v.ClockSkew)) {
+		return fmt.Errorf("token has expired")
+	}
+
+	notBefore, hasNotBefore := claims.Time("nbf")
+	if !hasNotBefore && claims["nbf"] != nil {
+		return fmt.Errorf("token does not have a valid not before time")
+	}
+	if hasNotBefore && now.Add(v.ClockSkew).Before(notBefore) {
+		return fmt.Errorf("token is not valid yet")
+	}
+
+	if v.Issuer != "" && claims.Issuer() != v.Issuer {
+		return fmt.Errorf("token has an invalid issuer")
+	}
+
+	if v.Audience != "" {
+		for _, audience := range claims.Audience() {
+			if audience == v.Audience {
+				return nil
+			}
+		}
+		return fmt.Errorf("token has an invalid audience")
+	}
+
+	return nil
+}
+
+func decodeJWTSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func verifyJWTSignature(key JWTKey, signed []byte, signature []byte) bool {
+	switch key.Algorithm {
+	case HS256:
+		secret, ok := key.Key.([]byte)
+		if !ok {
+			return false
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), signature)
+
+	case RS256:
+		public, ok := key.Key.(*rsa.PublicKey)
+		if !ok {
+			return false
+		}
+		hash := sha256.Sum256(signed)
+		return rsa.VerifyPKCS1v15(public, crypto.SHA256, hash[:], signature) == nil
+
+	case ES256:
+		public, ok := key.Key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return false
+		}
+		hash := sha256.Sum256(signed)
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(public, hash[:], r, s)
+
+	default:
+		return false
+	}
+}
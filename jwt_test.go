@@ -0,0 +1,273 @@
+package simplehttp
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var jwtTestNow = time.Date(2024, 5, 12, 5, 53, 22, 0, time.UTC)
+
+// signs a token with a locally generated key, in the same way an identity provider would
+func signTestJWT(t *testing.T, alg string, kid string, key any, claims map[string]any) string {
+	header := map[string]any{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+
+	headerJSON, _ := json.Marshal(header)
+	claimsJSON, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	hash := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch alg {
+	case HS256:
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case RS256:
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, hash[:])
+		if err != nil {
+			t.Fatalf("unable to sign token: %v", err)
+		}
+	case ES256:
+		r, s, err := ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), hash[:])
+		if err != nil {
+			t.Fatalf("unable to sign token: %v", err)
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func validTestClaims() map[string]any {
+	return map[string]any{
+		"sub": "alice",
+		"iss": "https://id.example.com",
+		"aud": []string{"api", "other"},
+		"exp": jwtTestNow.Add(time.Hour).Unix(),
+		"nbf": jwtTestNow.Add(-time.Hour).Unix(),
+	}
+}
+
+func newTestJWTValidator(keys ...JWTKey) *JWTValidator {
+	v := NewJWTValidator(NewJWTKeySet(keys...))
+	v.Issuer = "https://id.example.com"
+	v.Audience = "api"
+	v.now = func() time.Time { return jwtTestNow }
+	return v
+}
+
+func TestJWTValidator_Algorithms(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	secret := []byte("a-secret-of-at-least-32-bytes-long")
+
+	v := newTestJWTValidator(
+		JWTKey{ID: "hmac", Algorithm: HS256, Key: secret},
+		JWTKey{ID: "rsa", Algorithm: RS256, Key: &rsaKey.PublicKey},
+		JWTKey{ID: "ec", Algorithm: ES256, Key: &ecKey.PublicKey},
+	)
+
+	tokens := map[string]string{
+		HS256: signTestJWT(t, HS256, "hmac", secret, validTestClaims()),
+		RS256: signTestJWT(t, RS256, "rsa", rsaKey, validTestClaims()),
+		ES256: signTestJWT(t, ES256, "", ecKey, validTestClaims()),
+	}
+
+	for alg, token := range tokens {
+		claims, err := v.Validate(token)
+		if err != nil {
+			t.Fatalf("expected the %s token to be valid but received: %v", alg, err)
+		}
+
+		if claims.Subject() != "alice" {
+			t.Fatalf("incorrect subject. Expected 'alice' | Actual '%s'", claims.Subject())
+		}
+	}
+
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, err := v.Validate(signTestJWT(t, ES256, "ec", otherKey, validTestClaims()))
+	if err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Fatalf("expected an invalid signature error. Actual '%v'", err)
+	}
+}
+
+func TestJWTValidator_AlgorithmConfusion(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	v := newTestJWTValidator(JWTKey{ID: "rsa", Algorithm: RS256, Key: &rsaKey.PublicKey})
+
+	// an HS256 token "signed" with the RSA public key must not be accepted
+	publicBytes := rsaKey.PublicKey.N.Bytes()
+	_, err := v.Validate(signTestJWT(t, HS256, "rsa", publicBytes, validTestClaims()))
+	if err == nil {
+		t.Fatalf("expected a token using a different algorithm than its key to be rejected")
+	}
+
+	_, err = v.Validate(signTestJWT(t, "none", "", nil, validTestClaims()))
+	if err == nil {
+		t.Fatalf("expected an unsigned token to be rejected")
+	}
+
+	// the error must not include the algorithm, since the token chooses it
+	_, err = v.Validate(signTestJWT(t, "x\r\nSet-Cookie: pwned=1", "", nil, validTestClaims()))
+	if err == nil || strings.Contains(err.Error(), "pwned") {
+		t.Fatalf("expected the token to be rejected without repeating its algorithm. Actual '%v'", err)
+	}
+}
+
+func TestJWTValidator_Claims(t *testing.T) {
+	secret := []byte("secret")
+	v := newTestJWTValidator(JWTKey{Algorithm: HS256, Key: secret})
+
+	cases := map[string]func(map[string]any){
+		"token has expired":                           func(c map[string]any) { c["exp"] = jwtTestNow.Add(-2 * time.Minute).Unix() },
+		"token is not valid yet":                      func(c map[string]any) { c["nbf"] = jwtTestNow.Add(2 * time.Minute).Unix() },
+		"token has an invalid issuer":                 func(c map[string]any) { c["iss"] = "https://evil.com" },
+		"token has an invalid audience":               func(c map[string]any) { c["aud"] = "other" },
+		"token does not have a valid expiration time": func(c map[string]any) { delete(c, "exp") },
+	}
+
+	for expected, modify := range cases {
+		claims := validTestClaims()
+		modify(claims)
+
+		_, err := v.Validate(signTestJWT(t, HS256, "", secret, claims))
+		if err == nil || err.Error() != expected {
+			t.Fatalf("incorrect error. Expected '%s' | Actual '%v'", expected, err)
+		}
+	}
+
+	// within the clock skew
+	claims := validTestClaims()
+	claims["exp"] = jwtTestNow.Add(-30 * time.Second).Unix()
+	claims["nbf"] = jwtTestNow.Add(30 * time.Second).Unix()
+	claims["aud"] = "api"
+	_, err := v.Validate(signTestJWT(t, HS256, "", secret, claims))
+	if err != nil {
+		t.Fatalf("expected the token to be valid within the clock skew but received: %v", err)
+	}
+}
+
+func TestJWTValidator_Malformed(t *testing.T) {
+	v := newTestJWTValidator(JWTKey{Algorithm: HS256, Key: []byte("secret")})
+
+	for _, token := range []string{"", "a.b", "a.b.c", "!!.e30.sig"} {
+		_, err := v.Validate(token)
+		if err == nil {
+			t.Fatalf("expected an error for token '%s'", token)
+		}
+	}
+}
+
+func TestJWTKeySet_Rotation(t *testing.T) {
+	oldSecret := []byte("old-secret")
+	newSecret := []byte("new-secret")
+	v := newTestJWTValidator(JWTKey{ID: "old", Algorithm: HS256, Key: oldSecret})
+
+	oldToken := signTestJWT(t, HS256, "old", oldSecret, validTestClaims())
+	newToken := signTestJWT(t, HS256, "new", newSecret, validTestClaims())
+
+	v.Keys.Add(JWTKey{ID: "new", Algorithm: HS256, Key: newSecret})
+	for _, token := range []string{oldToken, newToken} {
+		_, err := v.Validate(token)
+		if err != nil {
+			t.Fatalf("expected both keys to be accepted during rotation but received: %v", err)
+		}
+	}
+
+	v.Keys.Remove("old")
+	_, err := v.Validate(oldToken)
+	if err == nil {
+		t.Fatalf("expected the removed key to be rejected")
+	}
+}
+
+func TestLoadJWKSFile(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+	jwks := map[string]any{"keys": []map[string]any{
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": encode(rsaKey.N.Bytes()),
+			"e": encode(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": encode(ecKey.X.FillBytes(make([]byte, 32))),
+			"y": encode(ecKey.Y.FillBytes(make([]byte, 32)))},
+		{"kty": "oct", "kid": "hmac", "k": encode([]byte("secret"))},
+		{"kty": "RSA", "kid": "encryption", "use": "enc", "n": "AQAB", "e": "AQAB"},
+		{"kty": "OKP", "kid": "unsupported", "crv": "Ed25519", "x": "AQAB"},
+	}}
+
+	data, _ := json.Marshal(jwks)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	os.WriteFile(path, data, 0644)
+
+	keys, err := LoadJWKSFile(path)
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+
+	if len(keys.keys) != 3 {
+		t.Fatalf("incorrect number of keys. Expected '3' | Actual '%d'", len(keys.keys))
+	}
+
+	v := newTestJWTValidator()
+	v.Keys = keys
+	for _, token := range []string{
+		signTestJWT(t, RS256, "rsa", rsaKey, validTestClaims()),
+		signTestJWT(t, ES256, "ec", ecKey, validTestClaims()),
+		signTestJWT(t, HS256, "hmac", []byte("secret"), validTestClaims()),
+	} {
+		_, err = v.Validate(token)
+		if err != nil {
+			t.Fatalf("expected the token to be verified by the JWKS but received: %v", err)
+		}
+	}
+
+	err = keys.SetJWKS([]byte(`{"keys": [{"kty": "EC", "crv": "P-256", "x": "AQAB", "y": "AQAB"}]}`))
+	if err == nil || len(keys.keys) != 3 {
+		t.Fatalf("expected an error for a point that is not on the curve, without changing the keys")
+	}
+}
+
+func TestJWTValidator_Middleware(t *testing.T) {
+	secret := []byte("secret")
+	v := newTestJWTValidator(JWTKey{Algorithm: HS256, Key: secret})
+
+	var claims JWTClaims
+	callback := v.Middleware(func(req Request, _ *Response) error {
+		claims, _ = req.Claims()
+		return nil
+	})
+
+	res := newResponse()
+	token := signTestJWT(t, HS256, "", secret, validTestClaims())
+	callback(Request{headers: headers{"Authorization": "Bearer " + token}}, &res)
+
+	if res.statusCode != 200 || claims.Subject() != "alice" || claims.Audience()[0] != "api" {
+		t.Fatalf("expected the claims to be available to the callback. Actual '%v'", claims)
+	}
+
+	res = newResponse()
+	callback(Request{headers: headers{"Authorization": "Bearer invalid"}}, &res)
+	if res.statusCode != 401 || !strings.Contains(res.headers["WWW-Authenticate"], `error="invalid_token"`) {
+		t.Fatalf("expected an invalid token to be rejected. Actual '%s'", res.headers["WWW-Authenticate"])
+	}
+}
@@ -1,5 +1,12 @@
 package simplehttp
 
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
 // Logger is a simple interface to be given to the [Server].
 type Logger interface {
 	// LogMessage accepts a string to be logged.
@@ -9,3 +16,157 @@ type Logger interface {
 type nilLogger struct{}
 
 func (nl nilLogger) LogMessage(string) {}
+
+// LogLevel is the severity of a message sent to a [StructuredLogger].
+type LogLevel int
+
+const (
+	// LevelDebug is used for the raw requests and responses.
+	LevelDebug LogLevel = iota
+	// LevelInfo is used for connection events.
+	LevelInfo
+	// LevelWarn is used for requests that could not be handled successfully.
+	LevelWarn
+	// LevelError is used for failures within the server or a callback.
+	LevelError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	default:
+		return fmt.Sprintf("LEVEL(%d)", int(l))
+	}
+}
+
+// LogField is a key/value pair that adds context to a log message,
+// such as the remote address of a connection.
+type LogField struct {
+	Key   string
+	Value any
+}
+
+// StructuredLogger is an interface to be given to the [Server] that receives
+// log messages with a level and key/value fields, rather than pre-formatted
+// strings. See [NewSlogLogger] to send messages to a [log/slog] Logger.
+type StructuredLogger interface {
+	// Log accepts a message to be logged along with its level and fields.
+	Log(level LogLevel, message string, fields ...LogField)
+}
+
+// Creates a [StructuredLogger] that sends messages to the provided [slog.Logger].
+// Each [LogField] is converted into an attribute.
+func NewSlogLogger(logger *slog.Logger) StructuredLogger {
+	return slogLogger{logger}
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (sl slogLogger) Log(level LogLevel, message string, fields ...LogField) {
+	attrs := make([]slog.Attr, len(fields))
+	for i, field := range fields {
+		attrs[i] = slog.Any(field.Key, field.Value)
+	}
+
+	sl.logger.LogAttrs(context.Background(), level.slogLevel(), message, attrs...)
+}
+
+func (l LogLevel) slogLevel() slog.Level {
+	switch l {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelInfo:
+		return slog.LevelInfo
+	case LevelWarn:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
+
+var defaultRedactedHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+}
+
+const redactedValue string = "[REDACTED]"
+
+// log sends a message to the StructuredLogger if one was provided, otherwise
+// the message and its fields are formatted into a single string for the Logger.
+func (s *Server) log(level LogLevel, message string, fields ...LogField) {
+	if s.StructuredLogger != nil {
+		s.StructuredLogger.Log(level, message, fields...)
+		return
+	}
+
+	if s.Logger == nil {
+		return
+	}
+
+	for _, field := range fields {
+		message += fmt.Sprintf(" %s=%v", field.Key, field.Value)
+	}
+	s.Logger.LogMessage(message)
+}
+
+// logDump logs an entire HTTP message at LevelDebug with the values of
+// sensitive headers redacted, unless DumpMessages is disabled.
+func (s *Server) logDump(message string, raw string, separator string, fields ...LogField) {
+	if !s.DumpMessages {
+		return
+	}
+
+	raw = redactHeaders(raw, s.RedactedHeaders)
+	if s.StructuredLogger != nil {
+		s.StructuredLogger.Log(LevelDebug, message, append(fields, LogField{"message", raw})...)
+		return
+	}
+
+	s.log(LevelDebug, message, fields...)
+	s.log(LevelDebug, separator)
+	s.log(LevelDebug, raw)
+	s.log(LevelDebug, separator)
+}
+
+// replaces the values of the named headers in a raw HTTP message with
+// [REDACTED]. Only the header section of the message is changed.
+func redactHeaders(raw string, names []string) string {
+	if len(names) == 0 {
+		return raw
+	}
+
+	head, body, hasBody := strings.Cut(raw, doubleLineEnd)
+	lines := strings.Split(head, lineEnd)
+
+	// the first line is the request-line or status-line
+	for i := 1; i < len(lines); i++ {
+		field, _, found := strings.Cut(lines[i], ":")
+		if !found {
+			continue
+		}
+
+		for _, name := range names {
+			if strings.EqualFold(strings.TrimSpace(field), name) {
+				lines[i] = field + ": " + redactedValue
+				break
+			}
+		}
+	}
+
+	redacted := strings.Join(lines, lineEnd)
+	if hasBody {
+		redacted += doubleLineEnd + body
+	}
+	return redacted
+}
@@ -0,0 +1,117 @@
+package simplehttp
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+type recordingLogger struct {
+	messages []string
+}
+
+func (rl *recordingLogger) LogMessage(message string) {
+	rl.messages = append(rl.messages, message)
+}
+
+type recordedEntry struct {
+	level   LogLevel
+	message string
+	fields  []LogField
+}
+
+type recordingStructuredLogger struct {
+	entries []recordedEntry
+}
+
+func (rsl *recordingStructuredLogger) Log(level LogLevel, message string, fields ...LogField) {
+	rsl.entries = append(rsl.entries, recordedEntry{level, message, fields})
+}
+
+func TestRedactHeaders(t *testing.T) {
+	raw := "GET / HTTP/1.0" + lineEnd +
+		"Host: client:8080" + lineEnd +
+		"authorization: Basic dXNlcjpwYXNz" + lineEnd +
+		"Cookie: session=secret" + doubleLineEnd +
+		"Authorization: in the body"
+
+	expected := "GET / HTTP/1.0" + lineEnd +
+		"Host: client:8080" + lineEnd +
+		"authorization: [REDACTED]" + lineEnd +
+		"Cookie: [REDACTED]" + doubleLineEnd +
+		"Authorization: in the body"
+
+	actual := redactHeaders(raw, defaultRedactedHeaders)
+	if actual != expected {
+		t.Fatalf("incorrect redaction. Expected '%s' | Actual '%s'", expected, actual)
+	}
+}
+
+func TestServer_Log_FormatsFieldsForLogger(t *testing.T) {
+	logger := &recordingLogger{}
+	server := NewServer(0)
+	server.Logger = logger
+
+	server.log(LevelInfo, "Connected to remote address", LogField{"remote_addr", "127.0.0.1:5000"})
+
+	if len(logger.messages) != 1 || logger.messages[0] != "Connected to remote address remote_addr=127.0.0.1:5000" {
+		t.Fatalf("incorrect message. Actual %v", logger.messages)
+	}
+}
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	logger.Log(LevelWarn, "something happened", LogField{"remote_addr", "127.0.0.1:5000"}, LogField{"count", 3})
+
+	output := buf.String()
+	if !strings.Contains(output, "level=WARN") ||
+		!strings.Contains(output, `msg="something happened"`) ||
+		!strings.Contains(output, "remote_addr=127.0.0.1:5000") ||
+		!strings.Contains(output, "count=3") {
+		t.Fatalf("incorrect slog output. Actual '%s'", output)
+	}
+}
+
+func TestServer_DumpMessages(t *testing.T) {
+	logger := &recordingStructuredLogger{}
+	server := NewServer(0)
+	server.StructuredLogger = logger
+	server.Get("/", dummyCallback)
+
+	sendRawRequest(&server, "GET / HTTP/1.0"+lineEnd+"Authorization: Bearer secret"+doubleLineEnd)
+
+	dumps := 0
+	for _, entry := range logger.entries {
+		if entry.level != LevelDebug {
+			continue
+		}
+
+		dumps++
+		for _, field := range entry.fields {
+			if strings.Contains(field.Value.(string), "secret") {
+				t.Fatalf("expected the Authorization header to be redacted. Actual '%s'", field.Value)
+			}
+		}
+	}
+
+	if dumps != 2 {
+		t.Fatalf("expected the request and response to be dumped. Actual %d dumps", dumps)
+	}
+
+	logger.entries = nil
+	server.DumpMessages = false
+	sendRawRequest(&server, "GET / HTTP/1.0"+doubleLineEnd)
+
+	for _, entry := range logger.entries {
+		if entry.level == LevelDebug {
+			t.Fatalf("did not expect messages to be dumped. Actual '%s'", entry.message)
+		}
+	}
+
+	if len(logger.entries) == 0 {
+		t.Fatalf("expected connection events to still be logged")
+	}
+}
@@ -0,0 +1,247 @@
+package simplehttp
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of the request
+// latency histogram used by [NewMetrics]. They match the default buckets of
+// the Prometheus client libraries.
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+const unmatchedRoute string = "unmatched"
+
+// Metrics collects request counts, latencies, and traffic for a [Server] and
+// renders them in the Prometheus text exposition format.
+// Metrics should only be created using the [NewMetrics] method, and is
+// enabled by registering its hooks using [Server.AddHooks]. Its Handler can
+// be registered as a callback to expose the metrics to a Prometheus scraper:
+//
+//	metrics := simplehttp.NewMetrics()
+//	server.AddHooks(metrics.Hooks())
+//	server.Get("/metrics", metrics.Handler)
+//
+// Requests are labelled by method, route, and status. The route is the path
+// of the callback as it was registered, rather than the requested path, so
+// the number of label values stays bounded. Requests for paths without a
+// registered callback use the route "unmatched".
+type Metrics struct {
+	buckets         []float64
+	mutex           sync.Mutex
+	requests        map[requestLabels]uint64
+	latencies       map[routeLabels]*histogram
+	inFlight        int64
+	openConnections int64
+	bytesRead       int64
+	bytesWritten    int64
+	readErrors      uint64
+}
+
+type requestLabels struct {
+	method string
+	route  string
+	status uint
+}
+
+type routeLabels struct {
+	method string
+	route  string
+}
+
+type histogram struct {
+	counts []uint64 // one per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// Creates a new [Metrics] that records request latencies using the
+// [DefaultLatencyBuckets].
+func NewMetrics() *Metrics {
+	return NewMetricsWithBuckets(DefaultLatencyBuckets)
+}
+
+// Creates a new [Metrics] that records request latencies using the provided
+// bucket upper bounds, in seconds.
+func NewMetricsWithBuckets(buckets []float64) *Metrics {
+	sorted := append([]float64{}, buckets...)
+	sort.Float64s(sorted)
+
+	return &Metrics{
+		buckets:   sorted,
+		requests:  make(map[requestLabels]uint64),
+		latencies: make(map[routeLabels]*histogram),
+	}
+}
+
+// Returns the [Hooks] to be registered on the [Server] using [Server.AddHooks].
+func (m *Metrics) Hooks() Hooks {
+	return Hooks{
+		ConnectionOpened: func(string) {
+			m.mutex.Lock()
+			defer m.mutex.Unlock()
+			m.openConnections++
+		},
+		ConnectionClosed: func(string) {
+			m.mutex.Lock()
+			defer m.mutex.Unlock()
+			m.openConnections--
+		},
+		ReadFailed: func(string, error) {
+			m.mutex.Lock()
+			defer m.mutex.Unlock()
+			m.readErrors++
+		},
+		RequestStarted: func(Request) {
+			m.mutex.Lock()
+			defer m.mutex.Unlock()
+			m.inFlight++
+		},
+		RequestCompleted: m.observe,
+	}
+}
+
+func (m *Metrics) observe(completed CompletedRequest) {
+	route := completed.Route
+	if route == "" {
+		route = unmatchedRoute
+	}
+
+	method := completed.Request.Method()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.inFlight--
+	m.bytesRead += completed.BytesRead
+	m.bytesWritten += completed.BytesWritten
+	m.requests[requestLabels{method, route, completed.StatusCode}]++
+
+	labels := routeLabels{method, route}
+	h, exists := m.latencies[labels]
+	if !exists {
+		h = &histogram{counts: make([]uint64, len(m.buckets))}
+		m.latencies[labels] = h
+	}
+
+	seconds := completed.Duration.Seconds()
+	h.sum += seconds
+	h.count++
+	for i, bound := range m.buckets {
+		if seconds <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+}
+
+// Handler is a [CallbackFunc] that responds with the current metrics in
+// the Prometheus text exposition format.
+func (m *Metrics) Handler(_ Request, res *Response) error {
+	body := m.render()
+	res.body = body
+	res.headers["Content-Length"] = strconv.Itoa(len(body))
+	res.headers["Content-Type"] = "text/plain; version=0.0.4; charset=utf-8"
+	return nil
+}
+
+func (m *Metrics) render() string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var out strings.Builder
+
+	writeMetricHeader(&out, "simplehttp_requests_total", "counter",
+		"Total number of requests handled, by method, route, and status.")
+	requestKeys := make([]requestLabels, 0, len(m.requests))
+	for key := range m.requests {
+		requestKeys = append(requestKeys, key)
+	}
+	sort.Slice(requestKeys, func(i, j int) bool {
+		a, b := requestKeys[i], requestKeys[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.status < b.status
+	})
+	for _, key := range requestKeys {
+		fmt.Fprintf(&out, "simplehttp_requests_total{method=\"%s\",route=\"%s\",status=\"%d\"} %d\n",
+			escapeLabelValue(key.method), escapeLabelValue(key.route), key.status, m.requests[key])
+	}
+
+	writeMetricHeader(&out, "simplehttp_request_duration_seconds", "histogram",
+		"Time taken to read requests and send responses, by method and route.")
+	latencyKeys := make([]routeLabels, 0, len(m.latencies))
+	for key := range m.latencies {
+		latencyKeys = append(latencyKeys, key)
+	}
+	sort.Slice(latencyKeys, func(i, j int) bool {
+		a, b := latencyKeys[i], latencyKeys[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		return a.method < b.method
+	})
+	for _, key := range latencyKeys {
+		h := m.latencies[key]
+		labels := fmt.Sprintf("method=\"%s\",route=\"%s\"", escapeLabelValue(key.method), escapeLabelValue(key.route))
+
+		var cumulative uint64
+		for i, bound := range m.buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(&out, "simplehttp_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n",
+				labels, formatFloat(bound), cumulative)
+		}
+		fmt.Fprintf(&out, "simplehttp_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(&out, "simplehttp_request_duration_seconds_sum{%s} %s\n", labels, formatFloat(h.sum))
+		fmt.Fprintf(&out, "simplehttp_request_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+
+	writeMetricHeader(&out, "simplehttp_requests_in_flight", "gauge",
+		"Number of requests currently being handled.")
+	fmt.Fprintf(&out, "simplehttp_requests_in_flight %d\n", m.inFlight)
+
+	writeMetricHeader(&out, "simplehttp_open_connections", "gauge",
+		"Number of connections currently open.")
+	fmt.Fprintf(&out, "simplehttp_open_connections %d\n", m.openConnections)
+
+	writeMetricHeader(&out, "simplehttp_request_bytes_total", "counter",
+		"Total number of bytes received in requests.")
+	fmt.Fprintf(&out, "simplehttp_request_bytes_total %d\n", m.bytesRead)
+
+	writeMetricHeader(&out, "simplehttp_response_bytes_total", "counter",
+		"Total number of bytes sent in response bodies.")
+	fmt.Fprintf(&out, "simplehttp_response_bytes_total %d\n", m.bytesWritten)
+
+	writeMetricHeader(&out, "simplehttp_request_read_errors_total", "counter",
+		"Total number of requests that could not be read or parsed.")
+	fmt.Fprintf(&out, "simplehttp_request_read_errors_total %d\n", m.readErrors)
+
+	return out.String()
+}
+
+func writeMetricHeader(out *strings.Builder, name string, metricType string, help string) {
+	fmt.Fprintf(out, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(out, "# TYPE %s %s\n", name, metricType)
+}
+
+// escapes a label value as required by the text exposition format
+func escapeLabelValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
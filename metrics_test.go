@@ -0,0 +1,103 @@
+package simplehttp
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestMetrics_Render(t *testing.T) {
+	m := NewMetricsWithBuckets([]float64{0.1, 0.01})
+	hooks := m.Hooks()
+
+	hooks.ConnectionOpened("127.0.0.1:5000")
+	hooks.ConnectionOpened("127.0.0.1:5001")
+	hooks.ConnectionClosed("127.0.0.1:5001")
+	hooks.ReadFailed("127.0.0.1:5002", fmt.Errorf("malformed"))
+
+	req := Request{method: get, uri: url.URL{Path: "/users"}}
+	for _, duration := range []time.Duration{5 * time.Millisecond, 50 * time.Millisecond, time.Second} {
+		hooks.RequestStarted(req)
+		hooks.RequestCompleted(CompletedRequest{
+			Request:      req,
+			Route:        "/users",
+			StatusCode:   200,
+			BytesRead:    10,
+			BytesWritten: 100,
+			Duration:     duration,
+		})
+	}
+
+	hooks.RequestStarted(req)
+	hooks.RequestCompleted(CompletedRequest{Request: req, StatusCode: 404})
+	hooks.RequestStarted(req)
+
+	res := newResponse()
+	m.Handler(Request{}, &res)
+
+	expectedLines := []string{
+		"# TYPE simplehttp_requests_total counter",
+		`simplehttp_requests_total{method="GET",route="/users",status="200"} 3`,
+		`simplehttp_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		"# TYPE simplehttp_request_duration_seconds histogram",
+		`simplehttp_request_duration_seconds_bucket{method="GET",route="/users",le="0.01"} 1`,
+		`simplehttp_request_duration_seconds_bucket{method="GET",route="/users",le="0.1"} 2`,
+		`simplehttp_request_duration_seconds_bucket{method="GET",route="/users",le="+Inf"} 3`,
+		`simplehttp_request_duration_seconds_sum{method="GET",route="/users"} 1.055`,
+		`simplehttp_request_duration_seconds_count{method="GET",route="/users"} 3`,
+		"simplehttp_requests_in_flight 1",
+		"simplehttp_open_connections 1",
+		"simplehttp_request_bytes_total 30",
+		"simplehttp_response_bytes_total 300",
+		"simplehttp_request_read_errors_total 1",
+	}
+
+	lines := strings.Split(res.body, "\n")
+	for _, expected := range expectedLines {
+		found := false
+		for _, line := range lines {
+			if line == expected {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			t.Fatalf("expected the line '%s' in the output:\n%s", expected, res.body)
+		}
+	}
+
+	if !strings.HasPrefix(res.headers["Content-Type"], "text/plain; version=0.0.4") {
+		t.Fatalf("incorrect Content-Type. Actual '%s'", res.headers["Content-Type"])
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	actual := escapeLabelValue("a\"b\\c\nd")
+	if actual != `a\"b\\c\nd` {
+		t.Fatalf(`incorrect escaping. Expected 'a\"b\\c\nd' | Actual '%s'`, actual)
+	}
+}
+
+func TestServer_Metrics(t *testing.T) {
+	server := NewServer(0)
+	metrics := NewMetrics()
+	server.AddHooks(metrics.Hooks())
+	server.Get("/metrics", metrics.Handler)
+	server.StaticFS("/static", fstest.MapFS{})
+
+	sendRawRequest(&server, "GET /static/missing.css HTTP/1.0"+doubleLineEnd)
+	sendRawRequest(&server, "NOT-HTTP"+doubleLineEnd)
+	response := sendRawRequest(&server, "GET /metrics HTTP/1.0"+doubleLineEnd)
+
+	if !strings.Contains(response, `simplehttp_requests_total{method="GET",route="/static/*",status="`) {
+		t.Fatalf("expected the request to be labelled with its prefix route. Actual:\n%s", response)
+	}
+
+	if !strings.Contains(response, "simplehttp_request_read_errors_total 1") {
+		t.Fatalf("expected the read error to be counted. Actual:\n%s", response)
+	}
+}
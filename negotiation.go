@@ -0,0 +1,205 @@
+package simplehttp
+
+import (
+	"mime"
+	"sort"
+	"strings"
+)
+
+// the key of the handler [Response.Format] calls when no other type is acceptable
+const defaultFormat string = "default"
+
+// Returns the type the client prefers out of the provided types, based on the
+// request's Accept header. Types can be media types, such as "application/json"
+// or "text/*", or file extensions, such as "json" or ".html". The type is
+// returned exactly as it was provided.
+// If the request does not have an Accept header, the first type is returned.
+// Returns an empty string if none of the types are acceptable.
+func (r Request) Accepts(types ...string) string {
+	accept, _ := r.headers.get("Accept")
+	return negotiate(accept, types, matchMediaType)
+}
+
+// Returns the language the client prefers out of the provided languages, such
+// as "en" or "en-US", based on the request's Accept-Language header.
+// If the request does not have an Accept-Language header, the first language
+// is returned. Returns an empty string if none of the languages are acceptable.
+func (r Request) AcceptsLanguages(languages ...string) string {
+	acceptLanguage, _ := r.headers.get("Accept-Language")
+	return negotiate(acceptLanguage, languages, matchLanguage)
+}
+
+// Returns the charset the client prefers out of the provided charsets, such as
+// "utf-8", based on the request's Accept-Charset header.
+// If the request does not have an Accept-Charset header, the first charset is
+// returned. Returns an empty string if none of the charsets are acceptable.
+func (r Request) AcceptsCharsets(charsets ...string) string {
+	acceptCharset, _ := r.headers.get("Accept-Charset")
+	return negotiate(acceptCharset, charsets, matchCharset)
+}
+
+// Calls the handler for the type the client prefers, based on the Accept
+// header of req. Keys of handlers are types as accepted by [Request.Accepts],
+// and the Content-Type header is set to the chosen type before its handler is
+// called. When the client prefers several types equally, the key that sorts
+// first is chosen.
+// If none of the types are acceptable, the handler with the key "default" is
+// called, or if there is not one, the Status-Code is set to 406 Not Acceptable.
+// The Vary header is always updated to include Accept.
+func (r *Response) Format(req Request, handlers map[string]func()) {
+	types := make([]string, 0, len(handlers))
+	for key := range handlers {
+		if key != defaultFormat {
+			types = append(types, key)
+		}
+	}
+	sort.Strings(types)
+
+	addVary(r, "Accept")
+
+	chosen := req.Accepts(types...)
+	if chosen == "" {
+		if handler, exists := handlers[defaultFormat]; exists {
+			handler()
+			return
+		}
+
+		r.SetStatus(406)
+		return
+	}
+
+	if contentType := mediaTypeOf(chosen); contentType != "" {
+		r.headers["Content-Type"] = contentType
+	}
+	handlers[chosen]()
+}
+
+// a matchFunc returns how specifically the element of a header matches an
+// offered value, or -1 if it does not match. Higher values are more specific.
+type matchFunc func(accepted qualityValue, offer string) int
+
+// negotiate chooses the offer the client prefers based on the value of an
+// Accept-style header. Offers are ranked by the quality of the most specific
+// element that matches them, then by that element's specificity and position
+// in the header, and finally by their own order. Offers with a quality of 0
+// are never chosen.
+func negotiate(header string, offers []string, match matchFunc) string {
+	if len(offers) == 0 {
+		return ""
+	}
+
+	if strings.TrimSpace(header) == "" {
+		return offers[0]
+	}
+
+	accepted := parseQualityList(header)
+
+	best := ""
+	bestQuality, bestSpecificity, bestPosition := 0.0, -1, len(accepted)
+	for _, offer := range offers {
+		quality, specificity, position := 0.0, -1, len(accepted)
+		for i, qv := range accepted {
+			s := match(qv, offer)
+			if s > specificity {
+				quality, specificity, position = qv.quality, s, i
+			}
+		}
+
+		if specificity < 0 || quality <= 0 {
+			continue
+		}
+
+		better := quality > bestQuality ||
+			(quality == bestQuality && specificity > bestSpecificity) ||
+			(quality == bestQuality && specificity == bestSpecificity && position < bestPosition)
+		if better {
+			best = offer
+			bestQuality, bestSpecificity, bestPosition = quality, specificity, position
+		}
+	}
+
+	return best
+}
+
+// returns the media type of an offer, which can be a media type or a file
+// extension. Returns an empty string if the extension is unknown.
+func mediaTypeOf(offer string) string {
+	if strings.Contains(offer, "/") {
+		return offer
+	}
+
+	if !strings.HasPrefix(offer, ".") {
+		offer = "." + offer
+	}
+	return mime.TypeByExtension(offer)
+}
+
+// a media range matches a media type if their types and subtypes are equal or
+// are wildcards, and every parameter of the range is in the media type
+func matchMediaType(accepted qualityValue, offer string) int {
+	mediaType, params, err := mime.ParseMediaType(mediaTypeOf(offer))
+	if err != nil {
+		return -1
+	}
+
+	acceptedType, acceptedSubtype, _ := strings.Cut(accepted.value, "/")
+	offerType, offerSubtype, _ := strings.Cut(mediaType, "/")
+
+	specificity := 0
+	if acceptedType == offerType {
+		specificity += 4
+	} else if acceptedType != "*" {
+		return -1
+	}
+
+	if acceptedSubtype == offerSubtype {
+		specificity += 2
+	} else if acceptedSubtype != "*" {
+		return -1
+	}
+
+	if len(accepted.params) > 0 {
+		for key, value := range accepted.params {
+			if !strings.EqualFold(params[key], value) {
+				return -1
+			}
+		}
+		specificity++
+	}
+
+	return specificity
+}
+
+// a language range matches a language tag if they are equal, if the range is
+// a prefix of the tag (ex. "en" and "en-US"), or less specifically, if the tag
+// is a prefix of the range. The range "*" matches every tag.
+func matchLanguage(accepted qualityValue, offer string) int {
+	offer = strings.ToLower(offer)
+	if accepted.value == offer {
+		return 4
+	}
+
+	if strings.HasPrefix(offer, accepted.value+"-") {
+		return 2
+	}
+
+	if strings.HasPrefix(accepted.value, offer+"-") {
+		return 1
+	}
+
+	if accepted.value == "*" {
+		return 0
+	}
+	return -1
+}
+
+func matchCharset(accepted qualityValue, offer string) int {
+	if accepted.value == strings.ToLower(offer) {
+		return 1
+	}
+
+	if accepted.value == "*" {
+		return 0
+	}
+	return -1
+}
@@ -0,0 +1,130 @@
+package simplehttp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRequest_Accepts(t *testing.T) {
+	tests := []struct {
+		accept   string
+		types    []string
+		expected string
+	}{
+		{"", []string{"json", "html"}, "json"},
+		{"text/html", []string{"json", "html"}, "html"},
+		{"text/html, application/json", []string{"json", "html"}, "html"},
+		{"application/json;q=0.5, text/*", []string{"application/json", "text/plain"}, "text/plain"},
+		{"*/*;q=0.1, application/json", []string{"text/html", "application/json"}, "application/json"},
+		{"text/*, text/plain;q=0", []string{"text/plain"}, ""},
+		{"text/html;level=1", []string{"text/html"}, ""},
+		{"image/png", []string{"json", "html"}, ""},
+		{"application/json", []string{}, ""},
+	}
+
+	for _, test := range tests {
+		req := Request{headers: headers{"Accept": test.accept}}
+		actual := req.Accepts(test.types...)
+		if actual != test.expected {
+			t.Fatalf("incorrect type for '%s'. Expected '%s' | Actual '%s'", test.accept, test.expected, actual)
+		}
+	}
+}
+
+func TestRequest_AcceptsLanguages(t *testing.T) {
+	tests := []struct {
+		acceptLanguage string
+		languages      []string
+		expected       string
+	}{
+		{"", []string{"en", "fr"}, "en"},
+		{"fr-CA, en;q=0.8", []string{"en", "fr"}, "fr"},
+		{"en", []string{"fr", "en-US"}, "en-US"},
+		{"en-GB, en-US;q=0.5", []string{"en-us", "en-gb"}, "en-gb"},
+		{"de, *;q=0.1", []string{"fr"}, "fr"},
+		{"de", []string{"en", "fr"}, ""},
+	}
+
+	for _, test := range tests {
+		req := Request{headers: headers{"accept-language": test.acceptLanguage}}
+		actual := req.AcceptsLanguages(test.languages...)
+		if actual != test.expected {
+			t.Fatalf("incorrect language for '%s'. Expected '%s' | Actual '%s'", test.acceptLanguage, test.expected, actual)
+		}
+	}
+}
+
+func TestRequest_AcceptsCharsets(t *testing.T) {
+	req := Request{headers: headers{"Accept-Charset": "iso-8859-1;q=0.5, UTF-8"}}
+	if actual := req.AcceptsCharsets("iso-8859-1", "utf-8"); actual != "utf-8" {
+		t.Fatalf("incorrect charset. Expected 'utf-8' | Actual '%s'", actual)
+	}
+
+	if actual := req.AcceptsCharsets("utf-16"); actual != "" {
+		t.Fatalf("expected no acceptable charset. Actual '%s'", actual)
+	}
+}
+
+func TestResponse_Format(t *testing.T) {
+	req := Request{headers: headers{"Accept": "application/json;q=0.9, text/html"}}
+	res := newResponse()
+
+	called := ""
+	res.Format(req, map[string]func(){
+		"json": func() { called = "json" },
+		"html": func() { called = "html" },
+	})
+
+	if called != "html" {
+		t.Fatalf("incorrect handler called. Expected 'html' | Actual '%s'", called)
+	}
+
+	if res.headers["Content-Type"] != "text/html; charset=utf-8" || res.headers["Vary"] != "Accept" {
+		t.Fatalf("incorrect headers. Actual %v", res.headers)
+	}
+}
+
+func TestResponse_Format_NotAcceptable(t *testing.T) {
+	req := Request{headers: headers{"Accept": "image/png"}}
+	res := newResponse()
+
+	res.Format(req, map[string]func(){
+		"application/json": func() { t.Fatalf("did not expect the handler to be called") },
+	})
+
+	if res.statusCode != 406 {
+		t.Fatalf("incorrect status. Expected '406' | Actual '%d'", res.statusCode)
+	}
+
+	called := false
+	res = newResponse()
+	res.Format(req, map[string]func(){
+		"application/json": func() { t.Fatalf("did not expect the handler to be called") },
+		"default":          func() { called = true },
+	})
+
+	if !called || res.statusCode != 200 {
+		t.Fatalf("expected the default handler to be called")
+	}
+}
+
+func TestServer_Format(t *testing.T) {
+	server := NewServer(0)
+	server.Get("/", func(req Request, res *Response) error {
+		res.Format(req, map[string]func(){
+			"text/plain":       func() { res.body = "hello" },
+			"application/json": func() { res.SetJson(`"hello"`) },
+		})
+		return nil
+	})
+
+	response := sendRawRequest(&server, "GET / HTTP/1.0"+lineEnd+"Accept: application/json"+doubleLineEnd)
+	if !strings.HasSuffix(response, `"hello"`) || !strings.Contains(response, "Vary: Accept"+lineEnd) {
+		t.Fatalf("incorrect response. Actual '%s'", response)
+	}
+
+	response = sendRawRequest(&server, "GET / HTTP/1.0"+lineEnd+"Accept: text/csv"+doubleLineEnd)
+	if !strings.HasPrefix(response, "HTTP/1.0 406 Not Acceptable") {
+		t.Fatalf("incorrect status line. Actual '%s'", response)
+	}
+}
@@ -0,0 +1,237 @@
+package simplehttp
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Returns the address of the client that made the request. If the request
+// was received from one of the [Server.TrustedProxies], this is the address
+// of the client that the proxies forwarded the request for, taken from the
+// Forwarded or X-Forwarded-For header, and may not include a port.
+// Otherwise, it is the address of the connection the request was received on.
+func (r Request) RemoteAddr() string {
+	return r.remoteAddr
+}
+
+// Returns the scheme the client used to make the request, either "http"
+// or "https". If the request was received from one of the
+// [Server.TrustedProxies], this is taken from the Forwarded or
+// X-Forwarded-Proto header.
+func (r Request) Scheme() string {
+	if r.scheme == "" {
+		return "http"
+	}
+	return r.scheme
+}
+
+// Returns the host the client made the request to. If the request was
+// received from one of the [Server.TrustedProxies], this is taken from the
+// Forwarded or X-Forwarded-Host header. Otherwise, it is the value of the
+// Host header, or an empty string if the request does not have one.
+func (r Request) Host() string {
+	if r.host != "" {
+		return r.host
+	}
+
+	host, _ := r.headers.get("Host")
+	return host
+}
+
+// parses a list of IP addresses and CIDR ranges, such as "10.0.0.1" and
+// "10.0.0.0/8", into prefixes
+func parseTrustedProxies(proxies []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if strings.Contains(proxy, "/") {
+			prefix, err := netip.ParsePrefix(proxy)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy '%s': %v", proxy, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy '%s': %v", proxy, err)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return prefixes, nil
+}
+
+// parses the Server's TrustedProxies once, before it starts handling requests
+func (s *Server) loadTrustedProxies() error {
+	trusted, err := parseTrustedProxies(s.TrustedProxies)
+	if err != nil {
+		return err
+	}
+
+	s.trustedProxies = trusted
+	return nil
+}
+
+// parses an IP address that may include a port. Addresses from the Forwarded
+// header may instead be "unknown" or an obfuscated identifier such as "_hidden",
+// which are not IP addresses.
+func parseHopAddr(address string) (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(strings.Trim(remoteHost(address), "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// returns true if the address, which may include a port, is within one of the prefixes
+func isTrustedProxy(address string, trusted []netip.Prefix) bool {
+	addr, ok := parseHopAddr(address)
+	if !ok {
+		return false
+	}
+
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedHop is a single proxy hop described by the Forwarded or X-Forwarded-* headers
+type forwardedHop struct {
+	forAddr string
+	proto   string
+	host    string
+}
+
+// resolveClient sets the request's remote address, scheme, and host from the
+// headers set by the proxies it passed through, if it was received from
+// one of the Server's TrustedProxies. The client is the last address in
+// the chain of proxies that is not trusted. If the client's address is not an
+// IP address, such as "unknown", the request keeps the address it was received from.
+func (s *Server) resolveClient(req Request) Request {
+	trusted := s.trustedProxies
+	if len(trusted) == 0 || !isTrustedProxy(req.remoteAddr, trusted) {
+		return req
+	}
+
+	hops := forwardedHops(req.headers)
+	if len(hops) == 0 {
+		return req
+	}
+
+	client := hops[0]
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !isTrustedProxy(hops[i].forAddr, trusted) {
+			client = hops[i]
+			break
+		}
+	}
+
+	if _, ok := parseHopAddr(client.forAddr); ok {
+		req.remoteAddr = client.forAddr
+	}
+
+	proto := strings.ToLower(client.proto)
+	if proto == "http" || proto == "https" {
+		req.scheme = proto
+	}
+	req.host = client.host
+	return req
+}
+
+// forwardedHops returns the hops described by the Forwarded header or, if
+// it is not present, the X-Forwarded-For, X-Forwarded-Proto, and
+// X-Forwarded-Host headers. Hops are ordered from the client to the last proxy.
+// Each proxy appends to all three X-Forwarded-* headers, so their values are
+// matched by position counting from the right. Values the client sent itself
+// are on the left, and so are never matched to the hops of trusted proxies.
+func forwardedHops(h headers) []forwardedHop {
+	if forwarded, exists := h.get("Forwarded"); exists {
+		return parseForwarded(forwarded)
+	}
+
+	forwardedFor, exists := h.get("X-Forwarded-For")
+	if !exists {
+		return nil
+	}
+
+	hops := make([]forwardedHop, 0)
+	for _, addr := range strings.Split(forwardedFor, ",") {
+		hops = append(hops, forwardedHop{forAddr: strings.TrimSpace(addr)})
+	}
+
+	protos := forwardedValues(h, "X-Forwarded-Proto")
+	hosts := forwardedValues(h, "X-Forwarded-Host")
+	for i := range hops {
+		fromRight := len(hops) - i
+		if fromRight <= len(protos) {
+			hops[i].proto = protos[len(protos)-fromRight]
+		}
+		if fromRight <= len(hosts) {
+			hops[i].host = hosts[len(hosts)-fromRight]
+		}
+	}
+	return hops
+}
+
+// returns the comma separated values of a header, or nil if it is not present
+func forwardedValues(h headers, key string) []string {
+	value, exists := h.get(key)
+	if !exists {
+		return nil
+	}
+
+	values := strings.Split(value, ",")
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+	return values
+}
+
+// parses the value of a Forwarded header as defined by RFC 7239, ex.
+// for=192.0.2.60;proto=http;by=203.0.113.43, for="[2001:db8:cafe::17]:4711"
+func parseForwarded(value string) []forwardedHop {
+	hops := make([]forwardedHop, 0)
+	for _, element := range splitQuoted(value, ',') {
+		var hop forwardedHop
+		for _, pair := range splitQuoted(element, ';') {
+			name, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+			if !found {
+				continue
+			}
+
+			value = strings.Trim(value, `"`)
+			switch strings.ToLower(name) {
+			case "for":
+				hop.forAddr = value
+			case "proto":
+				hop.proto = value
+			case "host":
+				hop.host = value
+			}
+		}
+		hops = append(hops, hop)
+	}
+	return hops
+}
+
+// splits s on sep, ignoring separators within quoted strings
+func splitQuoted(s string, sep byte) []string {
+	parts := make([]string, 0)
+	inQuotes := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '"':
+			inQuotes = !inQuotes
+		case s[i] == sep && !inQuotes:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -0,0 +1,142 @@
+package simplehttp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// the signature that starts every version 2 PROXY protocol header
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+const proxyV1MaxLength int = 107
+
+// readConnRequest reads a request from conn, after the PROXY protocol header
+// if the server expects one. Returns the request and the address of the
+// client that sent it.
+func (s *Server) readConnRequest(conn net.Conn) (Request, string, error) {
+	clientAddr := conn.RemoteAddr().String()
+	if s.ProxyProtocol {
+		proxiedAddr, err := readProxyHeader(conn)
+		if err != nil {
+			return Request{}, clientAddr, err
+		}
+
+		if proxiedAddr != "" {
+			clientAddr = proxiedAddr
+		}
+	}
+
+	request, err := readRequest(conn, s.MaxRequestBytes)
+	return request, clientAddr, err
+}
+
+// readProxyHeader reads a version 1 or 2 PROXY protocol header from the
+// start of r and returns the address of the client it describes. The returned
+// address is empty if the header does not describe a TCP connection, for
+// example because the proxy sent it for a health check. Exactly the bytes of
+// the header are read, so the request can be read from r afterwards.
+// See https://www.haproxy.org/download/2.9/doc/proxy-protocol.txt
+func readProxyHeader(r io.Reader) (string, error) {
+	start := make([]byte, len(proxyV2Signature))
+	_, err := io.ReadFull(r, start)
+	if err != nil {
+		return "", fmt.Errorf("unable to read PROXY protocol header: %v", err)
+	}
+
+	if bytes.Equal(start, proxyV2Signature) {
+		return readProxyV2Header(r)
+	}
+
+	if !bytes.HasPrefix(start, []byte("PROXY ")) {
+		return "", fmt.Errorf("connection did not start with a PROXY protocol header")
+	}
+
+	return readProxyV1Header(r, start)
+}
+
+// reads the rest of a version 1 header, ex. "PROXY TCP4 192.0.2.1 192.0.2.2 56324 80\r\n"
+func readProxyV1Header(r io.Reader, start []byte) (string, error) {
+	line := append([]byte{}, start...)
+	b := make([]byte, 1)
+	for !bytes.HasSuffix(line, []byte(lineEnd)) {
+		if len(line) >= proxyV1MaxLength {
+			return "", fmt.Errorf("PROXY protocol header exceeded %d bytes", proxyV1MaxLength)
+		}
+
+		_, err := io.ReadFull(r, b)
+		if err != nil {
+			return "", fmt.Errorf("unable to read PROXY protocol header: %v", err)
+		}
+		line = append(line, b[0])
+	}
+
+	fields := strings.Fields(string(line))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return "", nil
+	}
+
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return "", fmt.Errorf("malformed PROXY protocol header: `%s`", strings.TrimSpace(string(line)))
+	}
+
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if ip == nil || err != nil {
+		return "", fmt.Errorf("malformed PROXY protocol header: `%s`", strings.TrimSpace(string(line)))
+	}
+
+	return net.JoinHostPort(ip.String(), strconv.FormatUint(port, 10)), nil
+}
+
+// reads the rest of a version 2 header, after its signature
+func readProxyV2Header(r io.Reader) (string, error) {
+	head := make([]byte, 4)
+	_, err := io.ReadFull(r, head)
+	if err != nil {
+		return "", fmt.Errorf("unable to read PROXY protocol header: %v", err)
+	}
+
+	versionCommand, family := head[0], head[1]
+	payload := make([]byte, binary.BigEndian.Uint16(head[2:4]))
+	_, err = io.ReadFull(r, payload)
+	if err != nil {
+		return "", fmt.Errorf("unable to read PROXY protocol header: %v", err)
+	}
+
+	if versionCommand>>4 != 2 {
+		return "", fmt.Errorf("unsupported PROXY protocol version: %d", versionCommand>>4)
+	}
+
+	// the LOCAL command is used for connections made by the proxy itself
+	if versionCommand&0x0f == 0x00 {
+		return "", nil
+	}
+
+	if versionCommand&0x0f != 0x01 {
+		return "", fmt.Errorf("unsupported PROXY protocol command: %d", versionCommand&0x0f)
+	}
+
+	var addrLength int
+	switch family {
+	case 0x11: // TCP over IPv4
+		addrLength = net.IPv4len
+	case 0x21: // TCP over IPv6
+		addrLength = net.IPv6len
+	default:
+		return "", nil
+	}
+
+	// source address, destination address, source port, destination port
+	if len(payload) < 2*addrLength+4 {
+		return "", fmt.Errorf("PROXY protocol header is too short for its address family")
+	}
+
+	ip := net.IP(payload[:addrLength])
+	port := binary.BigEndian.Uint16(payload[2*addrLength:])
+	return net.JoinHostPort(ip.String(), strconv.Itoa(int(port))), nil
+}
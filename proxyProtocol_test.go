@@ -0,0 +1,125 @@
+package simplehttp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestReadProxyHeader_V1(t *testing.T) {
+	r := strings.NewReader("PROXY TCP4 192.0.2.1 192.0.2.2 56324 80\r\nGET / HTTP/1.0\r\n\r\n")
+	addr, err := readProxyHeader(r)
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+
+	if addr != "192.0.2.1:56324" {
+		t.Fatalf("incorrect address. Expected '192.0.2.1:56324' | Actual '%s'", addr)
+	}
+
+	rest, _ := io.ReadAll(r)
+	if string(rest) != "GET / HTTP/1.0\r\n\r\n" {
+		t.Fatalf("expected only the header to be read. Remaining '%s'", rest)
+	}
+
+	addr, err = readProxyHeader(strings.NewReader("PROXY TCP6 2001:db8::1 2001:db8::2 4711 443\r\n"))
+	if err != nil || addr != "[2001:db8::1]:4711" {
+		t.Fatalf("incorrect address. Expected '[2001:db8::1]:4711' | Actual '%s' (%v)", addr, err)
+	}
+
+	addr, err = readProxyHeader(strings.NewReader("PROXY UNKNOWN\r\n"))
+	if err != nil || addr != "" {
+		t.Fatalf("expected no address for UNKNOWN. Actual '%s' (%v)", addr, err)
+	}
+}
+
+func TestReadProxyHeader_V1Invalid(t *testing.T) {
+	invalid := []string{
+		"GET / HTTP/1.0\r\n\r\n",
+		"PROXY TCP4 192.0.2.1 56324 80\r\n",
+		"PROXY TCP4 not-an-ip 192.0.2.2 56324 80\r\n",
+		"PROXY TCP4 192.0.2.1 192.0.2.2 99999 80\r\n",
+		"PROXY TCP4 " + strings.Repeat("1", 200) + "\r\n",
+	}
+
+	for _, header := range invalid {
+		_, err := readProxyHeader(strings.NewReader(header))
+		if err == nil {
+			t.Fatalf("expected an error for header '%s'", header)
+		}
+	}
+}
+
+func buildProxyV2Header(command byte, family byte, addrs []byte) []byte {
+	header := append([]byte{}, proxyV2Signature...)
+	header = append(header, 0x20|command, family)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(addrs)))
+	return append(header, addrs...)
+}
+
+func TestReadProxyHeader_V2(t *testing.T) {
+	addrs := []byte{192, 0, 2, 1, 192, 0, 2, 2, 0xdc, 0x04, 0x00, 0x50}
+	r := bytes.NewReader(append(buildProxyV2Header(0x01, 0x11, addrs), []byte("GET")...))
+
+	addr, err := readProxyHeader(r)
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+
+	if addr != "192.0.2.1:56324" {
+		t.Fatalf("incorrect address. Expected '192.0.2.1:56324' | Actual '%s'", addr)
+	}
+
+	rest, _ := io.ReadAll(r)
+	if string(rest) != "GET" {
+		t.Fatalf("expected only the header to be read. Remaining '%s'", rest)
+	}
+
+	ipv6 := append(append(net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")...), 0x12, 0x67, 0x01, 0xbb)
+	addr, err = readProxyHeader(bytes.NewReader(buildProxyV2Header(0x01, 0x21, ipv6)))
+	if err != nil || addr != "[2001:db8::1]:4711" {
+		t.Fatalf("incorrect address. Expected '[2001:db8::1]:4711' | Actual '%s' (%v)", addr, err)
+	}
+
+	addr, err = readProxyHeader(bytes.NewReader(buildProxyV2Header(0x00, 0x00, nil)))
+	if err != nil || addr != "" {
+		t.Fatalf("expected no address for the LOCAL command. Actual '%s' (%v)", addr, err)
+	}
+
+	_, err = readProxyHeader(bytes.NewReader(buildProxyV2Header(0x01, 0x11, addrs[:6])))
+	if err == nil {
+		t.Fatalf("expected an error for a truncated address")
+	}
+}
+
+func TestServer_ProxyProtocol(t *testing.T) {
+	server := NewServer(0)
+	server.ProxyProtocol = true
+	server.TrustedProxies = []string{"192.0.2.1"}
+	server.loadTrustedProxies()
+
+	var remoteAddr string
+	server.Get("/hello", func(req Request, res *Response) error {
+		remoteAddr = req.RemoteAddr()
+		return nil
+	})
+
+	response := sendRawRequest(&server, "PROXY TCP4 192.0.2.1 192.0.2.2 56324 80\r\n"+
+		"GET /hello HTTP/1.0"+lineEnd+"X-Forwarded-For: 203.0.113.7"+doubleLineEnd)
+
+	if !strings.HasPrefix(response, "HTTP/1.0 200 OK") {
+		t.Fatalf("incorrect response. Actual '%s'", response)
+	}
+
+	if remoteAddr != "203.0.113.7" {
+		t.Fatalf("incorrect remote address. Expected '203.0.113.7' | Actual '%s'", remoteAddr)
+	}
+
+	response = sendRawRequest(&server, "GET /hello HTTP/1.0"+doubleLineEnd)
+	if response != "" {
+		t.Fatalf("expected the connection to be closed without a response. Actual '%s'", response)
+	}
+}
@@ -0,0 +1,170 @@
+package simplehttp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := parseTrustedProxies([]string{"10.0.0.1", "192.168.0.0/16", "::1"})
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+
+	if len(prefixes) != 3 || prefixes[0].String() != "10.0.0.1/32" || prefixes[1].String() != "192.168.0.0/16" {
+		t.Fatalf("incorrect prefixes. Actual '%v'", prefixes)
+	}
+
+	_, err = parseTrustedProxies([]string{"not-an-ip"})
+	if err == nil {
+		t.Fatalf("expected an error for an invalid address")
+	}
+}
+
+func TestIsTrustedProxy(t *testing.T) {
+	trusted, _ := parseTrustedProxies([]string{"10.0.0.0/8", "2001:db8::1"})
+	cases := map[string]bool{
+		"10.1.2.3:5000":       true,
+		"10.1.2.3":            true,
+		"[::ffff:10.1.2.3]:5": true,
+		"[2001:db8::1]:4711":  true,
+		"[2001:db8::1]":       true,
+		"2001:db8::1":         true,
+		"192.168.0.1:5000":    false,
+		"unknown":             false,
+	}
+
+	for address, expected := range cases {
+		if isTrustedProxy(address, trusted) != expected {
+			t.Fatalf("incorrect trust of '%s'. Expected '%v'", address, expected)
+		}
+	}
+}
+
+func TestParseForwarded(t *testing.T) {
+	hops := parseForwarded(`for=192.0.2.60;proto=https;host="example.com";by=203.0.113.43, ` +
+		`For="[2001:db8:cafe::17]:4711"`)
+
+	if len(hops) != 2 {
+		t.Fatalf("incorrect number of hops. Expected '2' | Actual '%d'", len(hops))
+	}
+
+	expected := forwardedHop{forAddr: "192.0.2.60", proto: "https", host: "example.com"}
+	if hops[0] != expected {
+		t.Fatalf("incorrect hop. Expected '%+v' | Actual '%+v'", expected, hops[0])
+	}
+
+	if hops[1].forAddr != "[2001:db8:cafe::17]:4711" {
+		t.Fatalf("incorrect address. Expected '[2001:db8:cafe::17]:4711' | Actual '%s'", hops[1].forAddr)
+	}
+}
+
+func TestServer_ResolveClient(t *testing.T) {
+	server := NewServer(0)
+	server.TrustedProxies = []string{"10.0.0.0/8"}
+	server.loadTrustedProxies()
+
+	req := server.resolveClient(Request{
+		remoteAddr: "10.0.0.2:5000",
+		headers: headers{
+			"Host":              "internal:8080",
+			"X-Forwarded-For":   "1.1.1.1, 203.0.113.7, 10.0.0.1",
+			"X-Forwarded-Proto": "https, http",
+			"X-Forwarded-Host":  "example.com, internal:8080",
+		},
+	})
+
+	if req.RemoteAddr() != "203.0.113.7" || req.Scheme() != "https" || req.Host() != "example.com" {
+		t.Fatalf("incorrect client. Actual '%s' '%s' '%s'", req.RemoteAddr(), req.Scheme(), req.Host())
+	}
+
+	req = server.resolveClient(Request{
+		remoteAddr: "10.0.0.2:5000",
+		headers:    headers{"Forwarded": "for=198.51.100.17;proto=https, for=10.0.0.1", "X-Forwarded-For": "1.1.1.1"},
+	})
+
+	if req.RemoteAddr() != "198.51.100.17" || req.Scheme() != "https" {
+		t.Fatalf("incorrect client from Forwarded. Actual '%s' '%s'", req.RemoteAddr(), req.Scheme())
+	}
+}
+
+func TestServer_ResolveClient_SpoofedForwardedValues(t *testing.T) {
+	server := NewServer(0)
+	server.TrustedProxies = []string{"10.0.0.0/8"}
+	server.loadTrustedProxies()
+
+	// the client sent the first value of each header, and the proxy appended the rest
+	req := server.resolveClient(Request{
+		remoteAddr: "10.0.0.2:5000",
+		headers: headers{
+			"Host":              "example.com",
+			"X-Forwarded-For":   "203.0.113.7",
+			"X-Forwarded-Proto": "https, http",
+			"X-Forwarded-Host":  "evil.com, example.com",
+		},
+	})
+
+	if req.RemoteAddr() != "203.0.113.7" || req.Scheme() != "http" || req.Host() != "example.com" {
+		t.Fatalf("expected the values sent by the client to be ignored. Actual '%s' '%s' '%s'",
+			req.RemoteAddr(), req.Scheme(), req.Host())
+	}
+}
+
+func TestServer_ResolveClient_Untrusted(t *testing.T) {
+	server := NewServer(0)
+	server.TrustedProxies = []string{"10.0.0.0/8"}
+	server.loadTrustedProxies()
+
+	req := server.resolveClient(Request{
+		remoteAddr: "203.0.113.7:5000",
+		headers:    headers{"Host": "example.com", "X-Forwarded-For": "1.1.1.1", "X-Forwarded-Proto": "https"},
+	})
+
+	if req.RemoteAddr() != "203.0.113.7:5000" || req.Scheme() != "http" || req.Host() != "example.com" {
+		t.Fatalf("expected forwarded headers to be ignored. Actual '%s' '%s' '%s'",
+			req.RemoteAddr(), req.Scheme(), req.Host())
+	}
+
+	server.TrustedProxies = nil
+	server.loadTrustedProxies()
+	req = server.resolveClient(Request{remoteAddr: "10.0.0.2:5000", headers: headers{"X-Forwarded-For": "1.1.1.1"}})
+	if req.RemoteAddr() != "10.0.0.2:5000" {
+		t.Fatalf("expected forwarded headers to be ignored without trusted proxies. Actual '%s'", req.RemoteAddr())
+	}
+}
+
+func TestServer_ResolveClient_NotAnIPAddress(t *testing.T) {
+	server := NewServer(0)
+	server.TrustedProxies = []string{"10.0.0.0/8"}
+	server.loadTrustedProxies()
+
+	for _, forwarded := range []string{"for=unknown;proto=https", `for="_hidden";proto=https`} {
+		req := server.resolveClient(Request{remoteAddr: "10.0.0.2:5000", headers: headers{"Forwarded": forwarded}})
+		if req.RemoteAddr() != "10.0.0.2:5000" || req.Scheme() != "https" {
+			t.Fatalf("expected the identifier in '%s' to be skipped. Actual '%s' '%s'",
+				forwarded, req.RemoteAddr(), req.Scheme())
+		}
+	}
+}
+
+func TestServer_Handler_InvalidTrustedProxies(t *testing.T) {
+	server := NewServer(0)
+	server.TrustedProxies = []string{"not-an-ip"}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected Handler to panic for the invalid trusted proxy")
+		}
+	}()
+	server.Handler()
+}
+
+func TestServer_Start_InvalidTrustedProxies(t *testing.T) {
+	server := NewServer(0)
+	server.TrustedProxies = []string{"10.0.0.0/33"}
+
+	err := server.Start()
+	if err == nil || !strings.Contains(err.Error(), "10.0.0.0/33") {
+		t.Fatalf("expected an error for the invalid trusted proxy. Actual '%v'", err)
+	}
+}
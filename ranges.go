@@ -0,0 +1,201 @@
+package simplehttp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxRanges is the maximum number of ranges the server will serve in a
+// single response. Requests for more ranges receive the full body.
+const maxRanges int = 32
+
+// a satisfiable byte range, where end is inclusive
+type byteRange struct {
+	start int
+	end   int
+}
+
+func (br byteRange) contentRange(size int) string {
+	return fmt.Sprintf("bytes %d-%d/%d", br.start, br.end, size)
+}
+
+// Enables range requests for the Response by setting the Accept-Ranges
+// header to "bytes". When a client requests part of the body using the Range
+// header, the server will send a 206 Partial Content response containing
+// only the requested ranges, or a 416 Range Not Satisfiable response if
+// none of the ranges overlap the body. [Response.SetFile] and
+// [Response.SetFileWithContentType] enable range requests automatically.
+func (r *Response) AcceptRanges() {
+	r.headers["Accept-Ranges"] = "bytes"
+}
+
+// applyRange changes res into a 206 Partial Content response if the request
+// has a Range header that can be satisfied, following RFC 9110 Section 14.
+// Only GET requests for successful responses that accept byte ranges are
+// considered. If the Range header is invalid, or an If-Range header no longer
+// matches the response, the full response is left as it is. Ranges that
+// overlap are merged so that no part of the body is sent more than once.
+func applyRange(req Request, res *Response) {
+	if req.method != get || res.statusCode != 200 || res.headers["Accept-Ranges"] != "bytes" {
+		return
+	}
+
+	rangeHeader, exists := req.headers.get("Range")
+	if !exists {
+		return
+	}
+
+	if ifRange, exists := req.headers.get("If-Range"); exists && !ifRangeMatches(ifRange, res) {
+		return
+	}
+
+	size := len(res.body)
+	ranges, err := parseRange(rangeHeader, size)
+	if err != nil {
+		return
+	}
+
+	if len(ranges) == 0 {
+		res.SetStatus(416)
+		res.body = ""
+		res.headers["Content-Range"] = fmt.Sprintf("bytes */%d", size)
+		res.headers["Content-Length"] = "0"
+		delete(res.headers, "Content-Type")
+		return
+	}
+
+	ranges = coalesceRanges(ranges)
+	res.SetStatus(206)
+	if len(ranges) == 1 {
+		res.headers["Content-Range"] = ranges[0].contentRange(size)
+		res.body = res.body[ranges[0].start : ranges[0].end+1]
+		res.headers["Content-Length"] = strconv.Itoa(len(res.body))
+		return
+	}
+
+	boundary := newMultipartBoundary()
+	contentType := res.headers["Content-Type"]
+	var body strings.Builder
+	for _, br := range ranges {
+		body.WriteString("--" + boundary + lineEnd)
+		if contentType != "" {
+			body.WriteString("Content-Type: " + contentType + lineEnd)
+		}
+		body.WriteString("Content-Range: " + br.contentRange(size) + doubleLineEnd)
+		body.WriteString(res.body[br.start:br.end+1] + lineEnd)
+	}
+	body.WriteString("--" + boundary + "--" + lineEnd)
+
+	res.body = body.String()
+	res.headers["Content-Type"] = "multipart/byteranges; boundary=" + boundary
+	res.headers["Content-Length"] = strconv.Itoa(len(res.body))
+}
+
+// sorts the ranges and merges those that overlap or are adjacent, as allowed
+// by RFC 9110 Section 14.2, so a client cannot request the same bytes many times
+func coalesceRanges(ranges []byteRange) []byteRange {
+	sorted := slices.Clone(ranges)
+	slices.SortFunc(sorted, func(a, b byteRange) int { return a.start - b.start })
+
+	merged := []byteRange{sorted[0]}
+	for _, br := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if br.start <= last.end+1 {
+			last.end = max(last.end, br.end)
+			continue
+		}
+		merged = append(merged, br)
+	}
+	return merged
+}
+
+// The If-Range header contains either an entity tag, which must strongly
+// match the response's ETag, or a date, which must exactly match the
+// response's Last-Modified header.
+func ifRangeMatches(ifRange string, res *Response) bool {
+	ifRange = strings.TrimSpace(ifRange)
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		condition, ok := parseEntityTag(ifRange)
+		etag, hasETag := parseEntityTag(res.headers["ETag"])
+		return ok && hasETag && condition.strongMatch(etag)
+	}
+
+	condition, err := parseHttpDate(ifRange)
+	if err != nil {
+		return false
+	}
+
+	lastModified, err := parseHttpDate(res.headers["Last-Modified"])
+	return err == nil && lastModified.Equal(condition)
+}
+
+// parses the value of a Range header for a body of the provided size.
+// Ranges that cannot be satisfied are dropped, so an empty result means
+// none of the ranges could be satisfied. An error is returned if the header
+// is malformed, uses a unit other than bytes, or contains too many ranges.
+func parseRange(value string, size int) ([]byteRange, error) {
+	unit, rangeSet, found := strings.Cut(value, "=")
+	if !found || strings.TrimSpace(unit) != "bytes" {
+		return nil, fmt.Errorf("unsupported range unit in `%s`", value)
+	}
+
+	specs := strings.Split(rangeSet, ",")
+	if len(specs) > maxRanges {
+		return nil, fmt.Errorf("too many ranges requested")
+	}
+
+	ranges := make([]byteRange, 0)
+	for _, spec := range specs {
+		first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+		if !found {
+			return nil, fmt.Errorf("invalid range `%s`", spec)
+		}
+
+		// suffix range, ex. "-500" is the last 500 bytes
+		if first == "" {
+			length, err := strconv.Atoi(last)
+			if err != nil || length < 0 {
+				return nil, fmt.Errorf("invalid range `%s`", spec)
+			}
+
+			if length == 0 || size == 0 {
+				continue
+			}
+
+			ranges = append(ranges, byteRange{max(size-length, 0), size - 1})
+			continue
+		}
+
+		start, err := strconv.Atoi(first)
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid range `%s`", spec)
+		}
+
+		end := size - 1
+		if last != "" {
+			end, err = strconv.Atoi(last)
+			if err != nil || end < start {
+				return nil, fmt.Errorf("invalid range `%s`", spec)
+			}
+			end = min(end, size-1)
+		}
+
+		if start >= size {
+			continue
+		}
+
+		ranges = append(ranges, byteRange{start, end})
+	}
+
+	return ranges, nil
+}
+
+func newMultipartBoundary() string {
+	random := make([]byte, 16)
+	rand.Read(random)
+	return hex.EncodeToString(random)
+}
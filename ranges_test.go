@@ -0,0 +1,165 @@
+package simplehttp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRange(t *testing.T) {
+	ranges, err := parseRange("bytes=0-1, 4-, -3, 20-30, 8-100", 10)
+	if err != nil {
+		t.Fatalf("did not expect an error, but received the following: %v", err)
+	}
+
+	expected := []byteRange{{0, 1}, {4, 9}, {7, 9}, {8, 9}}
+	if len(ranges) != len(expected) {
+		t.Fatalf("incorrect ranges. Expected %v | Actual %v", expected, ranges)
+	}
+
+	for i := range ranges {
+		if ranges[i] != expected[i] {
+			t.Fatalf("incorrect range. Expected %v | Actual %v", expected[i], ranges[i])
+		}
+	}
+}
+
+func TestParseRange_Invalid(t *testing.T) {
+	invalid := []string{"items=0-1", "bytes=5-1", "bytes=a-b", "bytes=1", "0-1"}
+
+	for _, value := range invalid {
+		_, err := parseRange(value, 10)
+		if err == nil {
+			t.Fatalf("expected an error for '%s', but it was nil", value)
+		}
+	}
+}
+
+func TestApplyRange_SingleRange(t *testing.T) {
+	req := Request{method: get, headers: headers{"Range": "bytes=2-5"}}
+	res := newResponse()
+	res.SetHtml("0123456789")
+	res.SetETag("v1", false)
+	res.SetLastModified(time.Date(2024, 5, 12, 5, 53, 22, 0, time.UTC))
+	res.AcceptRanges()
+	applyRange(req, &res)
+
+	if res.statusCode != 206 || res.reasonPhrase != "Partial Content" {
+		t.Fatalf("incorrect status. Expected '206 Partial Content' | Actual '%d %s'",
+			res.statusCode, res.reasonPhrase)
+	}
+
+	if res.body != "2345" || res.headers["Content-Length"] != "4" {
+		t.Fatalf("incorrect body. Expected '2345' | Actual '%s'", res.body)
+	}
+
+	if res.headers["Content-Range"] != "bytes 2-5/10" {
+		t.Fatalf("incorrect Content-Range. Expected 'bytes 2-5/10' | Actual '%s'", res.headers["Content-Range"])
+	}
+}
+
+func TestApplyRange_MultipleRanges(t *testing.T) {
+	req := Request{method: get, headers: headers{"Range": "bytes=0-1,-2"}}
+	res := newResponse()
+	res.SetHtml("0123456789")
+	res.SetETag("v1", false)
+	res.SetLastModified(time.Date(2024, 5, 12, 5, 53, 22, 0, time.UTC))
+	res.AcceptRanges()
+	applyRange(req, &res)
+
+	if res.statusCode != 206 {
+		t.Fatalf("incorrect status. Expected '206' | Actual '%d'", res.statusCode)
+	}
+
+	contentType := res.headers["Content-Type"]
+	boundary, found := strings.CutPrefix(contentType, "multipart/byteranges; boundary=")
+	if !found {
+		t.Fatalf("incorrect Content-Type. Expected 'multipart/byteranges' | Actual '%s'", contentType)
+	}
+
+	expected := "--" + boundary + lineEnd +
+		"Content-Type: text/html" + lineEnd +
+		"Content-Range: bytes 0-1/10" + doubleLineEnd +
+		"01" + lineEnd +
+		"--" + boundary + lineEnd +
+		"Content-Type: text/html" + lineEnd +
+		"Content-Range: bytes 8-9/10" + doubleLineEnd +
+		"89" + lineEnd +
+		"--" + boundary + "--" + lineEnd
+
+	if res.body != expected {
+		t.Fatalf("incorrect body. Expected '%s' | Actual '%s'", expected, res.body)
+	}
+}
+
+func TestApplyRange_OverlappingRanges(t *testing.T) {
+	req := Request{method: get, headers: headers{"Range": "bytes=0-,0-,0-,0-"}}
+	res := newResponse()
+	res.SetHtml("0123456789")
+	res.AcceptRanges()
+	applyRange(req, &res)
+
+	if res.statusCode != 206 || res.body != "0123456789" || res.headers["Content-Range"] != "bytes 0-9/10" {
+		t.Fatalf("expected the ranges to be merged. Actual %d '%s' %v", res.statusCode, res.body, res.headers)
+	}
+
+	ranges := coalesceRanges([]byteRange{{6, 8}, {0, 1}, {2, 3}, {7, 9}})
+	expected := []byteRange{{0, 3}, {6, 9}}
+	if len(ranges) != len(expected) || ranges[0] != expected[0] || ranges[1] != expected[1] {
+		t.Fatalf("incorrect ranges. Expected %v | Actual %v", expected, ranges)
+	}
+}
+
+func TestApplyRange_NotSatisfiable(t *testing.T) {
+	req := Request{method: get, headers: headers{"Range": "bytes=10-20"}}
+	res := newResponse()
+	res.SetHtml("0123456789")
+	res.SetETag("v1", false)
+	res.SetLastModified(time.Date(2024, 5, 12, 5, 53, 22, 0, time.UTC))
+	res.AcceptRanges()
+	applyRange(req, &res)
+
+	if res.statusCode != 416 || res.body != "" {
+		t.Fatalf("incorrect response. Expected '416' without a body | Actual '%d %s'", res.statusCode, res.body)
+	}
+
+	if res.headers["Content-Range"] != "bytes */10" {
+		t.Fatalf("incorrect Content-Range. Expected 'bytes */10' | Actual '%s'", res.headers["Content-Range"])
+	}
+}
+
+func TestApplyRange_IfRange(t *testing.T) {
+	conditions := map[string]uint{
+		`"v1"`:                          206,
+		`"v2"`:                          200,
+		`W/"v1"`:                        200,
+		"Sun, 12 May 2024 05:53:22 GMT": 206,
+		"Sat, 11 May 2024 05:53:22 GMT": 200,
+	}
+
+	for condition, expected := range conditions {
+		req := Request{method: get, headers: headers{"Range": "bytes=0-1", "If-Range": condition}}
+		res := newResponse()
+		res.SetHtml("0123456789")
+		res.SetETag("v1", false)
+		res.SetLastModified(time.Date(2024, 5, 12, 5, 53, 22, 0, time.UTC))
+		res.AcceptRanges()
+		applyRange(req, &res)
+
+		if res.statusCode != expected {
+			t.Fatalf("incorrect status for If-Range '%s'. Expected '%d' | Actual '%d'",
+				condition, expected, res.statusCode)
+		}
+	}
+}
+
+func TestApplyRange_RequiresAcceptRanges(t *testing.T) {
+	req := Request{method: get, headers: headers{"Range": "bytes=0-1"}}
+	res := newResponse()
+	res.SetHtml("0123456789")
+	applyRange(req, &res)
+
+	if res.statusCode != 200 || res.body != "0123456789" {
+		t.Fatalf("expected the full response | Actual '%d %s'", res.statusCode, res.body)
+	}
+}
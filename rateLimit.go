@@ -0,0 +1,302 @@
+package simplehttp
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitAlgorithm is the algorithm a [RateLimiter] uses to decide whether
+// a request is allowed.
+type RateLimitAlgorithm int
+
+const (
+	// TokenBucket gives each key a bucket that holds up to Limit tokens and
+	// refills at a rate of Limit tokens per Window. Each request takes a
+	// token, so short bursts of up to Limit requests are allowed.
+	TokenBucket RateLimitAlgorithm = iota
+	// SlidingWindow allows up to Limit requests in any period of length Window.
+	// It estimates the number of requests in the period from the counts of
+	// the current and previous fixed windows, weighting the previous window
+	// by how much of it overlaps the period.
+	SlidingWindow
+)
+
+// RateLimitPolicy describes how many requests a [RateLimiter] allows.
+type RateLimitPolicy struct {
+	Algorithm RateLimitAlgorithm
+	// Limit is the number of requests allowed per Window.
+	Limit int
+	// Window is the period of time that Limit applies to.
+	Window time.Duration
+}
+
+// RateLimitResult is the outcome of recording a request in a [RateLimitStore].
+type RateLimitResult struct {
+	// Allowed is true if the request is within the policy's limit.
+	Allowed bool
+	// Remaining is the number of requests that are still allowed right now.
+	Remaining int
+	// Reset is the time until the limit is fully available again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request would be allowed.
+	// It is 0 if Allowed is true.
+	RetryAfter time.Duration
+}
+
+// A RateLimitStore keeps the state a [RateLimiter] needs to enforce its
+// policy for each key. Implement RateLimitStore to share limits between
+// several servers, for example by keeping the state in a database, or use
+// [NewMemoryRateLimitStore]. Methods may be called concurrently.
+type RateLimitStore interface {
+	// Take records a request for key made at the time now and returns whether
+	// it is allowed under policy. Requests that are not allowed are not counted
+	// against the limit.
+	Take(key string, policy RateLimitPolicy, now time.Time) (RateLimitResult, error)
+}
+
+// RateLimiter is middleware that limits how often clients can make requests.
+// A RateLimiter should only be created using the [NewRateLimiter] method to
+// ensure it is properly initialized. It is registered using [Server.Use] to
+// limit every request, or it can wrap the callback of specific paths:
+//
+//	limiter := simplehttp.NewRateLimiter(5, time.Minute)
+//	server.Post("/login", limiter.Middleware(login))
+//
+// Requests are grouped by the key returned from KeyFunc. Requests that
+// exceed the limit receive a 429 Too Many Requests response with a
+// Retry-After header, and are not passed on to the next callback.
+// Every response includes the RateLimit-Limit, RateLimit-Remaining,
+// RateLimit-Reset, and RateLimit-Policy headers.
+type RateLimiter struct {
+	// Algorithm is the algorithm used to enforce the limit. Defaults to [TokenBucket].
+	Algorithm RateLimitAlgorithm
+	// Limit is the number of requests allowed per Window.
+	Limit int
+	// Window is the period of time that Limit applies to.
+	Window time.Duration
+	// KeyFunc returns the key that a request is counted against. Requests for
+	// which KeyFunc returns an empty string share a single limit.
+	// Defaults to [KeyByIP].
+	KeyFunc func(Request) string
+	// Store keeps the state of each key. Defaults to a store created with
+	// [NewMemoryRateLimitStore].
+	Store RateLimitStore
+	now   func() time.Time
+}
+
+// Creates and initializes a new [RateLimiter] that allows each client IP
+// address limit requests per window, using the [TokenBucket] algorithm and
+// an in-memory store.
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		Algorithm: TokenBucket,
+		Limit:     limit,
+		Window:    window,
+		KeyFunc:   KeyByIP,
+		Store:     NewMemoryRateLimitStore(),
+		now:       time.Now,
+	}
+}
+
+// KeyByIP groups requests by the IP address of the client.
+func KeyByIP(req Request) string {
+	return remoteHost(req.remoteAddr)
+}
+
+// Returns a function that groups requests by the value of the named header,
+// such as an API key.
+func KeyByHeader(name string) func(Request) string {
+	return func(req Request) string {
+		value, _ := req.headers.get(name)
+		return value
+	}
+}
+
+// Middleware is the [MiddlewareFunc] to be registered on the [Server] using
+// [Server.Use].
+func (rl *RateLimiter) Middleware(next CallbackFunc) CallbackFunc {
+	return func(req Request, res *Response) error {
+		policy := RateLimitPolicy{rl.Algorithm, rl.Limit, rl.Window}
+		result, err := rl.Store.Take(rl.KeyFunc(req), policy, rl.now())
+		if err != nil {
+			return fmt.Errorf("unable to check the rate limit: %w", err)
+		}
+
+		// the headers are kept if next returns an error, since
+		// the request still counts towards the limit
+		res.keepHeader("RateLimit-Limit", strconv.Itoa(policy.Limit))
+		res.keepHeader("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		res.keepHeader("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		res.keepHeader("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Limit, ceilSeconds(policy.Window)))
+
+		if !result.Allowed {
+			res.SetStatus(429)
+			res.headers["Retry-After"] = strconv.Itoa(ceilSeconds(result.RetryAfter))
+			return nil
+		}
+
+		return next(req, res)
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// MemoryRateLimitStore is a [RateLimitStore] that keeps the state of each key
+// in memory. Keys that have not been used for longer than their policy's
+// window are removed periodically. A MemoryRateLimitStore should only be
+// created using the [NewMemoryRateLimitStore] method.
+type MemoryRateLimitStore struct {
+	mutex     sync.Mutex
+	entries   map[rateLimitKey]*rateLimitEntry
+	lastSweep time.Time
+}
+
+type rateLimitKey struct {
+	policy RateLimitPolicy
+	key    string
+}
+
+type rateLimitEntry struct {
+	// token bucket state
+	tokens     float64
+	lastRefill time.Time
+
+	// sliding window state
+	windowStart   time.Time
+	currentCount  int
+	previousCount int
+
+	expires time.Time
+}
+
+const rateLimitSweepInterval time.Duration = time.Minute
+
+// Creates a new, empty [MemoryRateLimitStore].
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{entries: make(map[rateLimitKey]*rateLimitEntry)}
+}
+
+// Take records a request for key made at the time now and returns whether
+// it is allowed under policy.
+func (s *MemoryRateLimitStore) Take(key string, policy RateLimitPolicy, now time.Time) (RateLimitResult, error) {
+	if policy.Limit <= 0 || policy.Window <= 0 {
+		return RateLimitResult{}, fmt.Errorf("invalid rate limit policy: limit %d per %v", policy.Limit, policy.Window)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.sweep(now)
+
+	mapKey := rateLimitKey{policy, key}
+	entry, exists := s.entries[mapKey]
+	if !exists {
+		entry = &rateLimitEntry{tokens: float64(policy.Limit), lastRefill: now}
+		s.entries[mapKey] = entry
+	}
+
+	if policy.Algorithm == SlidingWindow {
+		return entry.takeSlidingWindow(policy, now), nil
+	}
+	return entry.takeToken(policy, now), nil
+}
+
+// removes entries that have expired, at most once per rateLimitSweepInterval
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+
+	for key, entry := range s.entries {
+		if now.After(entry.expires) {
+			delete(s.entries, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+func (e *rateLimitEntry) takeToken(policy RateLimitPolicy, now time.Time) RateLimitResult {
+	limit := float64(policy.Limit)
+	perSecond := limit / policy.Window.Seconds()
+
+	elapsed := now.Sub(e.lastRefill).Seconds()
+	if elapsed > 0 {
+		e.tokens = math.Min(limit, e.tokens+elapsed*perSecond)
+		e.lastRefill = now
+	}
+
+	result := RateLimitResult{Allowed: e.tokens >= 1}
+	if result.Allowed {
+		e.tokens--
+	} else {
+		result.RetryAfter = secondsToDuration((1 - e.tokens) / perSecond)
+	}
+
+	result.Remaining = int(e.tokens)
+	result.Reset = secondsToDuration((limit - e.tokens) / perSecond)
+	e.expires = now.Add(result.Reset)
+	return result
+}
+
+func (e *rateLimitEntry) takeSlidingWindow(policy RateLimitPolicy, now time.Time) RateLimitResult {
+	windowStart := now.Truncate(policy.Window)
+	if !windowStart.Equal(e.windowStart) {
+		if windowStart.Sub(e.windowStart) == policy.Window {
+			e.previousCount = e.currentCount
+		} else {
+			e.previousCount = 0
+		}
+		e.currentCount = 0
+		e.windowStart = windowStart
+	}
+
+	elapsed := now.Sub(windowStart)
+	untilNextWindow := policy.Window - elapsed
+	previousWeight := 1 - elapsed.Seconds()/policy.Window.Seconds()
+	estimate := float64(e.previousCount)*previousWeight + float64(e.currentCount)
+
+	result := RateLimitResult{Allowed: estimate+1 <= float64(policy.Limit)}
+	if result.Allowed {
+		e.currentCount++
+		estimate++
+	} else {
+		result.RetryAfter = e.slidingWindowRetryAfter(policy, elapsed, untilNextWindow)
+	}
+
+	result.Remaining = int(math.Max(0, float64(policy.Limit)-estimate))
+	result.Reset = untilNextWindow
+	if e.currentCount > 0 {
+		// the current window's requests count towards the next window too
+		result.Reset += policy.Window
+	}
+	e.expires = now.Add(result.Reset)
+	return result
+}
+
+// slidingWindowRetryAfter returns the time until the weight of the previous
+// window has decreased enough for another request to be allowed
+func (e *rateLimitEntry) slidingWindowRetryAfter(policy RateLimitPolicy, elapsed time.Duration,
+	untilNextWindow time.Duration) time.Duration {
+	limit := float64(policy.Limit)
+	current := float64(e.currentCount)
+	previous := float64(e.previousCount)
+
+	if current+1 <= limit {
+		// allowed once previous*weight <= limit-current-1 in this window
+		fraction := 1 - (limit-current-1)/previous
+		return secondsToDuration(fraction*policy.Window.Seconds()) - elapsed
+	}
+
+	// the current window becomes the previous window once it has finished
+	fraction := 1 - (limit-1)/current
+	return untilNextWindow + secondsToDuration(fraction*policy.Window.Seconds())
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
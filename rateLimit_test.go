@@ -0,0 +1,180 @@
+package simplehttp
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var rateLimitTestStart = time.Date(2024, 5, 12, 5, 53, 0, 0, time.UTC)
+
+func TestMemoryRateLimitStore_TokenBucket(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	policy := RateLimitPolicy{TokenBucket, 3, 3 * time.Second}
+	now := rateLimitTestStart
+
+	for i := 2; i >= 0; i-- {
+		result, _ := store.Take("client", policy, now)
+		if !result.Allowed || result.Remaining != i {
+			t.Fatalf("expected request to be allowed. Expected remaining '%d' | Actual '%d'", i, result.Remaining)
+		}
+	}
+
+	result, _ := store.Take("client", policy, now)
+	if result.Allowed || result.RetryAfter != time.Second || result.Reset != 3*time.Second {
+		t.Fatalf("expected request to be denied. Actual retry after '%v', reset '%v'", result.RetryAfter, result.Reset)
+	}
+
+	result, _ = store.Take("other", policy, now)
+	if !result.Allowed {
+		t.Fatalf("expected keys to be limited separately")
+	}
+
+	result, _ = store.Take("client", policy, now.Add(time.Second))
+	if !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("expected a token to be refilled after one second. Actual remaining '%d'", result.Remaining)
+	}
+}
+
+func TestMemoryRateLimitStore_SlidingWindow(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	policy := RateLimitPolicy{SlidingWindow, 4, 10 * time.Second}
+	now := rateLimitTestStart
+
+	for i := 0; i < 4; i++ {
+		result, _ := store.Take("client", policy, now)
+		if !result.Allowed {
+			t.Fatalf("expected request %d to be allowed", i)
+		}
+	}
+
+	result, _ := store.Take("client", policy, now)
+	if result.Allowed || result.Remaining != 0 {
+		t.Fatalf("expected request to be denied")
+	}
+
+	// 4 requests in the previous window, weighted by 0.5
+	now = now.Add(15 * time.Second)
+	for i := 0; i < 2; i++ {
+		result, _ = store.Take("client", policy, now)
+		if !result.Allowed {
+			t.Fatalf("expected request %d in the next window to be allowed", i)
+		}
+	}
+
+	result, _ = store.Take("client", policy, now)
+	if result.Allowed {
+		t.Fatalf("expected request to be denied")
+	}
+
+	// allowed once the previous window's weight drops to 0.25
+	if result.RetryAfter != 2500*time.Millisecond {
+		t.Fatalf("incorrect retry after. Expected '2.5s' | Actual '%v'", result.RetryAfter)
+	}
+
+	result, _ = store.Take("client", policy, now.Add(result.RetryAfter))
+	if !result.Allowed {
+		t.Fatalf("expected request to be allowed after waiting")
+	}
+}
+
+func TestMemoryRateLimitStore_InvalidPolicy(t *testing.T) {
+	_, err := NewMemoryRateLimitStore().Take("client", RateLimitPolicy{TokenBucket, 0, time.Second}, time.Now())
+	if err == nil {
+		t.Fatalf("expected an error for a limit of 0")
+	}
+}
+
+func TestMemoryRateLimitStore_Sweep(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	policy := RateLimitPolicy{TokenBucket, 1, time.Second}
+
+	store.Take("client", policy, rateLimitTestStart)
+	store.Take("other", policy, rateLimitTestStart.Add(2*time.Minute))
+
+	if len(store.entries) != 1 {
+		t.Fatalf("expected the expired entry to be removed. Actual '%d' entries", len(store.entries))
+	}
+}
+
+func TestRateLimiter_Middleware(t *testing.T) {
+	rl := NewRateLimiter(2, time.Minute)
+	rl.now = func() time.Time { return rateLimitTestStart }
+	req := Request{remoteAddr: "127.0.0.1:5000"}
+
+	res, next := invokeMiddleware(rl.Middleware, req)
+	if next == nil || res.statusCode != 200 {
+		t.Fatalf("expected the request to be allowed")
+	}
+
+	if res.headers["RateLimit-Limit"] != "2" || res.headers["RateLimit-Remaining"] != "1" ||
+		res.headers["RateLimit-Reset"] != "30" || res.headers["RateLimit-Policy"] != "2;w=60" {
+		t.Fatalf("incorrect RateLimit headers. Actual %v", res.headers)
+	}
+
+	invokeMiddleware(rl.Middleware, Request{remoteAddr: "127.0.0.1:5001"})
+	res, next = invokeMiddleware(rl.Middleware, req)
+	if next != nil || res.statusCode != 429 {
+		t.Fatalf("expected the request to be limited. Actual status '%d'", res.statusCode)
+	}
+
+	if res.headers["Retry-After"] != "30" || res.headers["RateLimit-Remaining"] != "0" {
+		t.Fatalf("incorrect headers. Actual %v", res.headers)
+	}
+
+	if getReasonPhrase(429) != "Too Many Requests" {
+		t.Fatalf("incorrect reason phrase. Actual '%s'", getReasonPhrase(429))
+	}
+}
+
+func TestRateLimiter_KeyByHeader(t *testing.T) {
+	rl := NewRateLimiter(1, time.Minute)
+	rl.KeyFunc = KeyByHeader("X-API-Key")
+
+	invokeMiddleware(rl.Middleware, Request{remoteAddr: "127.0.0.1:5000", headers: headers{"X-API-Key": "first"}})
+	_, next := invokeMiddleware(rl.Middleware, Request{remoteAddr: "127.0.0.1:5000", headers: headers{"x-api-key": "second"}})
+	if next == nil {
+		t.Fatalf("expected requests with different keys to be limited separately")
+	}
+
+	_, next = invokeMiddleware(rl.Middleware, Request{remoteAddr: "10.0.0.1:5000", headers: headers{"X-API-Key": "first"}})
+	if next != nil {
+		t.Fatalf("expected requests with the same key to share a limit")
+	}
+}
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(string, RateLimitPolicy, time.Time) (RateLimitResult, error) {
+	return RateLimitResult{}, errors.New("unavailable")
+}
+
+func TestRateLimiter_StoreError(t *testing.T) {
+	rl := NewRateLimiter(1, time.Minute)
+	rl.Store = failingRateLimitStore{}
+
+	res := newResponse()
+	err := rl.Middleware(func(_ Request, _ *Response) error { return nil })(Request{}, &res)
+	if err == nil {
+		t.Fatalf("expected an error when the store fails")
+	}
+}
+
+func TestRateLimiter_KeepsHeadersOnErrorResponses(t *testing.T) {
+	server := NewServer(0)
+	server.Use(NewRateLimiter(5, time.Minute).Middleware)
+	server.Get("/fail", func(_ Request, _ *Response) error {
+		return errors.New("failed")
+	})
+
+	response := sendRawRequest(&server, "GET /fail HTTP/1.0"+doubleLineEnd)
+	if !strings.HasPrefix(response, "HTTP/1.0 500 Internal Server Error"+lineEnd) {
+		t.Fatalf("incorrect status line. Actual '%s'", response)
+	}
+
+	if !strings.Contains(response, "RateLimit-Limit: 5"+lineEnd) ||
+		!strings.Contains(response, "RateLimit-Remaining: 4"+lineEnd) {
+		t.Fatalf("expected the RateLimit headers to be kept. Actual '%s'", response)
+	}
+}
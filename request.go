@@ -1,6 +1,7 @@
 package simplehttp
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"strconv"
@@ -18,6 +19,11 @@ type Request struct {
 	httpVersion string
 	headers     headers
 	body        string
+	ctx         context.Context
+	remoteAddr  string
+	id          string
+	scheme      string
+	host        string
 }
 
 // Rebuilds a string that represents the entire HTTP request.
@@ -75,6 +81,23 @@ func (r Request) RawParameters() string {
 	return r.uri.RawQuery
 }
 
+// Returns the request's context. The returned context is never nil;
+// it defaults to [context.Background].
+func (r Request) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// Returns a copy of the request with its context changed to ctx.
+// Because a Request is immutable, this is the way for a callback
+// to pass request-scoped values along.
+func (r Request) WithContext(ctx context.Context) Request {
+	r.ctx = ctx
+	return r
+}
+
 func parseRequest(rawMessage string) (Request, error) {
 	headerEnd := strings.Index(rawMessage, doubleLineEnd)
 	if headerEnd == -1 {
@@ -98,12 +121,11 @@ func parseRequest(rawMessage string) (Request, error) {
 	contentLengthStr, exists := headers["Content-Length"]
 	if !exists {
 		return Request{
-			rawMessage,
-			method,
-			uri,
-			httpVersion,
-			headers,
-			"",
+			rawMessage:  rawMessage,
+			method:      method,
+			uri:         uri,
+			httpVersion: httpVersion,
+			headers:     headers,
 		}, nil
 	}
 
@@ -122,12 +144,12 @@ func parseRequest(rawMessage string) (Request, error) {
 	body := content[:contentLength]
 
 	return Request{
-		rawMessage,
-		method,
-		uri,
-		httpVersion,
-		headers,
-		body,
+		rawMessage:  rawMessage,
+		method:      method,
+		uri:         uri,
+		httpVersion: httpVersion,
+		headers:     headers,
+		body:        body,
 	}, nil
 }
 
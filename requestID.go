@@ -0,0 +1,61 @@
+package simplehttp
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// RequestIDHeader is the header used to accept a request ID from the
+// client, and to return the request's ID in the response.
+const RequestIDHeader string = "X-Request-ID"
+
+const maxRequestIDLength int = 128
+
+// Returns the request's unique ID. The ID is taken from the request's
+// X-Request-ID header if the client sent a valid one and
+// [Server.TrustRequestID] is true. Otherwise, it is generated by the server.
+// The ID is returned to the client in the X-Request-ID response header,
+// and is included in the server's log messages for the request.
+func (r Request) ID() string {
+	return r.id
+}
+
+// generates a random, version 4 UUID to identify a request
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// assignRequestID sets the ID of the request to the value of its X-Request-ID
+// header if it can be trusted, otherwise to generated.
+func (s *Server) assignRequestID(req Request, generated string) Request {
+	req.id = generated
+	if !s.TrustRequestID {
+		return req
+	}
+
+	incoming, exists := req.headers.get(RequestIDHeader)
+	if exists && validRequestID(incoming) {
+		req.id = incoming
+	}
+	return req
+}
+
+// request IDs from clients are written to logs, so they are limited to
+// a reasonable length and to characters that cannot break a log line
+func validRequestID(id string) bool {
+	if len(id) == 0 || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		isAlphanumeric := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !isAlphanumeric && c != '-' && c != '_' && c != '.' && c != ':' && c != '/' && c != '+' && c != '=' {
+			return false
+		}
+	}
+	return true
+}
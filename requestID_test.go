@@ -0,0 +1,128 @@
+package simplehttp
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestNewRequestID(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	first := newRequestID()
+	if !uuid.MatchString(first) {
+		t.Fatalf("expected a version 4 UUID. Actual '%s'", first)
+	}
+
+	if first == newRequestID() {
+		t.Fatalf("expected request IDs to be unique")
+	}
+}
+
+func TestValidRequestID(t *testing.T) {
+	valid := []string{"abc-123", "f47ac10b-58cc-4372-a567-0e02b2c3d479", "trace:1/2+3=4_5.6"}
+	for _, id := range valid {
+		if !validRequestID(id) {
+			t.Fatalf("expected '%s' to be a valid request ID", id)
+		}
+	}
+
+	invalid := []string{"", "has space", "new\nline", `quote"`, strings.Repeat("a", 129)}
+	for _, id := range invalid {
+		if validRequestID(id) {
+			t.Fatalf("expected '%s' to be an invalid request ID", id)
+		}
+	}
+}
+
+func TestServer_RequestID(t *testing.T) {
+	server := NewServer(0)
+	var id string
+	server.Get("/hello", func(req Request, res *Response) error {
+		id = req.ID()
+		return nil
+	})
+
+	response := sendRawRequest(&server, "GET /hello HTTP/1.0"+doubleLineEnd)
+
+	if id == "" {
+		t.Fatalf("expected the request to have an ID")
+	}
+
+	if !strings.Contains(response, "X-Request-ID: "+id+lineEnd) {
+		t.Fatalf("expected the response to echo the request ID '%s'. Actual '%s'", id, response)
+	}
+}
+
+func TestServer_RequestID_Incoming(t *testing.T) {
+	server := NewServer(0)
+	server.TrustRequestID = true
+	var id string
+	server.Get("/hello", func(req Request, res *Response) error {
+		id = req.ID()
+		return nil
+	})
+
+	response := sendRawRequest(&server, "GET /hello HTTP/1.0"+lineEnd+"X-Request-ID: abc-123"+doubleLineEnd)
+	if id != "abc-123" || !strings.Contains(response, "X-Request-ID: abc-123"+lineEnd) {
+		t.Fatalf("incorrect request ID. Expected 'abc-123' | Actual '%s'", id)
+	}
+
+	response = sendRawRequest(&server, "GET /missing HTTP/1.0"+lineEnd+"X-Request-ID: abc-123"+doubleLineEnd)
+	if !strings.Contains(response, "X-Request-ID: abc-123"+lineEnd) {
+		t.Fatalf("expected the 404 response to echo the request ID. Actual '%s'", response)
+	}
+
+	server.TrustRequestID = false
+	sendRawRequest(&server, "GET /hello HTTP/1.0"+lineEnd+"X-Request-ID: abc-123"+doubleLineEnd)
+	if id == "abc-123" || id == "" {
+		t.Fatalf("expected a generated request ID. Actual '%s'", id)
+	}
+}
+
+func TestServer_RequestID_Logged(t *testing.T) {
+	server := NewServer(0)
+	server.TrustRequestID = true
+	logger := &recordingStructuredLogger{}
+	server.StructuredLogger = logger
+
+	sendRawRequest(&server, "GET /missing HTTP/1.0"+lineEnd+"X-Request-ID: abc-123"+doubleLineEnd)
+
+	connectionID := ""
+	for _, entry := range logger.entries {
+		fields := make(map[string]any)
+		for _, field := range entry.fields {
+			fields[field.Key] = field.Value
+		}
+
+		if connectionID == "" {
+			connectionID, _ = fields["connection_id"].(string)
+		}
+
+		if entry.message != "Connected to remote address" && fields["request_id"] != "abc-123" {
+			t.Fatalf("expected the log message '%s' to include the request ID", entry.message)
+		}
+
+		if _, exists := fields["connection_id"]; exists && fields["connection_id"] != connectionID {
+			t.Fatalf("expected the log message '%s' to include the connection ID '%s'", entry.message, connectionID)
+		}
+	}
+
+	if connectionID == "" || connectionID == "abc-123" {
+		t.Fatalf("expected a generated connection ID. Actual '%s'", connectionID)
+	}
+}
+
+func TestServer_RequestID_NotTrustedByDefault(t *testing.T) {
+	server := NewServer(0)
+	var id string
+	server.Get("/hello", func(req Request, res *Response) error {
+		id = req.ID()
+		return nil
+	})
+
+	sendRawRequest(&server, "GET /hello HTTP/1.0"+lineEnd+"X-Request-ID: abc-123"+doubleLineEnd)
+	if id == "abc-123" || id == "" {
+		t.Fatalf("expected a generated request ID. Actual '%s'", id)
+	}
+}
@@ -1,9 +1,15 @@
 package simplehttp
 
 import (
+	"bufio"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"html"
+	"io"
 	"mime"
+	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -21,15 +27,52 @@ type Response struct {
 	reasonPhrase string
 	headers      headers
 	body         string
+	// startStream sends the status line and headers to the client and
+	// returns a writer for the body. It is nil when the response is not
+	// attached to a client.
+	startStream func(*Response) (io.Writer, error)
+	stream      io.Writer
+	// clientGone returns a channel that is closed when the client
+	// disconnects. It is nil when the response is not attached to a client.
+	clientGone func() <-chan struct{}
+	// events is the response's event stream, if it has been started
+	events *EventStream
+	// upgrade takes over the client's connection after a 101 Switching
+	// Protocols response has been sent, such as for a WebSocket
+	upgrade func(conn net.Conn, reader *bufio.Reader) error
+	// views renders templates for Render. It is nil when the server
+	// does not have a view engine.
+	views *ViewEngine
+	// keptHeaders are headers set by middleware that describe the request
+	// rather than the callback's response, such as CORS headers. They are
+	// kept when the server replaces the response with an error response.
+	keptHeaders headers
+	// cookies are the values of the Set-Cookie headers. Unlike other headers,
+	// Set-Cookie headers cannot be combined into one, so each is sent separately.
+	cookies []string
+	// keptCookies are the cookies set using keepCookie, which are kept like keptHeaders
+	keptCookies []string
 }
 
 // Builds a string that represents the entire HTTP response.
 func (r Response) String() string {
+	return r.head() + r.body
+}
+
+// builds the status line and headers, including the blank line that
+// separates them from the body.
+func (r Response) head() string {
 	statusLine := fmt.Sprintf("%s %d %s", r.httpVersion, r.statusCode, r.reasonPhrase)
 
-	return statusLine + lineEnd +
-		r.headers.String() + doubleLineEnd +
-		r.body
+	fields := r.headers.String()
+	for _, cookie := range r.cookies {
+		if fields != "" {
+			fields += lineEnd
+		}
+		fields += "Set-Cookie: " + cookie
+	}
+
+	return statusLine + lineEnd + fields + doubleLineEnd
 }
 
 func newResponse() Response {
@@ -48,6 +91,36 @@ func newResponse() Response {
 	}
 }
 
+// sets a header that is kept if the server replaces the response with an
+// error response, such as when the callback returns an error
+func (r *Response) keepHeader(key string, value string) {
+	if r.keptHeaders == nil {
+		r.keptHeaders = make(headers)
+	}
+
+	r.headers[key] = value
+	r.keptHeaders[key] = value
+}
+
+// replaces res with replacement, keeping the headers and cookies set using
+// keepHeader and keepCookie
+func replaceResponse(res *Response, replacement Response) {
+	for key, value := range res.keptHeaders {
+		replacement.headers[key] = value
+	}
+
+	replacement.keptHeaders = res.keptHeaders
+	replacement.cookies = append(replacement.cookies, res.keptCookies...)
+	replacement.keptCookies = res.keptCookies
+	*res = replacement
+}
+
+func newStatusResponse(status uint) Response {
+	res := newResponse()
+	res.SetStatus(status)
+	return res
+}
+
 func new500StatusResponse() Response {
 	res := newResponse()
 	res.SetStatus(500)
@@ -60,6 +133,50 @@ func new404StatusResponse() Response {
 	return res
 }
 
+// parses the status line and headers of a response, such as one received by
+// a [Client]. The body is not parsed, since its length depends on the headers.
+func parseResponseHead(rawHead string) (Response, error) {
+	rawHead = strings.TrimSuffix(rawHead, doubleLineEnd)
+	rawStatusLine, rawHeaders, _ := strings.Cut(rawHead, lineEnd)
+
+	httpVersion, statusCode, reasonPhrase, err := parseStatusLine(rawStatusLine)
+	if err != nil {
+		return Response{}, &invalidMessage{err.Error()}
+	}
+
+	headers, err := parseHeaders(strings.TrimSpace(rawHeaders))
+	if err != nil {
+		return Response{}, &invalidMessage{err.Error()}
+	}
+
+	return Response{
+		httpVersion:  httpVersion,
+		statusCode:   statusCode,
+		reasonPhrase: reasonPhrase,
+		headers:      headers,
+	}, nil
+}
+
+// returns HTTP-Version, Status-Code, and Reason-Phrase
+func parseStatusLine(content string) (string, uint, string, error) {
+	split := strings.SplitN(content, " ", 3)
+	if len(split) < 2 || !strings.HasPrefix(split[0], "HTTP/") {
+		return "", 0, "", fmt.Errorf("unable to parse HTTP status-line")
+	}
+
+	status, err := strconv.ParseUint(split[1], 10, 0)
+	if err != nil || len(split[1]) != 3 || uint(status) < minStatusCode || uint(status) > maxStatusCode {
+		return "", 0, "", fmt.Errorf("invalid status code in HTTP status-line: `%s`", split[1])
+	}
+
+	reasonPhrase := ""
+	if len(split) == 3 {
+		reasonPhrase = strings.TrimSpace(split[2])
+	}
+
+	return split[0], uint(status), reasonPhrase, nil
+}
+
 // Returns the response's headers.
 func (r Response) Headers() map[string]string {
 	return r.headers
@@ -100,6 +217,31 @@ func (r *Response) SetHeader(key string, value string) error {
 	return nil
 }
 
+// Adds a cookie to the Response. Each cookie is sent in its own Set-Cookie
+// header, so several cookies can be set. An error will be returned if the
+// cookie is not valid, for example because its name is empty.
+func (r *Response) SetCookie(cookie *http.Cookie) error {
+	err := cookie.Valid()
+	if err != nil {
+		return fmt.Errorf("invalid cookie: %v", err)
+	}
+
+	r.cookies = append(r.cookies, cookie.String())
+	return nil
+}
+
+// adds a cookie that is kept if the server replaces the response with an
+// error response, like the headers set using keepHeader
+func (r *Response) keepCookie(cookie *http.Cookie) error {
+	err := r.SetCookie(cookie)
+	if err != nil {
+		return err
+	}
+
+	r.keptCookies = append(r.keptCookies, r.cookies[len(r.cookies)-1])
+	return nil
+}
+
 // Sets the Response's body to the provided html string.
 // This method will also set the Content-Length header to the length
 // of the provided input. The Content-Type header will be set to "text/html".
@@ -136,11 +278,78 @@ func (r *Response) SetJson(obj any) error {
 	return nil
 }
 
+// Sets the Response's body to the provided text.
+// This method will also set the Content-Length header to the length
+// of the provided input. The Content-Type header will be set to "text/plain".
+func (r *Response) SetText(text string) {
+	r.body = text
+	r.headers["Content-Length"] = strconv.Itoa(len(text))
+	r.headers["Content-Type"] = "text/plain"
+}
+
+// Sets the Response's body to an XML string. If obj is a string,
+// the body will be set to the provided string. If obj is any other type,
+// it will be marshalled to XML using [xml.Marshal], preceded by [xml.Header].
+// This method will set the Content-Length header appropriately as well
+// as setting the Content-Type header to "application/xml".
+// An error will be returned if there was an issue marshalling the obj.
+func (r *Response) SetXML(obj any) error {
+	body, isString := obj.(string)
+	if !isString {
+		marshalled, err := xml.Marshal(obj)
+		if err != nil {
+			return fmt.Errorf("error while marshalling object: %s", err)
+		}
+		body = xml.Header + string(marshalled)
+	}
+
+	r.body = body
+	r.headers["Content-Length"] = strconv.Itoa(len(body))
+	r.headers["Content-Type"] = "application/xml"
+	return nil
+}
+
+// Sets the Response's body to JSONP, a script that calls the function named
+// callback with obj marshalled to JSON as it would be by [Response.SetJson].
+// The callback is usually taken from a request parameter, and may only contain
+// letters, digits, and the characters '_', '$', '.', '[', and ']'.
+// The Content-Type header will be set to "text/javascript" and the
+// X-Content-Type-Options header to "nosniff". If callback is empty, this
+// behaves the same as [Response.SetJson].
+// An error will be returned if the callback contains an invalid character or
+// there was an issue marshalling the obj.
+func (r *Response) SetJsonp(callback string, obj any) error {
+	for _, c := range callback {
+		valid := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') ||
+			strings.ContainsRune("_$.[]", c)
+		if !valid {
+			return fmt.Errorf("JSONP callback contains an invalid character: %q", c)
+		}
+	}
+
+	err := r.SetJson(obj)
+	if err != nil || callback == "" {
+		return err
+	}
+
+	// U+2028 and U+2029 are valid in JSON strings but end lines in older JavaScript
+	payload := strings.NewReplacer("\u2028", "\\u2028", "\u2029", "\\u2029").Replace(r.body)
+
+	body := fmt.Sprintf("/**/ typeof %s === 'function' && %s(%s);", callback, callback, payload)
+	r.body = body
+	r.headers["Content-Length"] = strconv.Itoa(len(body))
+	r.headers["Content-Type"] = "text/javascript"
+	r.headers["X-Content-Type-Options"] = "nosniff"
+	return nil
+}
+
 // Sets the Response's body to the content in the file provided
 // by the path parameter. The path can be either absolute or relative
 // to the current working directory. The Content-Length header will be set
 // appropriately. The Content-Type header will be determined by the file's
-// extension using the [mime.TypeByExtension] function.
+// extension using the [mime.TypeByExtension] function. The Last-Modified
+// header will be set to the file's modification time, and range requests
+// will be enabled (see [Response.AcceptRanges]).
 // Returns an error if the file could not be read, or if a Content-Type
 // was unable to be determined.
 func (r *Response) SetFile(path string) error {
@@ -149,50 +358,242 @@ func (r *Response) SetFile(path string) error {
 		return err
 	}
 
-	extension := filepath.Ext(path)
-	if extension == "" {
-		return fmt.Errorf("unable to determine a Content-Type because the file does not have an extension")
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return err
 	}
 
-	contentType := mime.TypeByExtension(extension)
-	if contentType == "" {
-		return fmt.Errorf("unable to determine a Content-Type based on the file's extension")
+	contentType, err := contentTypeByExtension(path)
+	if err != nil {
+		return err
 	}
 
 	body := string(fileContents)
 	r.body = body
 	r.headers["Content-Length"] = strconv.Itoa(len(body))
 	r.headers["Content-Type"] = contentType
+	r.SetLastModified(fileInfo.ModTime())
+	r.AcceptRanges()
 	return nil
 }
 
+// determines a Content-Type from the extension of the file at path
+// using the [mime.TypeByExtension] function.
+func contentTypeByExtension(path string) (string, error) {
+	extension := filepath.Ext(path)
+	if extension == "" {
+		return "", fmt.Errorf("unable to determine a Content-Type because the file does not have an extension")
+	}
+
+	contentType := mime.TypeByExtension(extension)
+	if contentType == "" {
+		return "", fmt.Errorf("unable to determine a Content-Type based on the file's extension")
+	}
+
+	return contentType, nil
+}
+
 // Sets the Response's body to the content in the file provided
 // by the path parameter. The path can be either absolute or relative
 // to the current working directory. Sets the Content-Type header to the value
 // provided by the contentType parameter. The Content-Length header will be set
-// appropriately. Returns an error if the file could not be read.
+// appropriately, the Last-Modified header will be set to the file's
+// modification time, and range requests will be enabled (see [Response.AcceptRanges]).
+// Returns an error if the file could not be read.
 func (r *Response) SetFileWithContentType(path string, contentType string) error {
 	fileContents, err := os.ReadFile(path)
 	if err != nil {
 		return err
 	}
 
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
 	body := string(fileContents)
 	r.body = body
 	r.headers["Content-Length"] = strconv.Itoa(len(body))
 	r.headers["Content-Type"] = contentType
+	r.SetLastModified(fileInfo.ModTime())
+	r.AcceptRanges()
+	return nil
+}
+
+// Redirects the client to location, which can be an absolute URL or a path,
+// using the provided status. The status must be one of [StatusMovedPermanently],
+// [StatusFound], [StatusSeeOther], [StatusTemporaryRedirect], or [StatusPermanentRedirect].
+// Clients may change the method of the request to GET when following a 301 or
+// 302, always do so for a 303, and never do so for a 307 or 308.
+// The Location header is set to location, with any characters that are not
+// allowed in a URL percent-encoded, and the body is set to a short HTML note
+// linking to location.
+// An error is returned if the status is not a redirect or location is not a valid URL.
+func (r *Response) Redirect(location string, status uint) error {
+	switch status {
+	case StatusMovedPermanently, StatusFound, StatusSeeOther, StatusTemporaryRedirect, StatusPermanentRedirect:
+	default:
+		return fmt.Errorf("%d is not a redirect status", status)
+	}
+
+	parsed, err := url.Parse(location)
+	if err != nil {
+		return fmt.Errorf("invalid redirect location: %v", err)
+	}
+
+	location = parsed.String()
+	r.SetStatus(status)
+	r.headers["Location"] = location
+	r.SetHtml(fmt.Sprintf(`<p>Redirecting to <a href="%s">%s</a></p>`,
+		html.EscapeString(location), html.EscapeString(location)))
+	return nil
+}
+
+// Sets the Content-Disposition header so browsers download the response as a
+// file named filename, rather than displaying it. Any directories in filename
+// are removed. Names that are not plain ASCII are sent using the filename*
+// parameter described in [RFC 6266], with an ASCII fallback for older clients.
+// If filename has an extension with a known Content-Type, the Content-Type
+// header is also set. If filename is empty, no name is suggested.
+//
+// [RFC 6266]: https://www.rfc-editor.org/rfc/rfc6266.html
+func (r *Response) Attachment(filename string) {
+	if filename == "" {
+		r.headers["Content-Disposition"] = "attachment"
+		return
+	}
+
+	filename = filepath.Base(filename)
+	if contentType, err := contentTypeByExtension(filename); err == nil {
+		r.headers["Content-Type"] = contentType
+	}
+
+	r.headers["Content-Disposition"] = contentDisposition(filename)
+}
+
+// Sets the Response's body to the content in the file provided by the path
+// parameter as [Response.SetFile] does, and sets the Content-Disposition header
+// so browsers download it as a file named filename (see [Response.Attachment]).
+// Unlike Attachment, the Content-Type header is always set to
+// "application/octet-stream", regardless of the file's extension.
+// If filename is empty, the name of the file at path is used.
+// Returns an error if the file could not be read.
+func (r *Response) Download(path string, filename string) error {
+	err := r.SetFileWithContentType(path, "application/octet-stream")
+	if err != nil {
+		return err
+	}
+
+	if filename == "" {
+		filename = path
+	}
+	r.headers["Content-Disposition"] = contentDisposition(filepath.Base(filename))
+	return nil
+}
+
+// builds an attachment Content-Disposition header, adding an RFC 5987 encoded
+// filename* parameter when the name cannot be sent as a plain quoted string
+func contentDisposition(filename string) string {
+	var fallback strings.Builder
+	for _, c := range filename {
+		if c < 0x20 || c > 0x7e {
+			fallback.WriteRune('_')
+		} else {
+			fallback.WriteRune(c)
+		}
+	}
+
+	disposition := "attachment; filename=" + quoteString(fallback.String())
+	if fallback.String() == filename {
+		return disposition
+	}
+
+	var encoded strings.Builder
+	for _, b := range []byte(filename) {
+		isAttrChar := (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') ||
+			strings.IndexByte("!#$&+-.^_`|~", b) >= 0
+		if isAttrChar {
+			encoded.WriteByte(b)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+
+	return disposition + "; filename*=UTF-8''" + encoded.String()
+}
+
+// Sends the status line and headers to the client immediately and returns
+// a writer that sends data directly to the client as the response's body.
+// This is useful for bodies that are too large to hold in memory or that are
+// produced over time. The Content-Length header is removed, since the end of
+// the body is signalled by closing the connection.
+// Once Stream has been called, changes to the status, headers, or body
+// have no effect. Calling Stream again returns the same writer.
+// An error is returned if the Response is not attached to a client connection.
+func (r *Response) Stream() (io.Writer, error) {
+	if r.stream != nil {
+		return r.stream, nil
+	}
+
+	if r.startStream == nil {
+		return nil, fmt.Errorf("response is not attached to a client connection")
+	}
+
+	delete(r.headers, "Content-Length")
+	r.body = ""
+
+	stream, err := r.startStream(r)
+	if err != nil {
+		return nil, err
+	}
+
+	r.stream = stream
+	return stream, nil
+}
+
+// writes any data buffered by the stream, such as the end of a compressed body
+func (r *Response) closeStream() error {
+	r.events.finish()
+	if closer, ok := r.stream.(flushingEncoder); ok {
+		return closer.Close()
+	}
 	return nil
 }
 
 // Sets the Response's Status-Code to the value provided in the
-// status parameter. A Reason-Phrase will also be set based on the
-// status code. See [RFC 1945] Section 9 for a complete
-// list of Status-Codes and Reason-Phrases. If a Status-Code
-// is provided that does not appear in this list, the provided code will
-// be set, however the Reason-Phrase will be 'unknown'.
+// status parameter, such as [StatusNotFound]. A Reason-Phrase will also be
+// set based on the status code, using the [HTTP Status Code Registry].
+// If a Status-Code is provided that is not registered, the provided code
+// will be set with an empty Reason-Phrase. Codes that are not between 100
+// and 599 are never sent: the server logs them and sends a 500 Internal
+// Server Error response instead. Use [Response.SetStatusWithReason] to have
+// the status validated when it is set.
 //
-// [RFC 1945]: https://www.rfc-editor.org/rfc/rfc1945.html#section-9
+// [HTTP Status Code Registry]: https://www.iana.org/assignments/http-status-codes
 func (r *Response) SetStatus(status uint) {
 	r.statusCode = status
 	r.reasonPhrase = getReasonPhrase(status)
 }
+
+// Sets the Response's Status-Code to the value provided in the status
+// parameter and its Reason-Phrase to reason, rather than the registered
+// Reason-Phrase for the code.
+// An error is returned, and the response is left unchanged, if the status
+// is not between 100 and 599 or reason contains a control character, such
+// as a line break.
+func (r *Response) SetStatusWithReason(status uint, reason string) error {
+	if !validStatusCode(status) {
+		return fmt.Errorf("invalid status code %d: it must be between %d and %d",
+			status, minStatusCode, maxStatusCode)
+	}
+
+	for _, c := range reason {
+		if (c < 0x20 && c != '\t') || c == 0x7f {
+			return fmt.Errorf("reason phrase contains an invalid character: %q", c)
+		}
+	}
+
+	r.statusCode = status
+	r.reasonPhrase = reason
+	return nil
+}
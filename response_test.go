@@ -1,7 +1,12 @@
 package simplehttp
 
 import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -80,3 +85,268 @@ func TestResponse_SetJson(t *testing.T) {
 			res.headers["Content-Type"])
 	}
 }
+
+func TestResponse_Stream(t *testing.T) {
+	var client strings.Builder
+	res := newResponse()
+	res.SetHtml("<h1>discarded</h1>")
+	res.startStream = func(res *Response) (io.Writer, error) {
+		client.WriteString(res.head())
+		return &client, nil
+	}
+
+	stream, err := res.Stream()
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+	io.WriteString(stream, "streamed")
+
+	if !strings.HasSuffix(client.String(), doubleLineEnd+"streamed") {
+		t.Fatalf("incorrect streamed response. Actual '%s'", client.String())
+	}
+
+	if _, exists := res.headers["Content-Length"]; exists {
+		t.Fatalf("a streamed response should not have a Content-Length")
+	}
+}
+
+func TestResponse_Stream_ErrorIfNotAttached(t *testing.T) {
+	res := newResponse()
+	_, err := res.Stream()
+
+	if err == nil {
+		t.Fatalf("expected an error, but it was nil")
+	}
+}
+
+func TestResponse_SetText(t *testing.T) {
+	res := newResponse()
+	res.SetText("Hello World!")
+
+	if res.body != "Hello World!" || res.headers["Content-Length"] != "12" {
+		t.Fatalf("incorrect body. Expected 'Hello World!' | Actual '%s'", res.body)
+	}
+
+	if res.headers["Content-Type"] != "text/plain" {
+		t.Fatalf("incorrect Content-Type. Expected 'text/plain' | Actual '%s'", res.headers["Content-Type"])
+	}
+}
+
+func TestResponse_SetXML(t *testing.T) {
+	type user struct {
+		XMLName struct{} `xml:"user"`
+		Name    string   `xml:"name"`
+	}
+
+	res := newResponse()
+	err := res.SetXML(user{Name: "<alice>"})
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<user><name>&lt;alice&gt;</name></user>`
+	if res.body != expected {
+		t.Fatalf("incorrect body. Expected '%s' | Actual '%s'", expected, res.body)
+	}
+
+	if res.headers["Content-Type"] != "application/xml" || res.headers["Content-Length"] != strconv.Itoa(len(expected)) {
+		t.Fatalf("incorrect headers. Actual %v", res.headers)
+	}
+
+	err = res.SetXML(make(chan int))
+	if err == nil {
+		t.Fatalf("expected an error, but it was nil")
+	}
+}
+
+func TestResponse_SetJsonp(t *testing.T) {
+	res := newResponse()
+	err := res.SetJsonp("app.callbacks[0]", "{\"text\":\"line\u2028break\"}")
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+
+	expected := `/**/ typeof app.callbacks[0] === 'function' && app.callbacks[0]({"text":"line\u2028break"});`
+	if res.body != expected {
+		t.Fatalf("incorrect body. Expected '%s' | Actual '%s'", expected, res.body)
+	}
+
+	if res.headers["Content-Type"] != "text/javascript" || res.headers["X-Content-Type-Options"] != "nosniff" {
+		t.Fatalf("incorrect headers. Actual %v", res.headers)
+	}
+
+	res = newResponse()
+	err = res.SetJsonp("alert(1);x", map[string]int{"a": 1})
+	if err == nil {
+		t.Fatalf("expected an error for an invalid callback, but it was nil")
+	}
+
+	if _, exists := res.headers["Content-Type"]; exists || res.body != "" {
+		t.Fatalf("expected the response to be unchanged. Actual '%s' %v", res.body, res.headers)
+	}
+
+	res = newResponse()
+	res.SetJsonp("", map[string]int{"a": 1})
+	if res.body != `{"a":1}` || res.headers["Content-Type"] != "application/json" {
+		t.Fatalf("expected plain JSON without a callback. Actual '%s'", res.body)
+	}
+}
+
+func TestResponse_Redirect(t *testing.T) {
+	for _, status := range []uint{301, 302, 303, 307, 308} {
+		res := newResponse()
+		err := res.Redirect("/new path?q=1", status)
+		if err != nil {
+			t.Fatalf("did not expect an error but received: %v", err)
+		}
+
+		if res.statusCode != status || res.headers["Location"] != "/new%20path?q=1" {
+			t.Fatalf("incorrect redirect. Actual %d %v", res.statusCode, res.headers)
+		}
+
+		if !strings.Contains(res.body, `<a href="/new%20path?q=1">`) {
+			t.Fatalf("incorrect body. Actual '%s'", res.body)
+		}
+	}
+
+	res := newResponse()
+	if res.Redirect("/", 200) == nil {
+		t.Fatalf("expected an error for a status that is not a redirect")
+	}
+
+	if res.Redirect("/a\r\nSet-Cookie: x", 302) == nil {
+		t.Fatalf("expected an error for a location containing a line break")
+	}
+}
+
+func TestResponse_Attachment(t *testing.T) {
+	tests := []struct {
+		filename    string
+		disposition string
+		contentType string
+	}{
+		{"", "attachment", ""},
+		{"reports/summary.pdf", `attachment; filename="summary.pdf"`, "application/pdf"},
+		{`say "hi".txt`, `attachment; filename="say \"hi\".txt"`, "text/plain; charset=utf-8"},
+		{"résumé.json", `attachment; filename="r_sum_.json"; filename*=UTF-8''r%C3%A9sum%C3%A9.json`, "application/json"},
+	}
+
+	for _, test := range tests {
+		res := newResponse()
+		res.Attachment(test.filename)
+
+		if res.headers["Content-Disposition"] != test.disposition {
+			t.Fatalf("incorrect Content-Disposition. Expected '%s' | Actual '%s'",
+				test.disposition, res.headers["Content-Disposition"])
+		}
+
+		if res.headers["Content-Type"] != test.contentType {
+			t.Fatalf("incorrect Content-Type. Expected '%s' | Actual '%s'",
+				test.contentType, res.headers["Content-Type"])
+		}
+	}
+}
+
+func TestResponse_Download(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.bin")
+	os.WriteFile(path, []byte("binary"), 0644)
+
+	res := newResponse()
+	err := res.Download(path, "")
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+
+	if res.body != "binary" || res.headers["Content-Disposition"] != `attachment; filename="data.bin"` {
+		t.Fatalf("incorrect download. Actual '%s' %v", res.body, res.headers)
+	}
+
+	if res.headers["Content-Type"] != "application/octet-stream" {
+		t.Fatalf("incorrect Content-Type. Expected 'application/octet-stream' | Actual '%s'", res.headers["Content-Type"])
+	}
+
+	res = newResponse()
+	res.Download(path, "report.html")
+	if res.headers["Content-Type"] != "application/octet-stream" ||
+		res.headers["Content-Disposition"] != `attachment; filename="report.html"` {
+		t.Fatalf("incorrect headers. Actual %v", res.headers)
+	}
+}
+
+func TestResponse_SetStatus(t *testing.T) {
+	res := newResponse()
+	res.SetStatus(StatusUnprocessableContent)
+	if res.statusCode != 422 || res.reasonPhrase != "Unprocessable Content" {
+		t.Fatalf("incorrect status. Expected '422 Unprocessable Content' | Actual '%d %s'",
+			res.statusCode, res.reasonPhrase)
+	}
+
+	res.SetStatus(299)
+	if res.statusCode != 299 || res.reasonPhrase != "" {
+		t.Fatalf("incorrect status. Expected '299 ' | Actual '%d %s'", res.statusCode, res.reasonPhrase)
+	}
+}
+
+func TestResponse_SetStatusWithReason(t *testing.T) {
+	res := newResponse()
+	err := res.SetStatusWithReason(StatusOK, "All Good")
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+
+	if !strings.HasPrefix(res.String(), "HTTP/1.0 200 All Good"+lineEnd) {
+		t.Fatalf("incorrect status line. Actual '%s'", res.String())
+	}
+
+	if res.SetStatusWithReason(StatusOK, "Bad\r\nSet-Cookie: x") == nil {
+		t.Fatalf("expected an error for a reason phrase containing a line break")
+	}
+
+	for _, status := range []uint{0, 99, 600, 1000} {
+		if res.SetStatusWithReason(status, "Invalid") == nil {
+			t.Fatalf("expected an error for status %d, but it was nil", status)
+		}
+	}
+
+	if res.statusCode != 200 || res.reasonPhrase != "All Good" {
+		t.Fatalf("expected an invalid status to leave the response unchanged. Actual '%d %s'",
+			res.statusCode, res.reasonPhrase)
+	}
+}
+
+func TestParseResponseHead(t *testing.T) {
+	res, err := parseResponseHead("HTTP/1.1 418 I'm a teapot" + lineEnd + "Content-Type: text/plain" + lineEnd + "X-Test:  value " + doubleLineEnd)
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+
+	if res.httpVersion != "HTTP/1.1" || res.statusCode != 418 || res.ReasonPhrase() != "I'm a teapot" {
+		t.Fatalf("incorrect status-line. Expected 'HTTP/1.1 418 I'm a teapot' | Actual '%s %d %s'", res.httpVersion, res.statusCode, res.ReasonPhrase())
+	}
+
+	if res.Headers()["Content-Type"] != "text/plain" || res.Headers()["X-Test"] != "value" {
+		t.Fatalf("incorrect headers. Actual '%v'", res.Headers())
+	}
+
+	invalid := []string{"", "HTTP/1.1", "HTTP/1.1 abc OK", "HTTP/1.1 99 Low", "SPDY/3 200 OK"}
+	for _, statusLine := range invalid {
+		if _, err := parseResponseHead(statusLine + doubleLineEnd); err == nil {
+			t.Fatalf("expected an error for status-line '%s'", statusLine)
+		}
+	}
+}
+
+func TestResponse_SetCookie(t *testing.T) {
+	res := newResponse()
+	res.SetCookie(&http.Cookie{Name: "session", Value: "abc", HttpOnly: true})
+	res.SetCookie(&http.Cookie{Name: "theme", Value: "dark"})
+
+	expected := "Set-Cookie: session=abc; HttpOnly" + lineEnd + "Set-Cookie: theme=dark" + doubleLineEnd
+	if !strings.HasSuffix(res.String(), expected) {
+		t.Fatalf("incorrect cookies. Expected '%s' | Actual '%s'", expected, res.String())
+	}
+
+	if res.SetCookie(&http.Cookie{Name: "bad name", Value: "x"}) == nil {
+		t.Fatalf("expected an error for an invalid cookie name")
+	}
+}
@@ -0,0 +1,157 @@
+package simplehttp
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// A RotatingFile is an [io.WriteCloser] that appends to a log file and
+// rotates it once it grows past a maximum size, or once a day. When the file
+// is rotated, it is renamed with the date (and time, for size based rotation)
+// appended to its name, and a new file is started at the original path.
+// For example, "access.log" is rotated to "access.log.2024-05-12".
+// A RotatingFile should only be created using the [NewRotatingFile] method.
+// It is safe to use concurrently.
+type RotatingFile struct {
+	// MaxBytes is the size in bytes the file can reach before it is rotated.
+	// If 0, the file is not rotated by size.
+	MaxBytes int64
+	// Daily rotates the file when the first write of a new day occurs.
+	Daily  bool
+	path   string
+	file   *os.File
+	size   int64
+	opened time.Time
+	now    func() time.Time
+	rename func(oldPath string, newPath string) error
+	mutex  sync.Mutex
+}
+
+// Opens the file at path for appending, creating it if it does not exist,
+// and returns a [RotatingFile] that rotates it once it exceeds maxBytes
+// (if maxBytes is not 0) or once a day (if daily is true).
+func NewRotatingFile(path string, maxBytes int64, daily bool) (*RotatingFile, error) {
+	rf := &RotatingFile{
+		MaxBytes: maxBytes,
+		Daily:    daily,
+		path:     path,
+		now:      time.Now,
+		rename:   os.Rename,
+	}
+
+	err := rf.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return rf, nil
+}
+
+// Write appends p to the file, rotating the file first if needed.
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+
+	if rf.file == nil {
+		return 0, fmt.Errorf("rotating file '%s' is closed", rf.path)
+	}
+
+	now := rf.now()
+	dayChanged := rf.Daily && !sameDay(now, rf.opened)
+	tooLarge := rf.MaxBytes > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.MaxBytes
+
+	if dayChanged || tooLarge {
+		err := rf.rotate(dayChanged)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// Close closes the current file. Any writes after Close return an error.
+func (rf *RotatingFile) Close() error {
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+
+	if rf.file == nil {
+		return nil
+	}
+
+	err := rf.file.Close()
+	rf.file = nil
+	return err
+}
+
+func (rf *RotatingFile) open() error {
+	file, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	rf.file = file
+	rf.size = info.Size()
+	rf.opened = rf.now()
+
+	// a file that already existed belongs to the day it was last written
+	if info.Size() > 0 {
+		rf.opened = info.ModTime()
+	}
+	return nil
+}
+
+// rotate renames the current file and opens a new one. Files rotated because
+// the day changed are named by the day they contain, otherwise the time of
+// rotation is included so multiple rotations on the same day do not collide.
+// If the file cannot be renamed, it is reopened so later writes still succeed,
+// and rotation is tried again by the next write.
+func (rf *RotatingFile) rotate(dayChanged bool) error {
+	err := rf.file.Close()
+	if err != nil {
+		return err
+	}
+	rf.file = nil
+
+	suffix := rf.opened.Format("2006-01-02")
+	if !dayChanged {
+		suffix = rf.now().Format("2006-01-02T15-04-05.000")
+	}
+
+	rotated := rf.path + "." + suffix
+	for i := 1; fileExists(rotated); i++ {
+		rotated = fmt.Sprintf("%s.%s.%d", rf.path, suffix, i)
+	}
+
+	err = rf.rename(rf.path, rotated)
+	if err != nil {
+		openErr := rf.open()
+		if openErr != nil {
+			return openErr
+		}
+		return err
+	}
+
+	return rf.open()
+}
+
+func sameDay(a time.Time, b time.Time) bool {
+	aYear, aMonth, aDay := a.Date()
+	bYear, bMonth, bDay := b.In(a.Location()).Date()
+	return aYear == bYear && aMonth == bMonth && aDay == bDay
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,97 @@
+package simplehttp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile_RotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	rf, err := NewRotatingFile(path, 10, false)
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+	defer rf.Close()
+
+	rf.Write([]byte("123456\n"))
+	rf.Write([]byte("abcdef\n"))
+
+	rotated, _ := filepath.Glob(path + ".*")
+	if len(rotated) != 1 {
+		t.Fatalf("expected 1 rotated file | Actual %v", rotated)
+	}
+
+	old, _ := os.ReadFile(rotated[0])
+	current, _ := os.ReadFile(path)
+	if string(old) != "123456\n" || string(current) != "abcdef\n" {
+		t.Fatalf("incorrect file contents. Rotated '%s' | Current '%s'", old, current)
+	}
+}
+
+func TestRotatingFile_RenameFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	rf, err := NewRotatingFile(path, 10, false)
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+	defer rf.Close()
+
+	rf.rename = func(string, string) error { return errors.New("rename failed") }
+	rf.Write([]byte("123456\n"))
+	_, err = rf.Write([]byte("abcdef\n"))
+	if err == nil {
+		t.Fatalf("expected the rename error to be returned")
+	}
+
+	rf.rename = os.Rename
+	_, err = rf.Write([]byte("ghijkl\n"))
+	if err != nil {
+		t.Fatalf("expected writes to continue after a failed rotation. Actual %v", err)
+	}
+
+	rotated, _ := filepath.Glob(path + ".*")
+	current, _ := os.ReadFile(path)
+	if len(rotated) != 1 || string(current) != "ghijkl\n" {
+		t.Fatalf("expected the file to be rotated by a later write. Rotated %v | Current '%s'", rotated, current)
+	}
+}
+
+func TestRotatingFile_RotatesDaily(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	rf, err := NewRotatingFile(path, 0, true)
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+	defer rf.Close()
+
+	day := time.Date(2024, 5, 12, 23, 59, 0, 0, time.Local)
+	rf.opened = day
+	rf.now = func() time.Time { return day }
+	rf.Write([]byte("first day\n"))
+
+	day = day.Add(2 * time.Minute)
+	rf.Write([]byte("second day\n"))
+
+	old, err := os.ReadFile(path + ".2024-05-12")
+	if err != nil {
+		t.Fatalf("expected a file rotated by date but received: %v", err)
+	}
+
+	current, _ := os.ReadFile(path)
+	if string(old) != "first day\n" || string(current) != "second day\n" {
+		t.Fatalf("incorrect file contents. Rotated '%s' | Current '%s'", old, current)
+	}
+}
+
+func TestRotatingFile_WriteAfterClose(t *testing.T) {
+	rf, _ := NewRotatingFile(filepath.Join(t.TempDir(), "access.log"), 0, false)
+	rf.Close()
+
+	_, err := rf.Write([]byte("closed"))
+	if err == nil {
+		t.Fatalf("expected an error, but it was nil")
+	}
+}
@@ -0,0 +1,77 @@
+package simplehttp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSecurityHeaders_Apply(t *testing.T) {
+	sh := NewSecurityHeaders()
+	sh.HSTSIncludeSubdomains = true
+	sh.ContentSecurityPolicy = "script-src 'nonce-{nonce}'"
+	sh.FrameOptions = ""
+
+	req := sh.addNonce(Request{scheme: "https"})
+	if req.CSPNonce() == "" {
+		t.Fatalf("expected the request to have a nonce")
+	}
+
+	res := newResponse()
+	res.headers["Referrer-Policy"] = "no-referrer"
+	sh.apply(req, &res)
+
+	expected := map[string]string{
+		"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
+		"Content-Security-Policy":   "script-src 'nonce-" + req.CSPNonce() + "'",
+		"X-Content-Type-Options":    "nosniff",
+		"Referrer-Policy":           "no-referrer",
+		"Permissions-Policy":        "camera=(), microphone=(), geolocation=()",
+	}
+
+	for key, value := range expected {
+		if res.headers[key] != value {
+			t.Fatalf("incorrect %s header. Expected '%s' | Actual '%s'", key, value, res.headers[key])
+		}
+	}
+
+	if _, exists := res.headers["X-Frame-Options"]; exists {
+		t.Fatalf("expected an empty header to not be sent")
+	}
+}
+
+func TestSecurityHeaders_HSTSOnlyOverHTTPS(t *testing.T) {
+	res := newResponse()
+	NewSecurityHeaders().apply(Request{}, &res)
+
+	if _, exists := res.headers["Strict-Transport-Security"]; exists {
+		t.Fatalf("expected HSTS to not be sent over HTTP")
+	}
+
+	if NewSecurityHeaders().addNonce(Request{}).CSPNonce() != "" {
+		t.Fatalf("expected no nonce when the policy does not use one")
+	}
+}
+
+func TestServer_SecurityHeaders(t *testing.T) {
+	server := NewServer(0)
+	server.SecurityHeaders = NewSecurityHeaders()
+	server.ServerHeader = "my-app"
+
+	response := sendRawRequest(&server, "GET /missing HTTP/1.0"+doubleLineEnd)
+
+	if !strings.HasPrefix(response, "HTTP/1.0 404") {
+		t.Fatalf("incorrect status line. Actual '%s'", response)
+	}
+
+	for _, header := range []string{"Server: my-app", "X-Content-Type-Options: nosniff", "X-Frame-Options: DENY"} {
+		if !strings.Contains(response, header+lineEnd) {
+			t.Fatalf("expected the header '%s'. Actual '%s'", header, response)
+		}
+	}
+
+	server.ServerHeader = ""
+	response = sendRawRequest(&server, "GET /missing HTTP/1.0"+doubleLineEnd)
+	if strings.Contains(response, "Server:") {
+		t.Fatalf("expected the Server header to be removed. Actual '%s'", response)
+	}
+}
@@ -10,10 +10,12 @@
 package simplehttp
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
 	"io"
 	"net"
+	"net/netip"
 	"time"
 )
 
@@ -31,26 +33,126 @@ type Server struct {
 	// MaxRequestBytes is the maximum number of bytes an incoming request can
 	// be before the server rejects it.
 	MaxRequestBytes uint
+	// MaxDecompressedBytes is the maximum number of bytes a request body
+	// sent with a Content-Encoding, such as gzip, can expand to once it has been
+	// decompressed. This protects against small requests that decompress to
+	// very large bodies. Requests that exceed it receive a 413 response, and
+	// requests with an unsupported Content-Encoding receive a 415 response.
+	MaxDecompressedBytes uint
 	// ReadTimeoutSeconds is the time in seconds that the server waits for a
 	// request before closing the connection.
 	ReadTimeoutSeconds int
 	// Logger is a user-implementation of the [Logger] interface that the
 	// server will send messages about incoming requests and
 	// outgoing responses. If a Logger is not provided, the server will
-	// discard all log messages.
-	Logger      Logger
-	callbackMap callbackMap
+	// discard all log messages. Logger is ignored if a StructuredLogger is provided.
+	Logger Logger
+	// StructuredLogger is a user-implementation of the [StructuredLogger]
+	// interface that the server will send levelled messages with key/value
+	// fields to. See [NewSlogLogger] to use a [log/slog] Logger.
+	StructuredLogger StructuredLogger
+	// DumpMessages determines whether every raw request and response is
+	// logged at [LevelDebug]. Connection events are logged either way.
+	// Defaults to true.
+	DumpMessages bool
+	// RedactedHeaders lists the headers whose values are replaced with
+	// "[REDACTED]" when raw requests and responses are logged. Defaults to
+	// Authorization, Proxy-Authorization, Cookie, and Set-Cookie.
+	RedactedHeaders []string
+	// ETags determines whether the server generates an ETag header for
+	// successful responses that do not already have one. Defaults to [NoETags].
+	// Regardless of this setting, the server evaluates the If-Match,
+	// If-None-Match, If-Modified-Since, and If-Unmodified-Since headers of GET
+	// requests against the response's ETag and Last-Modified headers and
+	// returns a 304 Not Modified or 412 Precondition Failed response without a
+	// body when appropriate. Note the preconditions of GET requests are
+	// evaluated after the callback has run. Requests that change state are
+	// checked before their callback runs using ResourceState.
+	ETags ETagMode
+	// ResourceState returns the current state of the resource targeted by a
+	// request: its ETag, formatted as in an ETag header (ex. "v1" or W/"v1")
+	// or empty if it does not have one, its modification time or the zero
+	// time if it is unknown, and whether it currently exists. If provided, the
+	// If-Match, If-Unmodified-Since, and If-None-Match headers of POST, PUT,
+	// and DELETE requests to registered paths are evaluated against it after
+	// middleware has run, and requests whose preconditions fail receive a 412
+	// Precondition Failed response without their callback being invoked.
+	// If ResourceState is not provided, callbacks can check preconditions
+	// themselves using [Request.CheckPreconditions].
+	ResourceState func(req Request) (etag string, lastModified time.Time, exists bool)
+	// Compressor compresses response bodies when the client accepts a
+	// supported content-coding. If a Compressor is not provided, responses
+	// are not compressed. See [NewCompressor].
+	Compressor *Compressor
+	// Tracer creates a span for every request, with child spans for reading,
+	// routing, invoking the callback, and writing the response. The span is
+	// named by the request's method and route, such as "GET /users", or
+	// "unmatched" if no callback is registered for its path. The span
+	// continues any trace started by the client's traceparent header, and its
+	// [SpanContext] is available from the request's context using
+	// [SpanContextFromContext]. If a Tracer is not provided, requests are not traced.
+	Tracer Tracer
+	// TrustRequestID determines whether a request's ID is taken from its
+	// X-Request-ID header, when the client sends a valid one. Otherwise, an ID
+	// is always generated by the server. See [Request.ID]. Only enable
+	// TrustRequestID if every request passes through a proxy that sets the
+	// header, since clients can otherwise choose the IDs written to the
+	// server's logs. Defaults to false.
+	TrustRequestID bool
+	// TrustedProxies lists the IP addresses and CIDR ranges, such as
+	// "10.0.0.0/8", of proxies that the server is deployed behind. For requests
+	// received from a trusted proxy, the client's address, scheme, and host
+	// are taken from the Forwarded or X-Forwarded-* headers. See
+	// [Request.RemoteAddr], [Request.Scheme], and [Request.Host]. Proxies must
+	// append to X-Forwarded-Proto and X-Forwarded-Host as they do to
+	// X-Forwarded-For, since their values are matched to its addresses by
+	// position. These headers are ignored for requests from any other address. TrustedProxies is
+	// parsed by [Server.Start] and [Server.Handler], so changes made after
+	// that have no effect.
+	TrustedProxies []string
+	// ProxyProtocol determines whether every connection starts with a
+	// version 1 or 2 PROXY protocol header, which proxies such as HAProxy use
+	// to send the address of the client they are forwarding the connection
+	// for. Connections that do not start with a header are closed. Only enable
+	// ProxyProtocol if the server can only be reached through such a proxy.
+	ProxyProtocol bool
+	// ServerHeader is the value of the Server header sent with every response.
+	// If empty, the Server header is not sent. Defaults to "simplehttp".
+	ServerHeader string
+	// SecurityHeaders adds headers that enable browser security features,
+	// such as Content-Security-Policy, to every response. If SecurityHeaders
+	// is not provided, the headers are not added. See [NewSecurityHeaders].
+	SecurityHeaders *SecurityHeaders
+	// WebSocketCompression determines whether messages sent over WebSocket
+	// connections are compressed using the permessage-deflate extension, when
+	// the client supports it. See [Server.WebSocket].
+	WebSocketCompression bool
+	// WebSocketCheckOrigin returns whether a WebSocket handshake is allowed
+	// based on its Origin header, which prevents other sites from opening
+	// connections using the cookies of their visitors. If it is not provided,
+	// handshakes are allowed only when their Origin has the same host as the
+	// request, or they have no Origin header. See [Server.WebSocket].
+	WebSocketCheckOrigin func(Request) bool
+	callbackMap          callbackMap
+	middleware           []MiddlewareFunc
+	hooks                []Hooks
+	views                *ViewEngine
+	trustedProxies       []netip.Prefix
 }
 
 // Creates and initializes a new [Server] object and
 // assigns port to [Server.Port]
 func NewServer(port uint16) Server {
 	return Server{
-		Port:               port,
-		callbackMap:        newCallbackMap(),
-		MaxRequestBytes:    defaultMaxRequestBytes,
-		ReadTimeoutSeconds: defaultReadTimeoutSeconds,
-		Logger:             nilLogger{},
+		Port:                 port,
+		callbackMap:          newCallbackMap(),
+		MaxRequestBytes:      defaultMaxRequestBytes,
+		MaxDecompressedBytes: defaultMaxDecompressedBytes,
+		ReadTimeoutSeconds:   defaultReadTimeoutSeconds,
+		Logger:               nilLogger{},
+		DumpMessages:         true,
+		RedactedHeaders:      defaultRedactedHeaders,
+		ServerHeader:         "simplehttp",
 	}
 }
 
@@ -79,24 +181,47 @@ func (s *Server) Put(path string, callback CallbackFunc) error {
 // made to the provided path. The callback is a function that takes in a [Request] and
 // [*Response] and returns an error. See [CallbackFunc] for details on this function
 func (s *Server) Delete(path string, callback CallbackFunc) error {
-	return s.callbackMap.registerCallback(delete, path, callback)
+	return s.callbackMap.registerCallback(del, path, callback)
+}
+
+// Registers a callback that will be invoked whenever an OPTIONS request is
+// made to the provided path. The callback is a function that takes in a [Request] and
+// [*Response] and returns an error. See [CallbackFunc] for details on this function
+func (s *Server) Options(path string, callback CallbackFunc) error {
+	return s.callbackMap.registerCallback(options, path, callback)
+}
+
+// Registers middleware that wraps every request handled by the Server,
+// including requests for paths that do not have a registered callback.
+// Middleware is invoked in the order it was registered, so the first
+// middleware registered is the first to see each request. See [MiddlewareFunc]
+// for details on this function.
+func (s *Server) Use(middleware MiddlewareFunc) {
+	s.middleware = append(s.middleware, middleware)
 }
 
 // Starts the Server and begins listening for requests.
 // Multiple requests can be handled in parallel with no hard limit.
-// Returns an error if the Server was unable to open a TCP listener.
+// Returns an error if [Server.TrustedProxies] is invalid, or if the Server was
+// unable to open a TCP listener.
 func (s *Server) Start() error {
+	err := s.loadTrustedProxies()
+	if err != nil {
+		return err
+	}
+
 	listener, err := net.Listen("tcp4", fmt.Sprintf(":%d", s.Port))
 	if err != nil {
 		return fmt.Errorf("failed to open tcp listener: %v", err)
 	}
 
-	s.Logger.LogMessage(fmt.Sprintf("Listening on port %d", s.Port))
+	s.log(LevelInfo, "Listening for connections", LogField{"port", s.Port})
 
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
-			s.Logger.LogMessage(fmt.Sprintf("Failed to accept incoming connection: %v", err))
+			s.log(LevelError, "Failed to accept incoming connection", LogField{"error", err})
+			continue
 		}
 
 		if s.ReadTimeoutSeconds > 0 {
@@ -107,50 +232,203 @@ func (s *Server) Start() error {
 }
 
 func (s *Server) handleConnection(conn net.Conn) {
-	s.Logger.LogMessage(fmt.Sprintf("Connected to remote address %s", conn.RemoteAddr()))
+	remoteAddr := LogField{"remote_addr", conn.RemoteAddr().String()}
+	// the request's ID is not known until it has been read, so messages about
+	// the connection are identified by a separate ID
+	connectionID := LogField{"connection_id", newRequestID()}
+	s.log(LevelInfo, "Connected to remote address", remoteAddr, connectionID)
+	s.connectionOpened(conn.RemoteAddr().String())
+	defer s.connectionClosed(conn.RemoteAddr().String())
 	defer conn.Close()
 
-	request, err := readRequest(conn, s.MaxRequestBytes)
+	start := time.Now()
+	request, clientAddr, err := s.readConnRequest(conn)
 	if err != nil {
-		s.Logger.LogMessage(fmt.Sprintf("Unable to read message from the connection: %v", err))
-		s.Logger.LogMessage(fmt.Sprintf("Disconnecting from remote address %s", conn.RemoteAddr()))
+		s.log(LevelWarn, "Unable to read message from the connection", remoteAddr, connectionID, LogField{"error", err})
+		s.readFailed(conn.RemoteAddr().String(), err)
+		s.log(LevelInfo, "Disconnecting from remote address", remoteAddr, connectionID)
 		return
 	}
 
-	s.Logger.LogMessage(fmt.Sprintf("Request from %s:", conn.RemoteAddr()))
-	s.Logger.LogMessage("<<<<<<<<")
-	s.Logger.LogMessage(request.rawMessage)
-	s.Logger.LogMessage("<<<<<<<<")
+	request.remoteAddr = clientAddr
+	request = s.resolveClient(request)
+	request = s.assignRequestID(request, connectionID.Value.(string))
+	requestID := LogField{"request_id", request.id}
+	s.logDump("Request from remote address", request.rawMessage, "<<<<<<<<", remoteAddr, connectionID, requestID)
+	request = s.SecurityHeaders.addNonce(request)
+	request, trace := s.startTrace(request, start)
+	trace.phase("read", start, time.Now())
+	s.requestStarted(request)
 
 	response := newResponse()
+	response.views = s.views
+	streamed := &countingWriter{w: conn}
+	response.startStream = func(res *Response) (io.Writer, error) {
+		s.finishHeaders(request, res)
+		_, err := conn.Write([]byte(res.head()))
+		return streamed, err
+	}
+	response.clientGone = func() <-chan struct{} {
+		gone := make(chan struct{})
+		go func() {
+			// the request has already been read, so reading
+			// only stops once the client closes the connection
+			conn.SetReadDeadline(time.Time{})
+			io.Copy(io.Discard, conn)
+			close(gone)
+		}()
+		return gone
+	}
 
-	// call end-user's callback
-	method := request.method
-	path := request.Path()
-	err = s.callbackMap.invokeCallback(method, path, request, &response)
-	if err != nil {
-		s.Logger.LogMessage(err.Error())
-		var errorRes Response
+	s.tracedDispatch(request, &response, trace)
 
-		if errors.As(err, &callbackNotRegisteredError{}) {
-			errorRes = new404StatusResponse()
-		} else {
-			errorRes = new500StatusResponse()
+	writeStart := time.Now()
+	if response.upgrade != nil && response.statusCode == StatusSwitchingProtocols {
+		s.finishHeaders(request, &response)
+		s.logDump("Sending response to remote address", response.String(), ">>>>>>>>", remoteAddr, connectionID, requestID)
+		_, err = conn.Write([]byte(response.head()))
+		trace.phase("write", writeStart, time.Now())
+		trace.end(response)
+		s.requestCompleted(request, response, int64(len(response.head())), start)
+
+		if err == nil {
+			s.log(LevelInfo, "Upgraded the connection", remoteAddr, connectionID, requestID, LogField{"protocol", response.headers["Upgrade"]})
+			conn.SetReadDeadline(time.Time{})
+			err = response.upgrade(conn, bufio.NewReader(conn))
+		}
+		if err != nil {
+			s.log(LevelError, "The upgraded connection returned an error", remoteAddr, connectionID, requestID, LogField{"error", err})
 		}
+		s.log(LevelInfo, "Disconnecting from remote address", remoteAddr, connectionID, requestID)
+		return
+	}
 
-		conn.Write([]byte(errorRes.String()))
-		s.Logger.LogMessage(fmt.Sprintf("Disconnecting from remote address %s", conn.RemoteAddr()))
+	if response.stream != nil {
+		err = response.closeStream()
+		if err != nil {
+			s.log(LevelError, "Unable to finish streaming the response", remoteAddr, connectionID, requestID, LogField{"error", err})
+		}
+		trace.phase("write", writeStart, time.Now())
+		trace.end(response)
+		s.log(LevelInfo, "Streamed response to remote address", remoteAddr, connectionID, requestID)
+		s.requestCompleted(request, response, streamed.n, start)
+		s.log(LevelInfo, "Disconnecting from remote address", remoteAddr, connectionID, requestID)
 		return
 	}
 
 	// send a response
-	s.Logger.LogMessage(fmt.Sprintf("Sending request to %s:", conn.RemoteAddr()))
-	s.Logger.LogMessage(">>>>>>>>")
-	s.Logger.LogMessage(response.String())
-	s.Logger.LogMessage(">>>>>>>>")
+	s.finishHeaders(request, &response)
+	s.logDump("Sending response to remote address", response.String(), ">>>>>>>>", remoteAddr, connectionID, requestID)
 	conn.Write([]byte(response.String()))
+	trace.phase("write", writeStart, time.Now())
+	trace.end(response)
+
+	s.requestCompleted(request, response, int64(len(response.body)), start)
+	s.log(LevelInfo, "Disconnecting from remote address", remoteAddr, connectionID, requestID)
+}
+
+// dispatch decompresses the request's body, invokes the end-user's middleware
+// and callback for the request, compresses the response, and applies any
+// conditional and range request headers to it. If the callback could not be
+// found or returned an error, res is replaced with a 404 or 500 response that
+// keeps any headers middleware set using keepHeader, unless the callback
+// already started streaming it.
+func (s *Server) dispatch(request Request, res *Response) {
+	requestID := LogField{"request_id", request.id}
+	request, err := decompressRequest(request, s.MaxDecompressedBytes)
+	if err != nil {
+		s.log(LevelWarn, "Unable to decompress the request body", requestID, LogField{"error", err})
+
+		switch err.(type) {
+		case unsupportedEncodingError:
+			*res = newStatusResponse(415)
+		case decompressedTooLargeError:
+			*res = newStatusResponse(413)
+		default:
+			*res = newStatusResponse(400)
+		}
+		return
+	}
+
+	if s.Compressor != nil {
+		s.Compressor.wrapStream(request, res)
+	}
+
+	var callback CallbackFunc = func(req Request, res *Response) error {
+		if s.callbackMap.route(req.method, req.Path()) != "" && !s.preconditionsPass(req) {
+			setPreconditionFailed(res)
+			return nil
+		}
+		return s.callbackMap.invokeCallback(req.method, req.Path(), req, res)
+	}
+
+	for i := len(s.middleware) - 1; i >= 0; i-- {
+		callback = s.middleware[i](callback)
+	}
+
+	err = callback(request, res)
+	if err == nil {
+		if res.stream != nil {
+			return
+		}
+
+		if !validStatusCode(res.statusCode) {
+			s.log(LevelError, "The callback set an invalid status code", requestID,
+				LogField{"status", res.statusCode})
+			replaceResponse(res, new500StatusResponse())
+			return
+		}
+
+		if s.Compressor != nil {
+			err = s.Compressor.compress(request, res)
+			if err != nil {
+				s.log(LevelError, "Unable to compress the response", requestID, LogField{"error", err})
+			}
+		}
+
+		generateETag(res, s.ETags)
+		evaluatePreconditions(request, res)
+		applyRange(request, res)
+		return
+	}
+
+	if errors.As(err, &callbackNotRegisteredError{}) {
+		s.log(LevelWarn, "No callback is registered for the request", requestID, LogField{"error", err})
+	} else {
+		s.log(LevelError, "The callback returned an error", requestID, LogField{"error", err})
+	}
+
+	if res.stream != nil {
+		return
+	}
+
+	if errors.As(err, &callbackNotRegisteredError{}) {
+		replaceResponse(res, new404StatusResponse())
+	} else {
+		replaceResponse(res, new500StatusResponse())
+	}
+}
+
+// finishHeaders sets the headers the server adds to every response,
+// just before it is sent
+func (s *Server) finishHeaders(req Request, res *Response) {
+	res.headers[RequestIDHeader] = req.id
+
+	// the status of a streamed response is sent before its callback returns,
+	// so dispatch cannot replace the response if the status is invalid
+	if !validStatusCode(res.statusCode) {
+		s.log(LevelError, "The response has an invalid status code",
+			LogField{"request_id", req.id}, LogField{"status", res.statusCode})
+		res.SetStatus(StatusInternalServerError)
+	}
+
+	if s.ServerHeader == "" {
+		delete(res.headers, "Server")
+	} else {
+		res.headers["Server"] = s.ServerHeader
+	}
 
-	s.Logger.LogMessage(fmt.Sprintf("Disconnecting from remote address %s", conn.RemoteAddr()))
+	s.SecurityHeaders.apply(req, res)
 }
 
 func readRequest(conn net.Conn, maxBytes uint) (Request, error) {
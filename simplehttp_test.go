@@ -0,0 +1,160 @@
+package simplehttp
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestServer_Use(t *testing.T) {
+	server := NewServer(0)
+	order := ""
+
+	for _, name := range []string{"first", "second"} {
+		server.Use(func(next CallbackFunc) CallbackFunc {
+			return func(req Request, res *Response) error {
+				order += name + ","
+				return next(req, res)
+			}
+		})
+	}
+
+	server.Get("/", func(_ Request, _ *Response) error {
+		order += "callback"
+		return nil
+	})
+
+	res := newResponse()
+	server.dispatch(Request{method: get, uri: url.URL{Path: "/"}}, &res)
+
+	if order != "first,second,callback" {
+		t.Fatalf("incorrect order. Expected 'first,second,callback' | Actual '%s'", order)
+	}
+}
+
+func TestServer_Use_RespondsWithoutCallback(t *testing.T) {
+	server := NewServer(0)
+	server.Use(func(next CallbackFunc) CallbackFunc {
+		return func(req Request, res *Response) error {
+			res.SetStatus(204)
+			return nil
+		}
+	})
+
+	uri, _ := url.ParseRequestURI("/not-registered")
+	res := newResponse()
+	server.dispatch(Request{method: options, uri: *uri}, &res)
+
+	if res.statusCode != 204 {
+		t.Fatalf("incorrect status. Expected '204' | Actual '%d'", res.statusCode)
+	}
+}
+
+func TestServer_Use_Errors(t *testing.T) {
+	server := NewServer(0)
+	server.Use(func(next CallbackFunc) CallbackFunc {
+		return func(req Request, res *Response) error {
+			if req.Path() == "/forbidden" {
+				return fmt.Errorf("middleware error")
+			}
+			return next(req, res)
+		}
+	})
+
+	res := newResponse()
+	server.dispatch(Request{method: get, uri: url.URL{Path: "/missing"}}, &res)
+	if res.statusCode != 404 {
+		t.Fatalf("incorrect status. Expected '404' | Actual '%d'", res.statusCode)
+	}
+
+	res = newResponse()
+	server.dispatch(Request{method: get, uri: url.URL{Path: "/forbidden"}}, &res)
+	if res.statusCode != 500 {
+		t.Fatalf("incorrect status. Expected '500' | Actual '%d'", res.statusCode)
+	}
+}
+
+func TestServer_InvalidStatusCode(t *testing.T) {
+	server := NewServer(0)
+	server.Get("/invalid", func(_ Request, res *Response) error {
+		res.SetStatus(1000)
+		res.SetText("body")
+		return nil
+	})
+	server.Get("/stream", func(_ Request, res *Response) error {
+		res.SetStatus(42)
+		stream, err := res.Stream()
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(stream, "streamed")
+		return err
+	})
+
+	response := sendRawRequest(&server, "GET /invalid HTTP/1.0"+doubleLineEnd)
+	if !strings.HasPrefix(response, "HTTP/1.0 500 Internal Server Error"+lineEnd) || strings.Contains(response, "HTTP/1.0 1000") {
+		t.Fatalf("expected a 500 response instead of the invalid status. Actual '%s'", response)
+	}
+
+	response = sendRawRequest(&server, "GET /stream HTTP/1.0"+doubleLineEnd)
+	if !strings.HasPrefix(response, "HTTP/1.0 500 Internal Server Error"+lineEnd) {
+		t.Fatalf("expected a 500 status instead of the invalid status. Actual '%s'", response)
+	}
+}
+
+// sends a raw request to the server over an in-memory connection
+// and returns the raw response
+func sendRawRequest(server *Server, raw string) string {
+	client, conn := net.Pipe()
+	go server.handleConnection(conn)
+
+	go client.Write([]byte(raw))
+	response, _ := io.ReadAll(client)
+	client.Close()
+	return string(response)
+}
+
+func TestServer_HandleConnection(t *testing.T) {
+	server := NewServer(0)
+	server.Get("/hello", func(_ Request, res *Response) error {
+		res.SetHtml("<h1>Hello, world!</h1>")
+		return nil
+	})
+
+	response := sendRawRequest(&server, "GET /hello HTTP/1.0"+doubleLineEnd)
+
+	if !strings.HasPrefix(response, "HTTP/1.0 200 OK"+lineEnd) {
+		t.Fatalf("incorrect status line. Actual '%s'", response)
+	}
+
+	if !strings.HasSuffix(response, doubleLineEnd+"<h1>Hello, world!</h1>") {
+		t.Fatalf("incorrect body. Actual '%s'", response)
+	}
+}
+
+func TestServer_AddHooks(t *testing.T) {
+	server := NewServer(0)
+	server.Get("/hello", func(_ Request, res *Response) error {
+		res.SetHtml("hello")
+		return nil
+	})
+
+	completed := make(chan CompletedRequest, 1)
+	server.AddHooks(Hooks{RequestCompleted: func(c CompletedRequest) {
+		completed <- c
+	}})
+
+	sendRawRequest(&server, "GET /hello HTTP/1.0"+doubleLineEnd)
+	c := <-completed
+
+	if c.Request.Path() != "/hello" || c.StatusCode != 200 || c.BytesWritten != 5 {
+		t.Fatalf("incorrect completed request. Actual '%s' %d %d", c.Request.Path(), c.StatusCode, c.BytesWritten)
+	}
+
+	if c.Request.remoteAddr == "" || c.Start.IsZero() {
+		t.Fatalf("expected the remote address and start time to be set")
+	}
+}
@@ -0,0 +1,207 @@
+package simplehttp
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultHeartbeatInterval time.Duration = 15 * time.Second
+
+// An Event is a single Server-Sent Event sent using [EventStream.Send].
+type Event struct {
+	// ID is the event's id. When a client reconnects, it sends the ID of the
+	// last event it received in the Last-Event-ID header, so the server can
+	// resume the stream from that event. See [Request.LastEventID].
+	ID string
+	// Event is the event's type, which determines the listener the client
+	// dispatches it to. If empty, clients treat it as a "message" event.
+	Event string
+	// Data is the event's data. Data containing line breaks is sent as
+	// several data fields, which the client joins back together.
+	Data string
+	// Retry is how long the client should wait before reconnecting if the
+	// connection is lost. If 0, the client's current delay is kept.
+	Retry time.Duration
+}
+
+// An EventStream sends Server-Sent Events to a client over a response that
+// has the Content-Type "text/event-stream". An EventStream should only be
+// created using the [Response.EventStream] method.
+//
+// An EventStream sends a comment to the client every 15 seconds, so proxies
+// do not close the connection while no events are being sent. This can be
+// changed using [EventStream.SetHeartbeat]. The stream ends when the callback
+// that created it returns.
+type EventStream struct {
+	writer    io.Writer
+	mutex     sync.Mutex
+	heartbeat *time.Ticker
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Returns the value of the request's Last-Event-ID header, which a client
+// sends when it reconnects to an event stream. It is the ID of the last
+// [Event] the client received, or an empty string if the header was not sent.
+func (r Request) LastEventID() string {
+	id, _ := r.headers.get("Last-Event-ID")
+	return id
+}
+
+// Starts streaming the response as Server-Sent Events, and returns an
+// [EventStream] used to send events to the client. The Content-Type header is
+// set to "text/event-stream", and the status line and headers are sent to
+// the client immediately, as they are by [Response.Stream]. Calling
+// EventStream again returns the same EventStream.
+// An error is returned if the Response is not attached to a client connection.
+func (r *Response) EventStream() (*EventStream, error) {
+	if r.events != nil {
+		return r.events, nil
+	}
+
+	r.headers["Content-Type"] = "text/event-stream"
+	r.headers["Cache-Control"] = "no-cache"
+	writer, err := r.Stream()
+	if err != nil {
+		return nil, err
+	}
+
+	es := &EventStream{
+		writer:    writer,
+		heartbeat: time.NewTicker(defaultHeartbeatInterval),
+		done:      make(chan struct{}),
+	}
+	r.events = es
+
+	go es.sendHeartbeats()
+	if r.clientGone != nil {
+		go es.watchClient(r.clientGone())
+	}
+	return es, nil
+}
+
+// Sends an event to the client. An error is returned if the event's ID or
+// Event contain a line break, or if the event could not be sent, such as when
+// the client has disconnected.
+func (es *EventStream) Send(event Event) error {
+	if strings.ContainsAny(event.ID, "\r\n\x00") {
+		return fmt.Errorf("event id cannot contain a line break or null character")
+	}
+
+	if strings.ContainsAny(event.Event, "\r\n") {
+		return fmt.Errorf("event type cannot contain a line break")
+	}
+
+	var message strings.Builder
+	if event.ID != "" {
+		message.WriteString("id: " + event.ID + "\n")
+	}
+
+	if event.Event != "" {
+		message.WriteString("event: " + event.Event + "\n")
+	}
+
+	if event.Retry > 0 {
+		message.WriteString("retry: " + strconv.FormatInt(event.Retry.Milliseconds(), 10) + "\n")
+	}
+
+	if event.Data != "" || event.Event != "" {
+		data := strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(event.Data)
+		for _, line := range strings.Split(data, "\n") {
+			message.WriteString("data: " + line + "\n")
+		}
+	}
+
+	message.WriteString("\n")
+	return es.write(message.String())
+}
+
+// Sends a comment to the client. Comments are ignored by clients, but keep
+// the connection active. An error is returned if the comment could not be sent.
+func (es *EventStream) Comment(comment string) error {
+	var message strings.Builder
+	lines := strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(comment)
+	for _, line := range strings.Split(lines, "\n") {
+		message.WriteString(": " + line + "\n")
+	}
+
+	message.WriteString("\n")
+	return es.write(message.String())
+}
+
+// Changes how often a comment is sent to the client to keep the connection
+// active. If interval is 0, no comments are sent.
+func (es *EventStream) SetHeartbeat(interval time.Duration) {
+	if interval <= 0 {
+		es.heartbeat.Stop()
+		return
+	}
+	es.heartbeat.Reset(interval)
+}
+
+// Returns a channel that is closed when the client disconnects, or when
+// sending to it fails. Callbacks that send events over a long time should
+// return once it is closed.
+func (es *EventStream) Done() <-chan struct{} {
+	return es.done
+}
+
+func (es *EventStream) write(message string) error {
+	es.mutex.Lock()
+	defer es.mutex.Unlock()
+
+	select {
+	case <-es.done:
+		return fmt.Errorf("the event stream is closed")
+	default:
+	}
+
+	_, err := io.WriteString(es.writer, message)
+	if err != nil {
+		es.close()
+	}
+	return err
+}
+
+func (es *EventStream) sendHeartbeats() {
+	for {
+		select {
+		case <-es.done:
+			return
+		case <-es.heartbeat.C:
+			es.Comment("keep-alive")
+		}
+	}
+}
+
+func (es *EventStream) watchClient(gone <-chan struct{}) {
+	select {
+	case <-es.done:
+	case <-gone:
+		es.close()
+	}
+}
+
+// finish closes the stream once any event being sent has been written, so
+// nothing is written after the response ends. A nil EventStream does nothing.
+func (es *EventStream) finish() {
+	if es == nil {
+		return
+	}
+
+	es.mutex.Lock()
+	defer es.mutex.Unlock()
+	es.close()
+}
+
+// close stops the heartbeat and signals that the stream is finished
+func (es *EventStream) close() {
+	es.closeOnce.Do(func() {
+		es.heartbeat.Stop()
+		close(es.done)
+	})
+}
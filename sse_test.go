@@ -0,0 +1,116 @@
+package simplehttp
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResponse_EventStream(t *testing.T) {
+	var client strings.Builder
+	res := newResponse()
+	res.startStream = func(res *Response) (io.Writer, error) {
+		client.WriteString(res.head())
+		return &client, nil
+	}
+
+	events, err := res.EventStream()
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+	events.SetHeartbeat(0)
+
+	events.Send(Event{ID: "1", Event: "update", Data: "first\r\nsecond", Retry: 3 * time.Second})
+	events.Send(Event{Data: "plain"})
+	events.Comment("note")
+	res.closeStream()
+
+	expected := "id: 1\nevent: update\nretry: 3000\ndata: first\ndata: second\n\n" +
+		"data: plain\n\n" +
+		": note\n\n"
+	if !strings.HasSuffix(client.String(), doubleLineEnd+expected) {
+		t.Fatalf("incorrect stream. Expected '%s' | Actual '%s'", expected, client.String())
+	}
+
+	if !strings.Contains(client.String(), "Content-Type: text/event-stream"+lineEnd) {
+		t.Fatalf("incorrect headers. Actual '%s'", client.String())
+	}
+
+	if events.Send(Event{Data: "late"}) == nil {
+		t.Fatalf("expected an error when sending after the stream has finished")
+	}
+
+	if again, _ := res.EventStream(); again != events {
+		t.Fatalf("expected the same event stream to be returned")
+	}
+}
+
+func TestEventStream_Send_InvalidFields(t *testing.T) {
+	res := newResponse()
+	res.startStream = func(res *Response) (io.Writer, error) {
+		return io.Discard, nil
+	}
+	events, _ := res.EventStream()
+	defer res.closeStream()
+
+	if events.Send(Event{ID: "1\n2"}) == nil {
+		t.Fatalf("expected an error for an id containing a line break")
+	}
+
+	if events.Send(Event{Event: "a\rb"}) == nil {
+		t.Fatalf("expected an error for an event type containing a line break")
+	}
+}
+
+func TestResponse_EventStream_ErrorIfNotAttached(t *testing.T) {
+	res := newResponse()
+	_, err := res.EventStream()
+	if err == nil {
+		t.Fatalf("expected an error, but it was nil")
+	}
+}
+
+func TestServer_EventStream(t *testing.T) {
+	server := NewServer(0)
+	finished := make(chan string)
+	server.Get("/events", func(req Request, res *Response) error {
+		events, err := res.EventStream()
+		if err != nil {
+			return err
+		}
+		events.SetHeartbeat(10 * time.Millisecond)
+		events.Send(Event{ID: "6", Data: "resumed after " + req.LastEventID()})
+
+		<-events.Done()
+		finished <- "disconnected"
+		return nil
+	})
+
+	client, conn := net.Pipe()
+	go server.handleConnection(conn)
+	go client.Write([]byte("GET /events HTTP/1.0" + lineEnd + "Last-Event-ID: 5" + doubleLineEnd))
+
+	reader := bufio.NewReader(client)
+	received := ""
+	for !strings.Contains(received, ": keep-alive\n\n") {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("did not expect an error but received: %v", err)
+		}
+		received += line
+	}
+
+	if !strings.Contains(received, "id: 6\ndata: resumed after 5\n\n") {
+		t.Fatalf("incorrect stream. Actual '%s'", received)
+	}
+
+	client.Close()
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the disconnect to be detected")
+	}
+}
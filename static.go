@@ -0,0 +1,214 @@
+package simplehttp
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const defaultIndexFile string = "index.html"
+
+// A FileServer serves a tree of static files to GET requests.
+// A FileServer should only be created using the [Server.Static] or
+// [Server.StaticFS] methods. Its fields can be changed after it has been
+// registered.
+//
+// Requests for a directory are answered with its index file. If the
+// directory has no index file, a listing of its contents is returned when
+// ListDirectories is true, otherwise a 404 Not Found is returned.
+// Requests for paths containing a dot-file (a file or directory whose name
+// begins with '.') are answered with a 404 Not Found unless AllowDotFiles
+// is true.
+type FileServer struct {
+	// FS is the file system that files are served from.
+	FS fs.FS
+	// Index is the name of the file served for requests to a directory.
+	// Defaults to "index.html".
+	Index string
+	// ListDirectories enables listing the contents of
+	// directories that do not contain an index file.
+	ListDirectories bool
+	// AllowDotFiles enables serving files and directories
+	// whose names begin with '.'.
+	AllowDotFiles bool
+	prefix        string
+}
+
+// Registers a [FileServer] that serves the files found in the dir directory
+// to GET requests made to the provided prefix or any path below it.
+// For example, with a prefix of "/assets" and a dir of "./public", a request
+// for "/assets/css/site.css" is answered with the file "./public/css/site.css".
+// Callbacks registered for an exact path take precedence over the FileServer.
+func (s *Server) Static(prefix string, dir string) (*FileServer, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return nil, fmt.Errorf("'%s' is not a directory", dir)
+	}
+
+	return s.StaticFS(prefix, os.DirFS(dir))
+}
+
+// Registers a [FileServer] that serves the files found in fsys to GET requests
+// made to the provided prefix or any path below it. fsys can be any
+// [fs.FS], including an [embed.FS]. See [Server.Static] for details.
+//
+// [embed.FS]: https://pkg.go.dev/embed#FS
+func (s *Server) StaticFS(prefix string, fsys fs.FS) (*FileServer, error) {
+	fileServer := &FileServer{
+		FS:     fsys,
+		Index:  defaultIndexFile,
+		prefix: strings.TrimSuffix(prefix, "/"),
+	}
+
+	err := s.callbackMap.registerPrefixCallback(get, prefix, fileServer.serve)
+	if err != nil {
+		return nil, err
+	}
+
+	return fileServer, nil
+}
+
+func (fsrv *FileServer) serve(req Request, res *Response) error {
+	urlPath := req.uri.Path
+	name, ok := fsrv.resolve(urlPath)
+	if !ok {
+		res.SetStatus(404)
+		return nil
+	}
+
+	info, err := fs.Stat(fsrv.FS, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+			res.SetStatus(404)
+			return nil
+		}
+		return err
+	}
+
+	if !info.IsDir() {
+		return fsrv.serveFile(res, name)
+	}
+
+	// directories are always referred to with a trailing slash so
+	// relative links in index files and listings resolve correctly
+	if !strings.HasSuffix(urlPath, "/") {
+		location := url.URL{Path: urlPath + "/", RawQuery: req.uri.RawQuery}
+		res.SetStatus(301)
+		res.headers["Location"] = location.String()
+		return nil
+	}
+
+	index := path.Join(name, fsrv.Index)
+	if indexInfo, err := fs.Stat(fsrv.FS, index); err == nil && !indexInfo.IsDir() {
+		return fsrv.serveFile(res, index)
+	}
+
+	if !fsrv.ListDirectories {
+		res.SetStatus(404)
+		return nil
+	}
+
+	return fsrv.serveListing(res, name, urlPath)
+}
+
+// resolve converts a request path into a name within the FileServer's FS.
+// Returns false if the path is not allowed to be served.
+func (fsrv *FileServer) resolve(urlPath string) (string, bool) {
+	relative := strings.TrimPrefix(urlPath, fsrv.prefix)
+	if strings.Contains(relative, "\\") || strings.Contains(relative, "\x00") {
+		return "", false
+	}
+
+	// path.Clean on a rooted path removes any ".." elements, so
+	// the result can never escape the root of the FS
+	name := strings.TrimPrefix(path.Clean("/"+relative), "/")
+	if name == "" {
+		name = "."
+	}
+
+	if !fs.ValidPath(name) {
+		return "", false
+	}
+
+	if !fsrv.AllowDotFiles {
+		for _, element := range strings.Split(name, "/") {
+			if element != "." && strings.HasPrefix(element, ".") {
+				return "", false
+			}
+		}
+	}
+
+	return name, true
+}
+
+func (fsrv *FileServer) serveFile(res *Response, name string) error {
+	contents, err := fs.ReadFile(fsrv.FS, name)
+	if err != nil {
+		return err
+	}
+
+	info, err := fs.Stat(fsrv.FS, name)
+	if err != nil {
+		return err
+	}
+
+	contentType, err := contentTypeByExtension(name)
+	if err != nil {
+		contentType = "application/octet-stream"
+	}
+
+	body := string(contents)
+	res.body = body
+	res.headers["Content-Length"] = strconv.Itoa(len(body))
+	res.headers["Content-Type"] = contentType
+	res.AcceptRanges()
+
+	// files in an embed.FS do not have a modification time
+	if !info.ModTime().IsZero() {
+		res.SetLastModified(info.ModTime())
+	}
+	return nil
+}
+
+func (fsrv *FileServer) serveListing(res *Response, name string, urlPath string) error {
+	entries, err := fs.ReadDir(fsrv.FS, name)
+	if err != nil {
+		return err
+	}
+
+	listing := "<!DOCTYPE html>\n<html>\n<head><title>Index of " + html.EscapeString(urlPath) +
+		"</title></head>\n<body>\n<h1>Index of " + html.EscapeString(urlPath) + "</h1>\n<ul>\n"
+
+	if urlPath != fsrv.prefix+"/" {
+		listing += "<li><a href=\"../\">../</a></li>\n"
+	}
+
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if !fsrv.AllowDotFiles && strings.HasPrefix(entryName, ".") {
+			continue
+		}
+
+		if entry.IsDir() {
+			entryName += "/"
+		}
+
+		link := url.URL{Path: entryName}
+		listing += fmt.Sprintf("<li><a href=\"%s\">%s</a></li>\n",
+			html.EscapeString(link.String()), html.EscapeString(entryName))
+	}
+
+	listing += "</ul>\n</body>\n</html>\n"
+	res.SetHtml(listing)
+	return nil
+}
@@ -0,0 +1,113 @@
+package simplehttp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func newStaticTestServer(t *testing.T) (Server, *FileServer) {
+	server := NewServer(0)
+	fsys := fstest.MapFS{
+		"index.html":         {Data: []byte("<h1>home</h1>")},
+		"css/site.css":       {Data: []byte("body {}")},
+		"docs/readme.txt":    {Data: []byte("read me")},
+		"docs/.secret":       {Data: []byte("secret")},
+		".git/config":        {Data: []byte("[core]")},
+		"data/unknown.xyzab": {Data: []byte("unknown")},
+	}
+
+	fileServer, err := server.StaticFS("/assets", fsys)
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+
+	return server, fileServer
+}
+
+func serveStatic(server Server, path string) Response {
+	uri, _ := url.ParseRequestURI(path)
+	res := newResponse()
+	server.dispatch(Request{method: get, uri: *uri}, &res)
+	return res
+}
+
+func TestStatic_ServesFile(t *testing.T) {
+	server, _ := newStaticTestServer(t)
+	res := serveStatic(server, "/assets/css/site.css")
+
+	if res.statusCode != 200 || res.body != "body {}" {
+		t.Fatalf("incorrect response. Expected '200 body {}' | Actual '%d %s'", res.statusCode, res.body)
+	}
+
+	if !strings.HasPrefix(res.headers["Content-Type"], "text/css") {
+		t.Fatalf("incorrect Content-Type. Expected 'text/css' | Actual '%s'", res.headers["Content-Type"])
+	}
+
+	if res.headers["Content-Length"] != "7" {
+		t.Fatalf("incorrect Content-Length. Expected '7' | Actual '%s'", res.headers["Content-Length"])
+	}
+}
+
+func TestStatic_UnknownExtension(t *testing.T) {
+	server, _ := newStaticTestServer(t)
+	res := serveStatic(server, "/assets/data/unknown.xyzab")
+
+	if res.headers["Content-Type"] != "application/octet-stream" {
+		t.Fatalf("incorrect Content-Type. Expected 'application/octet-stream' | Actual '%s'",
+			res.headers["Content-Type"])
+	}
+}
+
+func TestStatic_ResolvesIndex(t *testing.T) {
+	server, _ := newStaticTestServer(t)
+
+	res := serveStatic(server, "/assets/")
+	if res.statusCode != 200 || res.body != "<h1>home</h1>" {
+		t.Fatalf("incorrect response. Expected '200 <h1>home</h1>' | Actual '%d %s'", res.statusCode, res.body)
+	}
+
+	res = serveStatic(server, "/assets")
+	if res.statusCode != 301 || res.headers["Location"] != "/assets/" {
+		t.Fatalf("expected a redirect to '/assets/' | Actual '%d %s'", res.statusCode, res.headers["Location"])
+	}
+}
+
+func TestStatic_DirectoryListing(t *testing.T) {
+	server, fileServer := newStaticTestServer(t)
+
+	res := serveStatic(server, "/assets/docs/")
+	if res.statusCode != 404 {
+		t.Fatalf("expected a 404 when listing is disabled | Actual '%d'", res.statusCode)
+	}
+
+	fileServer.ListDirectories = true
+	res = serveStatic(server, "/assets/docs/")
+	if res.statusCode != 200 || !strings.Contains(res.body, `href="readme.txt"`) {
+		t.Fatalf("expected a listing containing readme.txt | Actual '%d %s'", res.statusCode, res.body)
+	}
+
+	if strings.Contains(res.body, ".secret") {
+		t.Fatalf("the listing should not contain dot-files")
+	}
+}
+
+func TestStatic_BlocksTraversalAndDotFiles(t *testing.T) {
+	server, _ := newStaticTestServer(t)
+	paths := []string{
+		"/assets/../static.go",
+		"/assets/%2e%2e/static.go",
+		"/assets/css/..%2f..%2fstatic.go",
+		"/assets/.git/config",
+		"/assets/docs/.secret",
+		"/assets/missing.html",
+	}
+
+	for _, path := range paths {
+		res := serveStatic(server, path)
+		if res.statusCode != 404 {
+			t.Fatalf("expected a 404 for '%s' | Actual '%d %s'", path, res.statusCode, res.body)
+		}
+	}
+}
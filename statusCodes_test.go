@@ -0,0 +1,23 @@
+package simplehttp
+
+import "testing"
+
+func TestGetReasonPhrase(t *testing.T) {
+	tests := map[uint]string{
+		StatusContinue:                      "Continue",
+		StatusOK:                            "OK",
+		StatusFound:                         "Found",
+		StatusPermanentRedirect:             "Permanent Redirect",
+		StatusTeapot:                        "I'm a teapot",
+		StatusTooManyRequests:               "Too Many Requests",
+		StatusUnavailableForLegalReasons:    "Unavailable For Legal Reasons",
+		StatusNetworkAuthenticationRequired: "Network Authentication Required",
+		599:                                 "",
+	}
+
+	for status, expected := range tests {
+		if actual := getReasonPhrase(status); actual != expected {
+			t.Fatalf("incorrect reason phrase for %d. Expected '%s' | Actual '%s'", status, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package simplehttp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceID is the identifier of a trace, shared by every span within it.
+type TraceID [16]byte
+
+// Returns the lowercase hex encoding of the TraceID.
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID is the identifier of a single span within a trace.
+type SpanID [8]byte
+
+// Returns the lowercase hex encoding of the SpanID.
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanContext is the portion of a span that is propagated between services
+// using the W3C traceparent and tracestate headers. See the
+// [W3C Trace Context] specification for details.
+//
+// [W3C Trace Context]: https://www.w3.org/TR/trace-context/
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	// Flags are the trace-flags of the traceparent header. The least
+	// significant bit is the sampled flag.
+	Flags byte
+	// TraceState is the value of the tracestate header, which carries
+	// vendor-specific trace information.
+	TraceState string
+}
+
+// Returns true if neither the TraceID nor the SpanID are all zeroes.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Returns true if the sampled flag is set.
+func (sc SpanContext) Sampled() bool {
+	return sc.Flags&0x01 == 0x01
+}
+
+// Returns the SpanContext formatted as the value of a traceparent header,
+// ex. 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func (sc SpanContext) TraceParent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+// Parses the value of a traceparent header. An error is returned if the value
+// is malformed or contains an all-zero trace or span ID.
+func ParseTraceParent(traceParent string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(traceParent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 ||
+		len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, fmt.Errorf("malformed traceparent: `%s`", traceParent)
+	}
+
+	// the specification only allows lowercase hex
+	if strings.ToLower(traceParent) != traceParent {
+		return SpanContext{}, fmt.Errorf("malformed traceparent: `%s`", traceParent)
+	}
+
+	// version 00 has exactly four fields, future versions may add more
+	if parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, fmt.Errorf("unsupported traceparent version: `%s`", traceParent)
+	}
+
+	var sc SpanContext
+	_, err1 := hex.DecodeString(parts[0])
+	_, err2 := hex.Decode(sc.TraceID[:], []byte(parts[1]))
+	_, err3 := hex.Decode(sc.SpanID[:], []byte(parts[2]))
+	flags, err4 := hex.DecodeString(parts[3])
+	for _, err := range []error{err1, err2, err3, err4} {
+		if err != nil {
+			return SpanContext{}, fmt.Errorf("malformed traceparent: `%s`", traceParent)
+		}
+	}
+
+	if !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("traceparent contains an invalid ID: `%s`", traceParent)
+	}
+
+	sc.Flags = flags[0]
+	return sc, nil
+}
+
+// Creates a SpanContext for a new span. If parent is valid, the new span
+// belongs to the same trace and inherits its flags and trace state.
+// Otherwise a new, sampled trace is started.
+func NewSpanContext(parent SpanContext) SpanContext {
+	sc := SpanContext{Flags: 0x01}
+	if parent.IsValid() {
+		sc = parent
+	} else {
+		rand.Read(sc.TraceID[:])
+	}
+
+	rand.Read(sc.SpanID[:])
+	return sc
+}
+
+type spanContextKey struct{}
+
+// Returns a copy of ctx that carries the provided SpanContext.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// Returns the SpanContext carried by ctx. The second return value is false
+// if ctx does not carry one. The context of a [Request] handled by a [Server]
+// with a Tracer carries the SpanContext of the request's span.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok
+}
+
+// Adds the traceparent and tracestate headers for the SpanContext carried by
+// ctx to headers, so the trace continues in the service receiving a request
+// with them. Nothing is added if ctx does not carry a SpanContext.
+func InjectTraceContext(ctx context.Context, headers map[string]string) {
+	sc, ok := SpanContextFromContext(ctx)
+	if !ok || !sc.IsValid() {
+		return
+	}
+
+	headers["traceparent"] = sc.TraceParent()
+	if sc.TraceState != "" {
+		headers["tracestate"] = sc.TraceState
+	}
+}
+
+// A Tracer creates spans for the requests handled by a [Server]. Implement
+// Tracer to send spans to a tracing backend, or use [NewInMemoryTracer]
+// in tests. Methods may be called concurrently.
+type Tracer interface {
+	// StartSpan starts a span with the provided name at the time start.
+	// If parent is valid, the new span is its child, otherwise the new span
+	// is the root of a new trace. See [NewSpanContext].
+	StartSpan(name string, parent SpanContext, start time.Time) Span
+}
+
+// A Span represents a single operation within a trace.
+type Span interface {
+	// SpanContext returns the span's SpanContext.
+	SpanContext() SpanContext
+	// SetAttribute records a key/value pair describing the operation.
+	SetAttribute(key string, value any)
+	// End completes the span at the time end.
+	End(end time.Time)
+}
+
+// requestTrace holds the span of a request being handled by the server.
+// A nil requestTrace does nothing, so the server does not need to check
+// whether a Tracer was provided.
+type requestTrace struct {
+	tracer Tracer
+	span   Span
+	route  string
+}
+
+// startTrace starts the span of a request that the server started reading at
+// start. The span continues the trace from the request's traceparent header,
+// if it has one. The returned Request's context carries the span's SpanContext.
+func (s *Server) startTrace(req Request, start time.Time) (Request, *requestTrace) {
+	if s.Tracer == nil {
+		return req, nil
+	}
+
+	var parent SpanContext
+	if traceParent, exists := req.headers.get("traceparent"); exists {
+		parsed, err := ParseTraceParent(traceParent)
+		if err == nil {
+			parent = parsed
+			parent.TraceState, _ = req.headers.get("tracestate")
+		}
+	}
+
+	// spans are named by route rather than path, so there is one name per
+	// callback, as there is for the metrics of a Server
+	route := s.callbackMap.route(req.method, req.Path())
+	if route == "" {
+		route = unmatchedRoute
+	}
+
+	span := s.Tracer.StartSpan(req.Method()+" "+route, parent, start)
+	span.SetAttribute("http.method", req.Method())
+	span.SetAttribute("http.target", req.Uri())
+	span.SetAttribute("url.path", req.Path())
+	span.SetAttribute("http.flavor", strings.TrimPrefix(req.httpVersion, "HTTP/"))
+	span.SetAttribute("net.peer.addr", req.remoteAddr)
+
+	req = req.WithContext(ContextWithSpanContext(req.Context(), span.SpanContext()))
+	return req, &requestTrace{tracer: s.Tracer, span: span}
+}
+
+// tracedDispatch calls dispatch, recording the routing and callback phases
+// of the request in its trace
+func (s *Server) tracedDispatch(req Request, res *Response, trace *requestTrace) {
+	if trace == nil {
+		s.dispatch(req, res)
+		return
+	}
+
+	routeStart := time.Now()
+	trace.route = s.callbackMap.route(req.method, req.Path())
+	trace.phase("route", routeStart, time.Now())
+
+	callbackStart := time.Now()
+	s.dispatch(req, res)
+	trace.phase("callback", callbackStart, time.Now())
+}
+
+// phase records a child span of the request's span for one phase of handling it
+func (rt *requestTrace) phase(name string, start time.Time, end time.Time) {
+	if rt == nil {
+		return
+	}
+
+	span := rt.tracer.StartSpan(name, rt.span.SpanContext(), start)
+	span.End(end)
+}
+
+// end completes the request's span once the response has been sent
+func (rt *requestTrace) end(res Response) {
+	if rt == nil {
+		return
+	}
+
+	rt.span.SetAttribute("http.status_code", res.statusCode)
+	if rt.route != "" {
+		rt.span.SetAttribute("http.route", rt.route)
+	}
+	rt.span.End(time.Now())
+}
+
+// RecordedSpan is a span that has been recorded by an [InMemoryTracer].
+type RecordedSpan struct {
+	Name        string
+	SpanContext SpanContext
+	Parent      SpanContext
+	Start       time.Time
+	End         time.Time
+	Attributes  map[string]any
+}
+
+// InMemoryTracer is a [Tracer] that keeps every span that has ended in memory.
+// It is intended for tests. An InMemoryTracer should only be created using
+// the [NewInMemoryTracer] method.
+type InMemoryTracer struct {
+	mutex sync.Mutex
+	spans []RecordedSpan
+}
+
+// Creates a new, empty [InMemoryTracer].
+func NewInMemoryTracer() *InMemoryTracer {
+	return &InMemoryTracer{spans: make([]RecordedSpan, 0)}
+}
+
+// StartSpan starts a span that is recorded once it ends.
+func (t *InMemoryTracer) StartSpan(name string, parent SpanContext, start time.Time) Span {
+	return &inMemorySpan{
+		tracer: t,
+		recorded: RecordedSpan{
+			Name:        name,
+			SpanContext: NewSpanContext(parent),
+			Parent:      parent,
+			Start:       start,
+			Attributes:  make(map[string]any),
+		},
+	}
+}
+
+// Returns the spans that have ended, in the order they ended.
+func (t *InMemoryTracer) Spans() []RecordedSpan {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]RecordedSpan{}, t.spans...)
+}
+
+// Removes every recorded span.
+func (t *InMemoryTracer) Reset() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.spans = t.spans[:0]
+}
+
+type inMemorySpan struct {
+	tracer   *InMemoryTracer
+	mutex    sync.Mutex
+	recorded RecordedSpan
+}
+
+func (s *inMemorySpan) SpanContext() SpanContext {
+	return s.recorded.SpanContext
+}
+
+func (s *inMemorySpan) SetAttribute(key string, value any) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.recorded.Attributes[key] = value
+}
+
+func (s *inMemorySpan) End(end time.Time) {
+	s.mutex.Lock()
+	s.recorded.End = end
+	recorded := s.recorded
+	s.mutex.Unlock()
+
+	s.tracer.mutex.Lock()
+	defer s.tracer.mutex.Unlock()
+	s.tracer.spans = append(s.tracer.spans, recorded)
+}
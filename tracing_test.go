@@ -0,0 +1,169 @@
+package simplehttp
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	sc, err := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("incorrect trace ID. Expected '4bf92f3577b34da6a3ce929d0e0e4736' | Actual '%s'", sc.TraceID)
+	}
+
+	if sc.SpanID.String() != "00f067aa0ba902b7" {
+		t.Fatalf("incorrect span ID. Expected '00f067aa0ba902b7' | Actual '%s'", sc.SpanID)
+	}
+
+	if !sc.Sampled() {
+		t.Fatalf("expected the span context to be sampled")
+	}
+
+	if sc.TraceParent() != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Fatalf("incorrect traceparent. Actual '%s'", sc.TraceParent())
+	}
+}
+
+func TestParseTraceParent_FutureVersion(t *testing.T) {
+	_, err := ParseTraceParent("cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestParseTraceParent_Invalid(t *testing.T) {
+	invalid := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+	}
+
+	for _, traceParent := range invalid {
+		_, err := ParseTraceParent(traceParent)
+		if err == nil {
+			t.Fatalf("expected an error for traceparent '%s'", traceParent)
+		}
+	}
+}
+
+func TestNewSpanContext(t *testing.T) {
+	root := NewSpanContext(SpanContext{})
+	if !root.IsValid() || !root.Sampled() {
+		t.Fatalf("expected a valid, sampled root span context. Actual '%s'", root.TraceParent())
+	}
+
+	root.TraceState = "vendor=value"
+	child := NewSpanContext(root)
+	if child.TraceID != root.TraceID || child.SpanID == root.SpanID || child.TraceState != root.TraceState {
+		t.Fatalf("incorrect child span context. Expected trace '%s' | Actual '%s'", root.TraceID, child.TraceID)
+	}
+}
+
+func TestInjectTraceContext(t *testing.T) {
+	sc, _ := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	sc.TraceState = "vendor=value"
+
+	headers := make(map[string]string)
+	InjectTraceContext(context.Background(), headers)
+	if len(headers) != 0 {
+		t.Fatalf("expected no headers without a span context. Actual '%v'", headers)
+	}
+
+	InjectTraceContext(ContextWithSpanContext(context.Background(), sc), headers)
+	if headers["traceparent"] != sc.TraceParent() {
+		t.Fatalf("incorrect traceparent. Expected '%s' | Actual '%s'", sc.TraceParent(), headers["traceparent"])
+	}
+
+	if headers["tracestate"] != "vendor=value" {
+		t.Fatalf("incorrect tracestate. Expected 'vendor=value' | Actual '%s'", headers["tracestate"])
+	}
+}
+
+func TestServer_Tracer(t *testing.T) {
+	tracer := NewInMemoryTracer()
+	server := NewServer(0)
+	server.Tracer = tracer
+
+	var callbackContext SpanContext
+	server.Get("/users", func(req Request, res *Response) error {
+		callbackContext, _ = SpanContextFromContext(req.Context())
+		res.SetHtml("hello")
+		return nil
+	})
+
+	sendRawRequest(&server, "GET /users?id=5 HTTP/1.0"+lineEnd+
+		"traceparent: 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"+lineEnd+
+		"tracestate: vendor=value"+doubleLineEnd)
+
+	spans := tracer.Spans()
+	names := []string{"read", "route", "callback", "write", "GET /users"}
+	if len(spans) != len(names) {
+		t.Fatalf("incorrect number of spans. Expected '%d' | Actual '%d'", len(names), len(spans))
+	}
+
+	request := spans[len(spans)-1]
+	for i, name := range names {
+		if spans[i].Name != name {
+			t.Fatalf("incorrect span name. Expected '%s' | Actual '%s'", name, spans[i].Name)
+		}
+
+		if spans[i].SpanContext.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Fatalf("span '%s' is not part of the client's trace. Actual '%s'", name, spans[i].SpanContext.TraceID)
+		}
+
+		if name != request.Name && spans[i].Parent.SpanID != request.SpanContext.SpanID {
+			t.Fatalf("span '%s' is not a child of the request span", name)
+		}
+	}
+
+	if request.Parent.SpanID.String() != "00f067aa0ba902b7" || request.SpanContext.TraceState != "vendor=value" {
+		t.Fatalf("request span does not continue the client's trace. Actual '%s'", request.Parent.TraceParent())
+	}
+
+	if callbackContext.SpanID != request.SpanContext.SpanID {
+		t.Fatalf("incorrect span context in the request. Expected '%s' | Actual '%s'",
+			request.SpanContext.SpanID, callbackContext.SpanID)
+	}
+
+	if request.Attributes["http.status_code"] != uint(200) || request.Attributes["http.route"] != "/users" ||
+		request.Attributes["url.path"] != "/users" {
+		t.Fatalf("incorrect request span attributes. Actual '%v'", request.Attributes)
+	}
+}
+
+func TestServer_Tracer_StartsNewTrace(t *testing.T) {
+	tracer := NewInMemoryTracer()
+	server := NewServer(0)
+	server.Tracer = tracer
+
+	sendRawRequest(&server, "GET /missing HTTP/1.0"+lineEnd+
+		"traceparent: not-a-trace-parent"+doubleLineEnd)
+
+	spans := tracer.Spans()
+	request := spans[len(spans)-1]
+	if request.Parent.IsValid() || !request.SpanContext.IsValid() {
+		t.Fatalf("expected a new root span. Actual parent '%s'", request.Parent.TraceParent())
+	}
+
+	if request.Attributes["http.status_code"] != uint(404) {
+		t.Fatalf("incorrect status code attribute. Expected '404' | Actual '%v'", request.Attributes["http.status_code"])
+	}
+
+	if request.Name != "GET unmatched" || request.Attributes["url.path"] != "/missing" {
+		t.Fatalf("expected the span to be named by route. Actual '%s' %v", request.Name, request.Attributes)
+	}
+
+	tracer.Reset()
+	if len(tracer.Spans()) != 0 {
+		t.Fatalf("expected no spans after Reset")
+	}
+}
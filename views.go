@@ -0,0 +1,235 @@
+package simplehttp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const defaultViewExtension string = ".html"
+const defaultPartialsDir string = "partials"
+
+// contentTemplate is the name of the template that holds a view's content
+// when it is rendered inside a layout
+const contentTemplate string = "content"
+
+// A ViewEngine renders [html/template] templates, called views, for
+// [Response.Render]. A ViewEngine should only be created using the
+// [Server.Views] or [Server.ViewsFS] methods. Its fields can be changed
+// after it has been created.
+//
+// Views are named by their path relative to the view directory, without
+// their extension. For example, "users/show" is the file "users/show.html".
+//
+// Every file in the Partials directory is parsed along with each view, and
+// can be included by name, such as {{ template "partials/nav" . }}.
+//
+// If a Layout is set, views are rendered inside it. The layout includes the
+// view with {{ template "content" . }}, and can declare blocks such as
+// {{ block "title" . }}Default{{ end }} that views override using
+// {{ define "title" }}...{{ end }}.
+type ViewEngine struct {
+	// FS is the file system that views are loaded from.
+	FS fs.FS
+	// Extension is the file extension of views. Defaults to ".html".
+	Extension string
+	// Layout is the name of the view that every view is rendered inside,
+	// such as "layouts/main". If empty, views are rendered on their own.
+	Layout string
+	// Partials is the directory whose views are available to every view.
+	// Defaults to "partials".
+	Partials string
+	// Funcs are functions that can be called from every view.
+	Funcs template.FuncMap
+	// Reload parses views every time they are rendered, rather than once,
+	// so changes to the files are seen without restarting the server. It is
+	// intended for development.
+	Reload bool
+	mutex  sync.Mutex
+	cache  map[string]*template.Template
+}
+
+// Creates a [ViewEngine] that loads views from the dir directory and makes it
+// available to [Response.Render] for every request handled by the Server.
+func (s *Server) Views(dir string) (*ViewEngine, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return nil, fmt.Errorf("'%s' is not a directory", dir)
+	}
+
+	return s.ViewsFS(os.DirFS(dir)), nil
+}
+
+// Creates a [ViewEngine] that loads views from fsys, such as an [embed.FS],
+// and makes it available to [Response.Render] for every request handled by
+// the Server.
+func (s *Server) ViewsFS(fsys fs.FS) *ViewEngine {
+	s.views = &ViewEngine{
+		FS:        fsys,
+		Extension: defaultViewExtension,
+		Partials:  defaultPartialsDir,
+		cache:     make(map[string]*template.Template),
+	}
+	return s.views
+}
+
+// Renders the named view with data, and sets the response's body to the
+// result. The Content-Type header is set to "text/html; charset=utf-8" and
+// the Content-Length header is set appropriately. The status is not changed,
+// so it can be set with [Response.SetStatus] before or after rendering.
+// Returns an error, and leaves the response unchanged, if the Server does not
+// have a [ViewEngine] or the view could not be parsed or executed.
+func (r *Response) Render(name string, data any) error {
+	if r.views == nil {
+		return fmt.Errorf("unable to render '%s': the server does not have a view engine", name)
+	}
+
+	body, err := r.views.render(name, data)
+	if err != nil {
+		return err
+	}
+
+	r.body = body
+	r.headers["Content-Length"] = strconv.Itoa(len(body))
+	r.headers["Content-Type"] = "text/html; charset=utf-8"
+	return nil
+}
+
+func (ve *ViewEngine) render(name string, data any) (string, error) {
+	tmpl, err := ve.template(name)
+	if err != nil {
+		return "", err
+	}
+
+	entry := contentTemplate
+	if ve.Layout != "" {
+		entry = ve.Layout
+	}
+
+	// execute into a buffer so a failure part-way through does not
+	// leave the response with a partial body
+	var body bytes.Buffer
+	err = tmpl.ExecuteTemplate(&body, entry, data)
+	if err != nil {
+		return "", fmt.Errorf("unable to render '%s': %v", name, err)
+	}
+	return body.String(), nil
+}
+
+// template returns the parsed template set for the named view, using the
+// cache unless Reload is true
+func (ve *ViewEngine) template(name string) (*template.Template, error) {
+	if ve.Reload {
+		return ve.parse(name)
+	}
+
+	ve.mutex.Lock()
+	defer ve.mutex.Unlock()
+
+	key := ve.Layout + "\x00" + name
+	if tmpl, exists := ve.cache[key]; exists {
+		return tmpl, nil
+	}
+
+	tmpl, err := ve.parse(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ve.cache[key] = tmpl
+	return tmpl, nil
+}
+
+// parse builds the template set for a view from its layout, the partials,
+// and the view itself
+func (ve *ViewEngine) parse(name string) (*template.Template, error) {
+	tmpl := template.New(contentTemplate).Funcs(ve.Funcs)
+
+	if ve.Layout != "" {
+		err := ve.parseFile(tmpl, ve.Layout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	partials, err := ve.partialNames()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, partial := range partials {
+		err = ve.parseFile(tmpl, partial)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the view is parsed last, so the blocks it defines replace the layout's
+	err = ve.parseFileAs(tmpl, name, contentTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+func (ve *ViewEngine) parseFile(tmpl *template.Template, name string) error {
+	return ve.parseFileAs(tmpl, name, name)
+}
+
+// parses the named view into tmpl as a template called as
+func (ve *ViewEngine) parseFileAs(tmpl *template.Template, name string, as string) error {
+	if !fs.ValidPath(name) {
+		return fmt.Errorf("invalid view name '%s'", name)
+	}
+
+	contents, err := fs.ReadFile(ve.FS, name+ve.Extension)
+	if err != nil {
+		return fmt.Errorf("unable to read view '%s': %v", name, err)
+	}
+
+	target := tmpl
+	if as != tmpl.Name() {
+		target = tmpl.New(as)
+	}
+
+	_, err = target.Parse(string(contents))
+	if err != nil {
+		return fmt.Errorf("unable to parse view '%s': %v", name, err)
+	}
+	return nil
+}
+
+// returns the names of every view in the Partials directory and its subdirectories
+func (ve *ViewEngine) partialNames() ([]string, error) {
+	names := make([]string, 0)
+	if ve.Partials == "" {
+		return names, nil
+	}
+
+	err := fs.WalkDir(ve.FS, ve.Partials, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.IsDir() && path.Ext(p) == ve.Extension {
+			names = append(names, strings.TrimSuffix(p, ve.Extension))
+		}
+		return nil
+	})
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return names, nil
+	}
+	return names, err
+}
@@ -0,0 +1,131 @@
+package simplehttp
+
+import (
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func newTestViews() (*Server, fstest.MapFS) {
+	fsys := fstest.MapFS{
+		"layouts/main.html": {Data: []byte(
+			`<title>{{ block "title" . }}Default{{ end }}</title>{{ template "partials/nav" . }}<main>{{ template "content" . }}</main>`)},
+		"partials/nav.html":      {Data: []byte(`<nav>{{ .User }}</nav>`)},
+		"users/show.html":        {Data: []byte(`{{ define "title" }}{{ .User }}{{ end }}<p>Hello, {{ .User | shout }}</p>`)},
+		"plain.html":             {Data: []byte(`<p>{{ . }}</p>`)},
+		"broken.html":            {Data: []byte(`{{ .Missing.Field }}`)},
+		"partials/readme.txt":    {Data: []byte(`not a view`)},
+		"partials/nested/a.html": {Data: []byte(`nested`)},
+	}
+
+	server := NewServer(0)
+	views := server.ViewsFS(fsys)
+	views.Funcs = template.FuncMap{"shout": strings.ToUpper}
+	return &server, fsys
+}
+
+func TestResponse_Render(t *testing.T) {
+	server, _ := newTestViews()
+	server.views.Layout = "layouts/main"
+
+	res := newResponse()
+	res.views = server.views
+	err := res.Render("users/show", map[string]string{"User": "<alice>"})
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+
+	expected := `<title>&lt;alice&gt;</title><nav>&lt;alice&gt;</nav><main><p>Hello, &lt;ALICE&gt;</p></main>`
+	if res.body != expected {
+		t.Fatalf("incorrect body. Expected '%s' | Actual '%s'", expected, res.body)
+	}
+
+	if res.headers["Content-Type"] != "text/html; charset=utf-8" || res.headers["Content-Length"] != "92" {
+		t.Fatalf("incorrect headers. Actual %v", res.headers)
+	}
+}
+
+func TestResponse_Render_WithoutLayout(t *testing.T) {
+	server, _ := newTestViews()
+
+	res := newResponse()
+	res.views = server.views
+	res.SetStatus(404)
+	err := res.Render("plain", "not found")
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+
+	if res.body != "<p>not found</p>" || res.statusCode != 404 {
+		t.Fatalf("incorrect response. Actual '%d' '%s'", res.statusCode, res.body)
+	}
+}
+
+func TestResponse_Render_Errors(t *testing.T) {
+	server, _ := newTestViews()
+
+	res := newResponse()
+	err := res.Render("plain", nil)
+	if err == nil {
+		t.Fatalf("expected an error without a view engine")
+	}
+
+	res.views = server.views
+	for _, name := range []string{"missing", "../plain", "broken"} {
+		err = res.Render(name, 5)
+		if err == nil {
+			t.Fatalf("expected an error rendering '%s'", name)
+		}
+	}
+
+	if res.body != "" {
+		t.Fatalf("expected the body to be unchanged. Actual '%s'", res.body)
+	}
+}
+
+func TestViewEngine_Cache(t *testing.T) {
+	server, fsys := newTestViews()
+	views := server.views
+
+	first, _ := views.render("plain", "a")
+	fsys["plain.html"] = &fstest.MapFile{Data: []byte(`<div>{{ . }}</div>`)}
+
+	cached, _ := views.render("plain", "a")
+	if cached != first {
+		t.Fatalf("expected the cached view to be used. Actual '%s'", cached)
+	}
+
+	views.Reload = true
+	reloaded, _ := views.render("plain", "a")
+	if reloaded != "<div>a</div>" {
+		t.Fatalf("expected the view to be reloaded. Actual '%s'", reloaded)
+	}
+}
+
+func TestServer_Views(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "index.html"), []byte(`<h1>{{ . }}</h1>`), 0644)
+
+	server := NewServer(0)
+	_, err := server.Views(dir)
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+
+	server.Get("/", func(_ Request, res *Response) error {
+		return res.Render("index", "Home")
+	})
+
+	response := sendRawRequest(&server, "GET / HTTP/1.0"+doubleLineEnd)
+	if !strings.HasSuffix(response, doubleLineEnd+"<h1>Home</h1>") {
+		t.Fatalf("incorrect response. Actual '%s'", response)
+	}
+
+	_, err = server.Views(filepath.Join(dir, "index.html"))
+	if err == nil {
+		t.Fatalf("expected an error for a file")
+	}
+}
@@ -0,0 +1,707 @@
+package simplehttp
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// the GUID that is appended to the Sec-WebSocket-Key header to build the
+// Sec-WebSocket-Accept header, from RFC 6455 Section 1.3
+const webSocketGUID string = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const defaultMaxMessageBytes int64 = 1 * 1024 * 1024 // 1 MB
+const maxControlPayloadBytes int = 125
+
+// how long to wait for the client to reply to a close frame
+const closeTimeout time.Duration = 5 * time.Second
+
+// the extension header sent when permessage-deflate is negotiated. Messages are
+// always compressed independently, so no compression context is kept between them.
+const deflateExtension string = "permessage-deflate; server_no_context_takeover; client_no_context_takeover"
+
+// appended to a compressed message to end the deflate stream, see RFC 7692 Section 7.2.2
+const deflateTail string = "\x00\x00\xff\xff\x01\x00\x00\xff\xff"
+
+// MessageType is the type of a WebSocket data message.
+type MessageType int
+
+const (
+	// TextMessage is a message containing UTF-8 text.
+	TextMessage MessageType = 1
+	// BinaryMessage is a message containing binary data.
+	BinaryMessage MessageType = 2
+)
+
+const (
+	opContinuation byte = 0x0
+	opText         byte = 0x1
+	opBinary       byte = 0x2
+	opClose        byte = 0x8
+	opPing         byte = 0x9
+	opPong         byte = 0xa
+)
+
+// Status codes sent in close frames to explain why a WebSocket connection
+// was closed. See [RFC 6455] Section 7.4.1.
+//
+// [RFC 6455]: https://www.rfc-editor.org/rfc/rfc6455.html#section-7.4.1
+const (
+	CloseNormalClosure      = 1000
+	CloseGoingAway          = 1001
+	CloseProtocolError      = 1002
+	CloseUnsupportedData    = 1003
+	CloseNoStatusReceived   = 1005
+	CloseInvalidPayload     = 1007
+	ClosePolicyViolation    = 1008
+	CloseMessageTooBig      = 1009
+	CloseMandatoryExtension = 1010
+	CloseInternalError      = 1011
+)
+
+// A CloseError is returned by [Conn.ReadMessage] when the client closes
+// the connection. Code is [CloseNoStatusReceived] if the client did not send one.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("the WebSocket connection was closed with code %d: %s", e.Code, e.Reason)
+}
+
+// WebSocketHandler is the function signature that represents the handler of
+// WebSocket connections registered using [Server.WebSocket]. The [Request] is
+// the request that opened the connection, and the [*Conn] is used to send and
+// receive messages. The connection is closed when the handler returns. If an
+// error is returned, it is logged and the connection is closed with
+// [CloseInternalError].
+type WebSocketHandler = func(Request, *Conn) error
+
+// Registers a handler that will be invoked for WebSocket connections opened by
+// GET requests to the provided path. The opening handshake is performed as
+// described in [RFC 6455], so the request passes through middleware, such as
+// authentication, like any other request. Requests to the path that are not
+// WebSocket handshakes receive a 426 Upgrade Required response, and handshakes
+// from an origin that is not allowed by [Server.WebSocketCheckOrigin] receive a
+// 403 Forbidden response. If [Server.WebSocketCompression] is true, messages are compressed when the
+// client supports the permessage-deflate extension.
+//
+// [RFC 6455]: https://www.rfc-editor.org/rfc/rfc6455.html
+func (s *Server) WebSocket(path string, handler WebSocketHandler) error {
+	return s.callbackMap.registerCallback(get, path, func(req Request, res *Response) error {
+		s.upgradeWebSocket(req, res, handler)
+		return nil
+	})
+}
+
+// upgradeWebSocket validates the opening handshake and sets res to switch
+// protocols, or to an error response if the handshake is invalid
+func (s *Server) upgradeWebSocket(req Request, res *Response, handler WebSocketHandler) {
+	connection, _ := req.headers.get("Connection")
+	upgrade, _ := req.headers.get("Upgrade")
+	version, _ := req.headers.get("Sec-WebSocket-Version")
+	if !hasToken(connection, "upgrade") || !hasToken(upgrade, "websocket") || version != "13" {
+		res.SetStatus(StatusUpgradeRequired)
+		res.headers["Upgrade"] = "websocket"
+		res.headers["Connection"] = "Upgrade"
+		res.headers["Sec-WebSocket-Version"] = "13"
+		return
+	}
+
+	key, _ := req.headers.get("Sec-WebSocket-Key")
+	decodedKey, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(decodedKey) != 16 || req.httpVersion == "HTTP/1.0" {
+		res.SetStatus(StatusBadRequest)
+		return
+	}
+
+	if !s.webSocketOriginAllowed(req) {
+		res.SetStatus(StatusForbidden)
+		return
+	}
+
+	res.SetStatus(StatusSwitchingProtocols)
+	res.httpVersion = "HTTP/1.1"
+	res.body = ""
+	delete(res.headers, "Content-Length")
+	res.headers["Upgrade"] = "websocket"
+	res.headers["Connection"] = "Upgrade"
+	res.headers["Sec-WebSocket-Accept"] = webSocketAccept(key)
+
+	extensions, _ := req.headers.get("Sec-WebSocket-Extensions")
+	compress := s.WebSocketCompression && acceptsDeflate(extensions)
+	if compress {
+		res.headers["Sec-WebSocket-Extensions"] = deflateExtension
+	}
+
+	res.upgrade = func(conn net.Conn, reader *bufio.Reader) error {
+		c := &Conn{
+			MaxMessageBytes: defaultMaxMessageBytes,
+			conn:            conn,
+			reader:          reader,
+			compress:        compress,
+		}
+
+		err := handler(req, c)
+		c.finish(err)
+		return err
+	}
+}
+
+// checks the origin of a handshake using Server.WebSocketCheckOrigin, or if it
+// is not set, allows handshakes without an Origin header and handshakes whose
+// Origin has the same host as the request
+func (s *Server) webSocketOriginAllowed(req Request) bool {
+	if s.WebSocketCheckOrigin != nil {
+		return s.WebSocketCheckOrigin(req)
+	}
+
+	origin, exists := req.headers.get("Origin")
+	if !exists {
+		return true
+	}
+
+	originUrl, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(originUrl.Host, req.Host())
+}
+
+// builds the Sec-WebSocket-Accept header for the client's Sec-WebSocket-Key
+func webSocketAccept(key string) string {
+	hash := sha1.Sum([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// checks if a comma separated header value contains token, ignoring case
+func hasToken(value string, token string) bool {
+	for _, element := range strings.Split(value, ",") {
+		if strings.EqualFold(strings.TrimSpace(element), token) {
+			return true
+		}
+	}
+	return false
+}
+
+// checks if the client offered a permessage-deflate extension that can be
+// accepted. Offers that limit the server's window size are declined, since
+// the compress/flate package always uses the full window.
+func acceptsDeflate(extensions string) bool {
+	for _, offer := range strings.Split(extensions, ",") {
+		params := strings.Split(offer, ";")
+		if strings.TrimSpace(params[0]) != "permessage-deflate" {
+			continue
+		}
+
+		acceptable := true
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			value = strings.Trim(strings.TrimSpace(value), `"`)
+			switch strings.TrimSpace(name) {
+			case "server_no_context_takeover", "client_no_context_takeover", "client_max_window_bits":
+			case "server_max_window_bits":
+				acceptable = acceptable && value == "15"
+			default:
+				acceptable = false
+			}
+		}
+
+		if acceptable {
+			return true
+		}
+	}
+	return false
+}
+
+// Conn is a WebSocket connection passed to a [WebSocketHandler]. Messages
+// are read using [Conn.ReadMessage], and written using [Conn.WriteMessage] or
+// [Conn.NextWriter].
+//
+// Ping frames sent by the client are answered automatically while reading
+// messages. Only one goroutine may read from a Conn at a time, but messages
+// can be written from several goroutines.
+type Conn struct {
+	// MaxMessageBytes is the maximum number of bytes a message received from
+	// the client can be, after it has been decompressed. The connection is
+	// closed with [CloseMessageTooBig] if a larger message is received.
+	// Defaults to 1 MB.
+	MaxMessageBytes int64
+	// PongHandler is called with the payload of every pong frame received
+	// from the client, such as replies to [Conn.Ping].
+	PongHandler   func(data []byte)
+	conn          net.Conn
+	reader        *bufio.Reader
+	compress      bool
+	messageMutex  sync.Mutex
+	writeMutex    sync.Mutex
+	closeSent     bool
+	closeReceived bool
+}
+
+type frame struct {
+	fin        bool
+	compressed bool
+	opcode     byte
+	payload    []byte
+}
+
+// Returns the address of the client, as reported by the connection.
+func (c *Conn) RemoteAddr() string {
+	return c.conn.RemoteAddr().String()
+}
+
+// Reads the next data message sent by the client, joining it back together
+// if it was sent in several fragments and decompressing it if necessary.
+// Control frames received while waiting are handled: pings are answered with
+// a pong, and pongs are passed to PongHandler.
+// If the client closes the connection, a [*CloseError] is returned. If the
+// client violates the WebSocket protocol, such as by sending an unmasked frame,
+// invalid UTF-8 in a text message, or a message larger than MaxMessageBytes,
+// the connection is closed with the appropriate status code and an error is returned.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	var messageType MessageType
+	message := make([]byte, 0)
+	compressed := false
+	started := false
+
+	for {
+		f, err := c.readFrame(c.MaxMessageBytes - int64(len(message)))
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch f.opcode {
+		case opPing:
+			c.writeFrame(true, false, opPong, f.payload)
+			continue
+		case opPong:
+			if c.PongHandler != nil {
+				c.PongHandler(f.payload)
+			}
+			continue
+		case opClose:
+			return 0, nil, c.receiveClose(f.payload)
+		case opContinuation:
+			if !started {
+				return 0, nil, c.fail(CloseProtocolError, "received a continuation frame outside of a message")
+			}
+		default:
+			if started {
+				return 0, nil, c.fail(CloseProtocolError, "received a new message before the previous one finished")
+			}
+			started = true
+			messageType = MessageType(f.opcode)
+			compressed = f.compressed
+		}
+
+		message = append(message, f.payload...)
+		if f.fin {
+			break
+		}
+	}
+
+	if compressed {
+		inflated, err := inflateMessage(message, c.MaxMessageBytes)
+		if errors.Is(err, errMessageTooBig) {
+			return 0, nil, c.fail(CloseMessageTooBig, "message exceeded the maximum size")
+		}
+		if err != nil {
+			return 0, nil, c.fail(CloseInvalidPayload, "unable to decompress message")
+		}
+		message = inflated
+	}
+
+	if messageType == TextMessage && !utf8.Valid(message) {
+		return 0, nil, c.fail(CloseInvalidPayload, "text message is not valid UTF-8")
+	}
+
+	return messageType, message, nil
+}
+
+// Sends a message to the client in a single frame, compressing it if
+// permessage-deflate was negotiated. If a writer returned by [Conn.NextWriter]
+// is open, WriteMessage waits until it is closed. An error is returned if a
+// text message is not valid UTF-8, or the message could not be sent.
+func (c *Conn) WriteMessage(messageType MessageType, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("invalid message type %d", messageType)
+	}
+
+	c.messageMutex.Lock()
+	defer c.messageMutex.Unlock()
+	return c.writeMessage(messageType, data)
+}
+
+// sends a message in a single frame. The caller must hold messageMutex.
+func (c *Conn) writeMessage(messageType MessageType, data []byte) error {
+	if messageType == TextMessage && !utf8.Valid(data) {
+		return fmt.Errorf("text message is not valid UTF-8")
+	}
+
+	if !c.compress {
+		return c.writeFrame(true, false, byte(messageType), data)
+	}
+
+	compressed, err := deflateMessage(data)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(true, true, byte(messageType), compressed)
+}
+
+// Returns a writer for a message that is sent to the client in fragments.
+// Each call to Write sends a fragment, and Close sends the final fragment.
+// This is useful for messages whose size is not known in advance. When
+// permessage-deflate was negotiated, the message is instead compressed and
+// sent in a single frame when the writer is closed.
+// Other messages written from other goroutines wait until the writer is
+// closed, so the writer must always be closed. Write returns an error if a
+// text message is not valid UTF-8, in which case only the valid part is sent.
+func (c *Conn) NextWriter(messageType MessageType) (io.WriteCloser, error) {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return nil, fmt.Errorf("invalid message type %d", messageType)
+	}
+
+	c.messageMutex.Lock()
+	return &messageWriter{conn: c, messageType: messageType}, nil
+}
+
+// Sends a ping frame with the optional data to the client, which replies with
+// a pong frame containing the same data (see PongHandler). The data can be at
+// most 125 bytes.
+func (c *Conn) Ping(data []byte) error {
+	if len(data) > maxControlPayloadBytes {
+		return fmt.Errorf("ping data cannot be more than %d bytes", maxControlPayloadBytes)
+	}
+	return c.writeFrame(true, false, opPing, data)
+}
+
+// Sends a close frame with the provided status code, such as
+// [CloseNormalClosure], and reason to the client. No more messages can be
+// written afterwards. The handler should return once it has called Close;
+// the server then waits for the client to acknowledge the close before
+// closing the connection. The reason can be at most 123 bytes.
+func (c *Conn) Close(code int, reason string) error {
+	if len(reason) > maxControlPayloadBytes-2 {
+		return fmt.Errorf("close reason cannot be more than %d bytes", maxControlPayloadBytes-2)
+	}
+
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	return c.writeFrame(true, false, opClose, append(payload, reason...))
+}
+
+// reads a single frame, validating it against the rules of RFC 6455 Section 5
+func (c *Conn) readFrame(maxPayloadBytes int64) (frame, error) {
+	header := make([]byte, 2)
+	_, err := io.ReadFull(c.reader, header)
+	if err != nil {
+		return frame{}, err
+	}
+
+	f := frame{
+		fin:        header[0]&0x80 != 0,
+		compressed: header[0]&0x40 != 0,
+		opcode:     header[0] & 0x0f,
+	}
+	control := f.opcode >= opClose
+
+	switch f.opcode {
+	case opContinuation, opText, opBinary, opClose, opPing, opPong:
+	default:
+		return frame{}, c.fail(CloseProtocolError, fmt.Sprintf("unknown opcode %d", f.opcode))
+	}
+
+	if header[0]&0x30 != 0 {
+		return frame{}, c.fail(CloseProtocolError, "reserved bits are set")
+	}
+
+	if f.compressed && (!c.compress || control || f.opcode == opContinuation) {
+		return frame{}, c.fail(CloseProtocolError, "unexpected compressed frame")
+	}
+
+	if header[1]&0x80 == 0 {
+		return frame{}, c.fail(CloseProtocolError, "frames sent by clients must be masked")
+	}
+
+	length := int64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		_, err = io.ReadFull(c.reader, extended)
+		length = int64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		_, err = io.ReadFull(c.reader, extended)
+		length = int64(binary.BigEndian.Uint64(extended))
+	}
+	if err != nil {
+		return frame{}, err
+	}
+
+	if control && (length > int64(maxControlPayloadBytes) || !f.fin) {
+		return frame{}, c.fail(CloseProtocolError, "control frames must be a single frame of at most 125 bytes")
+	}
+
+	if !control && (length < 0 || length > maxPayloadBytes) {
+		return frame{}, c.fail(CloseMessageTooBig, "message exceeded the maximum size")
+	}
+
+	mask := make([]byte, 4)
+	_, err = io.ReadFull(c.reader, mask)
+	if err != nil {
+		return frame{}, err
+	}
+
+	f.payload = make([]byte, length)
+	_, err = io.ReadFull(c.reader, f.payload)
+	if err != nil {
+		return frame{}, err
+	}
+
+	for i := range f.payload {
+		f.payload[i] ^= mask[i%4]
+	}
+	return f, nil
+}
+
+// writes a single unmasked frame. No frames can be written after a close frame.
+func (c *Conn) writeFrame(fin bool, compressed bool, opcode byte, payload []byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	if c.closeSent {
+		return fmt.Errorf("the WebSocket connection is closed")
+	}
+
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	if compressed {
+		first |= 0x40
+	}
+
+	header := []byte{first}
+	switch {
+	case len(payload) < 126:
+		header = append(header, byte(len(payload)))
+	case len(payload) <= 0xffff:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(len(payload)))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(len(payload)))
+	}
+
+	if opcode == opClose {
+		c.closeSent = true
+	}
+
+	_, err := c.conn.Write(append(header, payload...))
+	return err
+}
+
+// handles a close frame from the client, replying with the same status code
+func (c *Conn) receiveClose(payload []byte) error {
+	c.closeReceived = true
+	if len(payload) == 0 {
+		c.writeFrame(true, false, opClose, nil)
+		return &CloseError{Code: CloseNoStatusReceived}
+	}
+
+	if len(payload) == 1 {
+		return c.fail(CloseProtocolError, "close frame has an invalid payload")
+	}
+
+	code := int(binary.BigEndian.Uint16(payload))
+	reason := string(payload[2:])
+	if !validCloseCode(code) {
+		return c.fail(CloseProtocolError, fmt.Sprintf("close frame has an invalid status code %d", code))
+	}
+
+	if !utf8.ValidString(reason) {
+		return c.fail(CloseInvalidPayload, "close reason is not valid UTF-8")
+	}
+
+	c.writeFrame(true, false, opClose, payload[:2])
+	return &CloseError{Code: code, Reason: reason}
+}
+
+// closes the connection because the client violated the protocol,
+// and returns an error describing the violation
+func (c *Conn) fail(code int, reason string) error {
+	c.Close(code, reason)
+	return fmt.Errorf("WebSocket protocol error: %s", reason)
+}
+
+// finish performs the closing handshake once the handler has returned
+func (c *Conn) finish(handlerErr error) {
+	if !c.closeSent {
+		if handlerErr != nil {
+			c.Close(CloseInternalError, "")
+		} else {
+			c.Close(CloseNormalClosure, "")
+		}
+	}
+
+	if c.closeReceived {
+		return
+	}
+
+	// wait for the client to acknowledge the close, discarding any other frames
+	c.conn.SetReadDeadline(time.Now().Add(closeTimeout))
+	for {
+		f, err := c.readFrame(c.MaxMessageBytes)
+		if err != nil || f.opcode == opClose {
+			return
+		}
+	}
+}
+
+// checks the status code of a received close frame, see RFC 6455 Section 7.4
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	default:
+		return false
+	}
+}
+
+// messageWriter sends a message in fragments, see Conn.NextWriter.
+// It holds the Conn's messageMutex until it is closed.
+type messageWriter struct {
+	conn        *Conn
+	messageType MessageType
+	started     bool
+	closed      bool
+	buffer      bytes.Buffer
+	// partial holds an incomplete UTF-8 character at the end of the
+	// previous write of a text message, which is sent with the next write
+	partial []byte
+}
+
+func (mw *messageWriter) Write(p []byte) (int, error) {
+	if mw.closed {
+		return 0, fmt.Errorf("the message writer is closed")
+	}
+
+	if mw.conn.compress {
+		return mw.buffer.Write(p)
+	}
+
+	fragment := p
+	if mw.messageType == TextMessage {
+		fragment = append(mw.partial, p...)
+		complete := completeUTF8(fragment)
+		if !utf8.Valid(fragment[:complete]) {
+			return 0, fmt.Errorf("text message is not valid UTF-8")
+		}
+
+		mw.partial = bytes.Clone(fragment[complete:])
+		fragment = fragment[:complete]
+	}
+
+	if len(fragment) > 0 {
+		err := mw.conn.writeFrame(false, false, mw.opcode(), fragment)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (mw *messageWriter) Close() error {
+	if mw.closed {
+		return fmt.Errorf("the message writer is closed")
+	}
+	mw.closed = true
+	defer mw.conn.messageMutex.Unlock()
+
+	if mw.conn.compress {
+		return mw.conn.writeMessage(mw.messageType, mw.buffer.Bytes())
+	}
+
+	err := mw.conn.writeFrame(true, false, mw.opcode(), nil)
+	if err == nil && len(mw.partial) > 0 {
+		return fmt.Errorf("text message is not valid UTF-8")
+	}
+	return err
+}
+
+// the first fragment has the message's opcode, and the rest are continuations
+func (mw *messageWriter) opcode() byte {
+	if mw.started {
+		return opContinuation
+	}
+	mw.started = true
+	return byte(mw.messageType)
+}
+
+// returns the length of data without the incomplete UTF-8 character at its end, if any
+func completeUTF8(data []byte) int {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(data)
+}
+
+var errMessageTooBig = errors.New("message exceeded the maximum size")
+
+// compresses a message as described in RFC 7692 Section 7.2.1
+func deflateMessage(data []byte) ([]byte, error) {
+	var compressed bytes.Buffer
+	writer, err := flate.NewWriter(&compressed, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = writer.Write(data)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// a flush always ends with an empty block, which is removed
+	return bytes.TrimSuffix(compressed.Bytes(), []byte(deflateTail[:4])), nil
+}
+
+// decompresses a message as described in RFC 7692 Section 7.2.2,
+// returning errMessageTooBig if it is larger than maxBytes
+func inflateMessage(data []byte, maxBytes int64) ([]byte, error) {
+	reader := flate.NewReader(io.MultiReader(bytes.NewReader(data), strings.NewReader(deflateTail)))
+	defer reader.Close()
+
+	inflated, err := io.ReadAll(io.LimitReader(reader, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(inflated)) > maxBytes {
+		return nil, errMessageTooBig
+	}
+	return inflated, nil
+}
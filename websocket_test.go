@@ -0,0 +1,432 @@
+package simplehttp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testWebSocketKey string = "dGhlIHNhbXBsZSBub25jZQ=="
+
+// testWebSocketClient is a minimal WebSocket client that sends masked frames.
+// Writes are sent in order by a separate goroutine, so the client can keep
+// writing while the server is blocked writing to it.
+type testWebSocketClient struct {
+	conn     net.Conn
+	reader   *bufio.Reader
+	writes   chan []byte
+	response string
+}
+
+// opens a WebSocket connection to the server and completes the opening handshake
+func dialTestWebSocket(t *testing.T, server *Server, path string, extraHeaders string) *testWebSocketClient {
+	client, conn := net.Pipe()
+	go server.handleConnection(conn)
+	return handshakeTestWebSocket(t, client, path, extraHeaders)
+}
+
+func handshakeTestWebSocket(t *testing.T, conn net.Conn, path string, extraHeaders string) *testWebSocketClient {
+	ws := &testWebSocketClient{conn: conn, reader: bufio.NewReader(conn), writes: make(chan []byte, 16)}
+	go func() {
+		for data := range ws.writes {
+			conn.Write(data)
+		}
+	}()
+
+	ws.writes <- []byte("GET " + path + " HTTP/1.1" + lineEnd +
+		"Host: localhost" + lineEnd +
+		"Upgrade: websocket" + lineEnd +
+		"Connection: keep-alive, Upgrade" + lineEnd +
+		"Sec-WebSocket-Key: " + testWebSocketKey + lineEnd +
+		extraHeaders +
+		"Sec-WebSocket-Version: 13" + doubleLineEnd)
+
+	for !strings.HasSuffix(ws.response, doubleLineEnd) {
+		line, err := ws.reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("unable to read the handshake response: %v", err)
+		}
+		ws.response += line
+	}
+
+	if !strings.HasPrefix(ws.response, "HTTP/1.1 101 Switching Protocols"+lineEnd) {
+		t.Fatalf("incorrect handshake response. Actual '%s'", ws.response)
+	}
+
+	if !strings.Contains(ws.response, "Sec-WebSocket-Accept: s3pPLMBiTxaQ9kYGzzhZRbK+xOo="+lineEnd) {
+		t.Fatalf("incorrect Sec-WebSocket-Accept header. Actual '%s'", ws.response)
+	}
+	return ws
+}
+
+func (ws *testWebSocketClient) writeFrame(first byte, payload []byte, masked bool) {
+	header := []byte{first}
+	second := byte(0)
+	if masked {
+		second = 0x80
+	}
+
+	switch {
+	case len(payload) < 126:
+		header = append(header, second|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		header = append(header, second|126)
+		header = binary.BigEndian.AppendUint16(header, uint16(len(payload)))
+	default:
+		header = append(header, second|127)
+		header = binary.BigEndian.AppendUint64(header, uint64(len(payload)))
+	}
+
+	body := append([]byte{}, payload...)
+	if masked {
+		mask := []byte{0x12, 0x34, 0x56, 0x78}
+		header = append(header, mask...)
+		for i := range body {
+			body[i] ^= mask[i%4]
+		}
+	}
+
+	ws.writes <- append(header, body...)
+}
+
+// reads an unmasked frame sent by the server
+func (ws *testWebSocketClient) readFrame(t *testing.T) (byte, []byte) {
+	header := make([]byte, 2)
+	_, err := io.ReadFull(ws.reader, header)
+	if err != nil {
+		t.Fatalf("unable to read a frame: %v", err)
+	}
+
+	if header[1]&0x80 != 0 {
+		t.Fatalf("expected frames sent by the server to be unmasked")
+	}
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		io.ReadFull(ws.reader, extended)
+		length = uint64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		io.ReadFull(ws.reader, extended)
+		length = binary.BigEndian.Uint64(extended)
+	}
+
+	payload := make([]byte, length)
+	io.ReadFull(ws.reader, payload)
+	return header[0], payload
+}
+
+func (ws *testWebSocketClient) expectClose(t *testing.T, code int) {
+	first, payload := ws.readFrame(t)
+	if first != 0x80|opClose || len(payload) < 2 || int(binary.BigEndian.Uint16(payload)) != code {
+		t.Fatalf("expected a close frame with code %d. Actual %x %v", code, first, payload)
+	}
+}
+
+func newEchoServer() (*Server, chan error) {
+	server := NewServer(0)
+	errs := make(chan error, 1)
+	server.WebSocket("/echo", func(req Request, conn *Conn) error {
+		conn.MaxMessageBytes = 1024
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				errs <- err
+				return nil
+			}
+
+			err = conn.WriteMessage(messageType, message)
+			if err != nil {
+				return err
+			}
+		}
+	})
+	return &server, errs
+}
+
+func TestWebSocket_Echo(t *testing.T) {
+	server, errs := newEchoServer()
+	ws := dialTestWebSocket(t, server, "/echo", "")
+
+	ws.writeFrame(0x80|opText, []byte("hello"), true)
+	first, payload := ws.readFrame(t)
+	if first != 0x80|opText || string(payload) != "hello" {
+		t.Fatalf("incorrect echo. Expected 'hello' | Actual %x '%s'", first, payload)
+	}
+
+	large := bytes.Repeat([]byte{0xab}, 300)
+	ws.writeFrame(0x80|opBinary, large, true)
+	first, payload = ws.readFrame(t)
+	if first != 0x80|opBinary || !bytes.Equal(payload, large) {
+		t.Fatalf("incorrect binary echo. Actual %x with %d bytes", first, len(payload))
+	}
+
+	ws.writeFrame(0x80|opClose, binary.BigEndian.AppendUint16(nil, CloseGoingAway), true)
+	ws.expectClose(t, CloseGoingAway)
+
+	err := <-errs
+	closeErr := &CloseError{}
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseGoingAway {
+		t.Fatalf("expected a CloseError with code %d. Actual %v", CloseGoingAway, err)
+	}
+}
+
+func TestWebSocket_FragmentsAndControlFrames(t *testing.T) {
+	server, _ := newEchoServer()
+	ws := dialTestWebSocket(t, server, "/echo", "")
+
+	var pongs []string
+	ws.writeFrame(opText, []byte("frag"), true)
+	ws.writeFrame(0x80|opPing, []byte("are you there"), true)
+	ws.writeFrame(opContinuation, []byte("men"), true)
+	ws.writeFrame(0x80|opContinuation, []byte("ted"), true)
+
+	for len(pongs) < 1 {
+		first, payload := ws.readFrame(t)
+		if first != 0x80|opPong {
+			t.Fatalf("expected a pong before the echo. Actual %x", first)
+		}
+		pongs = append(pongs, string(payload))
+	}
+
+	first, payload := ws.readFrame(t)
+	if first != 0x80|opText || string(payload) != "fragmented" || pongs[0] != "are you there" {
+		t.Fatalf("incorrect echo. Expected 'fragmented' | Actual '%s' after pongs %v", payload, pongs)
+	}
+}
+
+func TestWebSocket_ProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		first   byte
+		payload []byte
+		masked  bool
+		code    int
+	}{
+		{"unmasked frame", 0x80 | opText, []byte("hi"), false, CloseProtocolError},
+		{"reserved bits", 0xa0 | opText, []byte("hi"), true, CloseProtocolError},
+		{"unknown opcode", 0x80 | 0x3, []byte("hi"), true, CloseProtocolError},
+		{"continuation without a message", 0x80 | opContinuation, []byte("hi"), true, CloseProtocolError},
+		{"fragmented control frame", opPing, []byte("hi"), true, CloseProtocolError},
+		{"invalid UTF-8", 0x80 | opText, []byte{0xff, 0xfe}, true, CloseInvalidPayload},
+		{"message too big", 0x80 | opBinary, make([]byte, 2048), true, CloseMessageTooBig},
+		{"compressed without negotiation", 0xc0 | opText, []byte("hi"), true, CloseProtocolError},
+	}
+
+	for _, test := range tests {
+		server, errs := newEchoServer()
+		ws := dialTestWebSocket(t, server, "/echo", "")
+
+		ws.writeFrame(test.first, test.payload, test.masked)
+		ws.expectClose(t, test.code)
+
+		err := <-errs
+		if err == nil || errors.As(err, new(*CloseError)) {
+			t.Fatalf("%s: expected a protocol error. Actual %v", test.name, err)
+		}
+		ws.conn.Close()
+	}
+}
+
+func TestWebSocket_Compression(t *testing.T) {
+	server, _ := newEchoServer()
+	server.WebSocketCompression = true
+	ws := dialTestWebSocket(t, server, "/echo", "Sec-WebSocket-Extensions: permessage-deflate; client_max_window_bits"+lineEnd)
+
+	if !strings.Contains(ws.response, "Sec-WebSocket-Extensions: "+deflateExtension+lineEnd) {
+		t.Fatalf("expected permessage-deflate to be negotiated. Actual '%s'", ws.response)
+	}
+
+	message := strings.Repeat("compress me ", 20)
+	compressed, _ := deflateMessage([]byte(message))
+	ws.writeFrame(0xc0|opText, compressed, true)
+
+	first, payload := ws.readFrame(t)
+	if first != 0xc0|opText || len(payload) >= len(message) {
+		t.Fatalf("expected a compressed text frame. Actual %x with %d bytes", first, len(payload))
+	}
+
+	inflated, err := inflateMessage(payload, 1024)
+	if err != nil || string(inflated) != message {
+		t.Fatalf("incorrect echo. Expected '%s' | Actual '%s' (%v)", message, inflated, err)
+	}
+
+	bomb, _ := deflateMessage(make([]byte, 4096))
+	ws.writeFrame(0xc0|opBinary, bomb, true)
+	ws.expectClose(t, CloseMessageTooBig)
+}
+
+func TestAcceptsDeflate(t *testing.T) {
+	tests := map[string]bool{
+		"":                       false,
+		"permessage-deflate":     true,
+		"x-webkit-deflate-frame": false,
+		"permessage-deflate; server_max_window_bits=10, permessage-deflate": true,
+		"permessage-deflate; server_max_window_bits=10":                     false,
+		"permessage-deflate; unknown_param":                                 false,
+	}
+
+	for extensions, expected := range tests {
+		if acceptsDeflate(extensions) != expected {
+			t.Fatalf("incorrect result for '%s'. Expected '%t'", extensions, expected)
+		}
+	}
+}
+
+func TestWebSocket_WriteAndClose(t *testing.T) {
+	server := NewServer(0)
+	server.WebSocket("/news", func(req Request, conn *Conn) error {
+		writer, _ := conn.NextWriter(TextMessage)
+		writer.Write([]byte("breaking "))
+		writer.Write([]byte("news"))
+		writer.Close()
+
+		conn.Ping([]byte("ping"))
+		return conn.Close(ClosePolicyViolation, "done")
+	})
+
+	ws := dialTestWebSocket(t, &server, "/news", "")
+	expected := []struct {
+		first   byte
+		payload string
+	}{
+		{opText, "breaking "},
+		{opContinuation, "news"},
+		{0x80 | opContinuation, ""},
+		{0x80 | opPing, "ping"},
+	}
+
+	for _, frame := range expected {
+		first, payload := ws.readFrame(t)
+		if first != frame.first || string(payload) != frame.payload {
+			t.Fatalf("incorrect frame. Expected %x '%s' | Actual %x '%s'", frame.first, frame.payload, first, payload)
+		}
+	}
+
+	ws.expectClose(t, ClosePolicyViolation)
+	ws.writeFrame(0x80|opClose, binary.BigEndian.AppendUint16(nil, ClosePolicyViolation), true)
+
+	ws.conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err := ws.reader.ReadByte()
+	if err != io.EOF {
+		t.Fatalf("expected the server to close the connection. Actual %v", err)
+	}
+}
+
+func TestWebSocket_NextWriter(t *testing.T) {
+	server := NewServer(0)
+	errs := make(chan error, 3)
+	server.WebSocket("/text", func(req Request, conn *Conn) error {
+		writer, _ := conn.NextWriter(TextMessage)
+		writer.Write([]byte{'a', 0xc3})
+
+		sent := make(chan struct{})
+		go func() {
+			conn.WriteMessage(TextMessage, []byte("other"))
+			close(sent)
+		}()
+		time.Sleep(50 * time.Millisecond)
+
+		writer.Write([]byte{0xa9, '!'})
+		_, err := writer.Write([]byte{0xff})
+		errs <- err
+		writer.Close()
+		<-sent
+
+		writer, _ = conn.NextWriter(TextMessage)
+		writer.Write([]byte{0xe2, 0x82})
+		errs <- writer.Close()
+		return nil
+	})
+
+	ws := dialTestWebSocket(t, &server, "/text", "")
+	expected := []struct {
+		first   byte
+		payload string
+	}{
+		{opText, "a"},
+		{opContinuation, "é!"},
+		{0x80 | opContinuation, ""},
+		{0x80 | opText, "other"},
+		{0x80 | opText, ""},
+	}
+
+	for _, frame := range expected {
+		first, payload := ws.readFrame(t)
+		if first != frame.first || string(payload) != frame.payload {
+			t.Fatalf("incorrect frame. Expected %x '%s' | Actual %x '%s'", frame.first, frame.payload, first, payload)
+		}
+	}
+
+	if <-errs == nil || <-errs == nil {
+		t.Fatalf("expected errors for invalid UTF-8")
+	}
+}
+
+func TestWebSocket_CheckOrigin(t *testing.T) {
+	server, _ := newEchoServer()
+	dialTestWebSocket(t, server, "/echo", "Origin: http://localhost"+lineEnd)
+
+	handshake := "GET /echo HTTP/1.1" + lineEnd + "Host: localhost" + lineEnd +
+		"Upgrade: websocket" + lineEnd + "Connection: Upgrade" + lineEnd +
+		"Sec-WebSocket-Key: " + testWebSocketKey + lineEnd + "Sec-WebSocket-Version: 13" + lineEnd +
+		"Origin: https://evil.com" + doubleLineEnd
+
+	response := sendRawRequest(server, handshake)
+	if !strings.HasPrefix(response, "HTTP/1.0 403 Forbidden") {
+		t.Fatalf("expected a cross-origin handshake to be rejected. Actual '%s'", response)
+	}
+
+	server.WebSocketCheckOrigin = func(req Request) bool {
+		origin, _ := req.headers.get("Origin")
+		return origin == "https://evil.com"
+	}
+	client, conn := net.Pipe()
+	go server.handleConnection(conn)
+	handshakeTestWebSocket(t, client, "/echo", "Origin: https://evil.com"+lineEnd)
+}
+
+func TestWebSocket_InvalidHandshake(t *testing.T) {
+	server, _ := newEchoServer()
+
+	response := sendRawRequest(server, "GET /echo HTTP/1.1"+doubleLineEnd)
+	if !strings.HasPrefix(response, "HTTP/1.0 426 Upgrade Required") || !strings.Contains(response, "Sec-WebSocket-Version: 13") {
+		t.Fatalf("incorrect response. Actual '%s'", response)
+	}
+
+	response = sendRawRequest(server, "GET /echo HTTP/1.1"+lineEnd+
+		"Upgrade: websocket"+lineEnd+"Connection: Upgrade"+lineEnd+
+		"Sec-WebSocket-Key: short"+lineEnd+"Sec-WebSocket-Version: 13"+doubleLineEnd)
+	if !strings.HasPrefix(response, "HTTP/1.0 400 Bad Request") {
+		t.Fatalf("incorrect response. Actual '%s'", response)
+	}
+}
+
+func TestWebSocket_Handler(t *testing.T) {
+	server, _ := newEchoServer()
+	httpServer := httptest.NewServer(server.Handler())
+	defer httpServer.Close()
+
+	conn, err := net.Dial("tcp", httpServer.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("did not expect an error but received: %v", err)
+	}
+	defer conn.Close()
+
+	ws := handshakeTestWebSocket(t, conn, "/echo", "")
+	ws.writeFrame(0x80|opText, []byte("through net/http"), true)
+
+	first, payload := ws.readFrame(t)
+	if first != 0x80|opText || string(payload) != "through net/http" {
+		t.Fatalf("incorrect echo. Actual %x '%s'", first, payload)
+	}
+}